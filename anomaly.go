@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// anomalyLevel is the severity of a change compared to the previous output.
+type anomalyLevel int
+
+const (
+	anomalyNone anomalyLevel = iota
+	anomalyWarning
+	anomalyCritical
+)
+
+var anomalyLevelNames = []string{"none", "warning", "critical"}
+
+func (l anomalyLevel) String() string {
+	return anomalyLevelNames[l]
+}
+
+func (l *anomalyLevel) Set(value string) error {
+	for i, name := range anomalyLevelNames {
+		if name == value {
+			*l = anomalyLevel(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown anomaly level '%s'", value)
+}
+
+// detectAnomalies compares the converted channels with the files currently
+// in dir and reports channels losing more than half of their events or
+// covered hours and channels whose descriptions went missing, as well as a
+// sharp drop of the total covered hours. Channels of the previous manifest
+// missing from the output are critical, unless they were removed from the
+// channels file, are inactive or failed in a partial publish. It returns the
+// highest level found.
+func detectAnomalies(t tenant, dir string, summary *tenantSummary, report *runReport) anomalyLevel {
+	converted := summary.converted
+	level := anomalyNone
+	raise := func(l anomalyLevel, c requestedChannel, format string, args ...interface{}) {
+		report.anomaly(l, c, fmt.Sprintf(format, args...))
+		if l > level {
+			level = l
+		}
+	}
+
+	var prevTotal, newTotal time.Duration
+	for _, c := range converted {
+		prev, err := readPreviousOutput(t, dir, c.channel)
+		if err != nil {
+			continue
+		}
+		prevEvents, newEvents := len(prev.Events.Values), len(c.output.Events.Values)
+		prevHours, newHours := coveredDuration(prev), coveredDuration(c.output)
+		prevTotal += prevHours
+		newTotal += newHours
+
+		if prevEvents > 0 && newEvents*2 < prevEvents {
+			raise(anomalyCritical, c.channel, "events dropped from %d to %d", prevEvents, newEvents)
+		}
+		if prevHours > 0 && newHours*2 < prevHours {
+			raise(anomalyCritical, c.channel, "covered time dropped from %s to %s", prevHours, newHours)
+		}
+		if prevDesc, newDesc := describedShare(prev), describedShare(c.output); prevDesc >= 0.5 && newDesc < 0.1 {
+			raise(anomalyWarning, c.channel, "events with description dropped from %.0f%% to %.0f%%", prevDesc*100, newDesc*100)
+		}
+	}
+
+	if prev, err := readManifest(dir); err == nil && prev != nil {
+		written := make(map[string]bool)
+		for _, c := range converted {
+			written[c.channel.ID] = true
+		}
+		for _, c := range summary.FailedChannels {
+			written[c.ID] = true
+		}
+		var missing []string
+		for id := range prev.Channels {
+			if _, inactive := summary.inactive[id]; !written[id] && !inactive && (summary.mapped == nil || summary.mapped[id]) {
+				missing = append(missing, id)
+			}
+		}
+		sort.Strings(missing)
+		for _, id := range missing {
+			c := prev.Channels[id]
+			raise(anomalyCritical, requestedChannel{ID: id, Name: c.Name}, "channel missing from the output, previously %d events", len(c.Events))
+		}
+	}
+
+	if prevTotal > 0 && newTotal*4 < prevTotal*3 {
+		raise(anomalyWarning, requestedChannel{}, "total covered time dropped from %s to %s", prevTotal, newTotal)
+	}
+	return level
+}
+
+// coveredDuration sums the durations of the channel events.
+func coveredDuration(c *outputChannel) time.Duration {
+	var total time.Duration
+	for _, e := range c.Events.Values {
+		start, err := time.Parse(outDateLayout, e.StartTime)
+		if err != nil {
+			continue
+		}
+		if e.EndTime != "" {
+			if end, err := time.Parse(outDateLayout, e.EndTime); err == nil {
+				total += end.Sub(start)
+			}
+			continue
+		}
+		total += e.duration()
+	}
+	return total
+}
+
+// describedShare returns the share of the channel events having a description.
+func describedShare(c *outputChannel) float64 {
+	if len(c.Events.Values) == 0 {
+		return 0
+	}
+	described := 0
+	for _, e := range c.Events.Values {
+		if e.Description != "" {
+			described++
+		}
+	}
+	return float64(described) / float64(len(c.Events.Values))
+}
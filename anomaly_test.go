@@ -0,0 +1,52 @@
+package main
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestDetectAnomaliesMissingChannels(t *testing.T) {
+	tests := []struct {
+		name    string
+		summary *tenantSummary
+		want    anomalyLevel
+	}{
+		{
+			name:    "missing",
+			summary: &tenantSummary{mapped: map[string]bool{"1": true}},
+			want:    anomalyCritical,
+		},
+		{
+			name:    "removed from the channels file",
+			summary: &tenantSummary{mapped: map[string]bool{}},
+			want:    anomalyNone,
+		},
+		{
+			name:    "inactive",
+			summary: &tenantSummary{mapped: map[string]bool{"1": true}, inactive: map[string]requestedChannel{"1": {ID: "1"}}},
+			want:    anomalyNone,
+		},
+		{
+			name: "failed",
+			summary: &tenantSummary{mapped: map[string]bool{"1": true},
+				FailedChannels: []failedChannel{{ID: "1", Name: "One"}}},
+			want: anomalyNone,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			prev := &manifest{Generated: time.Now(), Channels: map[string]*manifestChannel{
+				"1": {Name: "One", File: "n_events_1.xml", Events: map[string]string{"a": "h"}},
+			}}
+			if err := writeManifest(dir, prev); err != nil {
+				t.Fatal(err)
+			}
+			report := newRunReport(ioutil.Discard)
+			if got := detectAnomalies(tenant{}, dir, tt.summary, report); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// eventTimes returns the start and end of a published event.
+func eventTimes(e outputEvent) (time.Time, time.Time, error) {
+	start, err := time.Parse(outDateLayout, e.StartTime)
+	if err != nil {
+		return start, start, err
+	}
+	if e.EndTime == "" {
+		return start, start.Add(e.duration()), nil
+	}
+	end, err := time.Parse(outDateLayout, e.EndTime)
+	return start, end, err
+}
+
+// duration returns the duration_seconds of the event, 0 when it was not written.
+func (e outputEvent) duration() time.Duration {
+	if e.Duration == nil {
+		return 0
+	}
+	return time.Duration(*e.Duration) * time.Second
+}
+
+type apiChannel struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Events int    `json:"events"`
+}
+
+// handleAPIChannels serves GET /api/channels listing the published channels.
+func (s *server) handleAPIChannels(w http.ResponseWriter, req *http.Request) {
+	channels, err := loadPublishedChannels(*outputDir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	result := make([]apiChannel, 0, len(channels))
+	for _, c := range channels {
+		result = append(result, apiChannel{ID: c.ID, Name: c.Name, Events: len(c.Events.Values)})
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handleAPIEvents serves GET /api/channels/{id}/events?from=&to= streaming
+// the events of the channel overlapping the optional RFC 3339 time range as
+// newline delimited JSON.
+func (s *server) handleAPIEvents(w http.ResponseWriter, req *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(req.URL.Path, "/api/channels/"), "/")
+	if len(parts) != 2 || parts[1] != "events" {
+		http.NotFound(w, req)
+		return
+	}
+	from, to, err := parseTimeRange(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	channels, err := loadPublishedChannels(*outputDir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, c := range channels {
+		if c.ID != parts[0] {
+			continue
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(w)
+		flusher, _ := w.(http.Flusher)
+		for _, e := range c.Events.Values {
+			if !eventInRange(e, from, to) {
+				continue
+			}
+			if err := enc.Encode(e); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		return
+	}
+	http.NotFound(w, req)
+}
+
+// parseTimeRange reads the optional from and to query parameters.
+func parseTimeRange(req *http.Request) (time.Time, time.Time, error) {
+	var from, to time.Time
+	var err error
+	if v := req.URL.Query().Get("from"); v != "" {
+		if from, err = time.Parse(time.RFC3339, v); err != nil {
+			return from, to, fmt.Errorf("invalid from due: %v", err)
+		}
+	}
+	if v := req.URL.Query().Get("to"); v != "" {
+		if to, err = time.Parse(time.RFC3339, v); err != nil {
+			return from, to, fmt.Errorf("invalid to due: %v", err)
+		}
+	}
+	return from, to, nil
+}
+
+// eventInRange reports whether the event overlaps [from, to), a zero bound
+// is open.
+func eventInRange(e outputEvent, from, to time.Time) bool {
+	start, end, err := eventTimes(e)
+	if err != nil {
+		return false
+	}
+	if !from.IsZero() && !end.After(from) {
+		return false
+	}
+	if !to.IsZero() && !start.Before(to) {
+		return false
+	}
+	return true
+}
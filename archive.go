@@ -0,0 +1,29 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+)
+
+// archiveDir is the directory every run of the archive command appends its
+// converted events to, empty outside of it.
+var archiveDir string
+
+// archiveCommand converts the sources once like a run without a command and
+// appends the converted events together with the run time and their source
+// file and provider to the archive, keeping an as-broadcast history after
+// the source files are rotated away:
+//
+//	<dir>/[<tenant>/]date=2024-06-01/channel=163/run-20240601T040000Z.parquet
+func archiveCommand(args []string, cache *sourceCache) {
+	fs := flag.NewFlagSet("archive", flag.ExitOnError)
+	dir := fs.String("dir", "archive", "archive directory")
+	fs.Parse(args)
+
+	archiveDir = *dir
+	*provenance = true
+	if _, err := defaultJob().run(cache, newRunReport(os.Stdout)); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// auditEntry records a change of provider data by a transform rule or the
+// plugin, written as a JSON line into the -auditLog file.
+type auditEntry struct {
+	Time    string        `json:"time"`
+	User    string        `json:"user"`
+	Source  string        `json:"source"`
+	Rule    string        `json:"rule,omitempty"`
+	Channel string        `json:"channel"`
+	Event   string        `json:"event"`
+	Action  string        `json:"action"`
+	Changes []auditChange `json:"changes,omitempty"`
+}
+
+// auditChange is a field of an event before and after a change.
+type auditChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+type auditWriter struct {
+	mu   sync.Mutex
+	w    io.Writer
+	user string
+}
+
+// auditLog is the -auditLog file, nil without one.
+var auditLog *auditWriter
+
+func newAuditWriter(w io.Writer) *auditWriter {
+	name := os.Getenv("EPGTOOL_AUDIT_USER")
+	if name == "" {
+		if u, err := user.Current(); err == nil {
+			name = u.Username
+		}
+	}
+	return &auditWriter{w: w, user: name}
+}
+
+// change records the difference of the JSON forms of an event before and
+// after a rule or the plugin, which dropped it when after is nil.
+func (a *auditWriter) change(source, rule, channel string, before, after map[string]interface{}) {
+	if a == nil {
+		return
+	}
+	entry := auditEntry{Source: source, Rule: rule, Channel: channel, Event: auditEventKey(before), Action: "dropped"}
+	if after != nil {
+		entry.Action = "changed"
+		entry.Changes = diffFields(before, after)
+		if len(entry.Changes) == 0 {
+			return
+		}
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	entry.Time = time.Now().UTC().Format(time.RFC3339)
+	entry.User = a.user
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	a.w.Write(append(data, '\n'))
+}
+
+// auditEventKey identifies an output event by its ID and a source programme
+// by its start.
+func auditEventKey(m map[string]interface{}) string {
+	if id, ok := m["id"]; ok {
+		return fmt.Sprint(id)
+	}
+	return fmt.Sprint(m["start"])
+}
+
+func diffFields(before, after map[string]interface{}) []auditChange {
+	fields := make(map[string]bool)
+	for k := range before {
+		fields[k] = true
+	}
+	for k := range after {
+		fields[k] = true
+	}
+	names := make([]string, 0, len(fields))
+	for k := range fields {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var result []auditChange
+	for _, k := range names {
+		if !reflect.DeepEqual(before[k], after[k]) {
+			result = append(result, auditChange{Field: k, Before: before[k], After: after[k]})
+		}
+	}
+	return result
+}
+
+func copyFields(m map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		result[k] = v
+	}
+	return result
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"time"
+)
+
+// fillFromBaseline adds the events of the previous output of the channel
+// which have not ended yet and overlap none of the converted events, so a
+// channel missing from the sources for a while keeps its known schedule
+// instead of a hole. It is only used for channels without converted events,
+// as the sources of the others are authoritative, including their removals.
+// It returns the number of events added.
+func fillFromBaseline(prev *outputChannel, c *outputChannel, now time.Time) int {
+	spans := make(timeline, 0, len(c.Events.Values))
+	for _, e := range c.Events.Values {
+		if s, ok := eventSpan(e); ok {
+			spans.insert(s)
+		}
+	}
+	added := 0
+	for _, e := range prev.Events.Values {
+		s, ok := eventSpan(e)
+		if !ok || !s.end.After(now) || len(spans.intersections(s)) > 0 {
+			continue
+		}
+		spans.insert(s)
+		c.Events.Values = append(c.Events.Values, e)
+		added++
+	}
+	if added > 0 {
+		sortEvents(c.Events.Values)
+	}
+	return added
+}
+
+// eventSpan returns the time span of an output event.
+func eventSpan(e outputEvent) (span, bool) {
+	start, err := time.Parse(outDateLayout, e.StartTime)
+	if err != nil {
+		return span{}, false
+	}
+	if e.EndTime == "" {
+		return span{start: start, end: start.Add(e.duration())}, true
+	}
+	end, err := time.Parse(outDateLayout, e.EndTime)
+	if err != nil {
+		return span{}, false
+	}
+	return span{start: start, end: end}, true
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+)
+
+// parseCacheVersion is part of the cache key and has to be changed whenever
+// the source structs change, so stale entries are not decoded.
+const parseCacheVersion = "v2"
+
+// readCachedSource returns the parsed source file from the cache in the output store
+// keyed by the hash of the file content and the window, parsing and storing
+// it on a miss.
+func readCachedSource(dir string, fname string, w sourceWindow) (source, error) {
+	hash, err := fileHash(fname)
+	if err != nil {
+		return source{}, fmt.Errorf("unable to hash source file '%s' due: %v", fname, err)
+	}
+	cacheFile := filepath.Join(dir, hash+"-"+parseCacheVersion+w.key()+".gob")
+
+	acquireFile()
+	if data, err := outputFiles.ReadFile(cacheFile); err == nil {
+		releaseFile()
+		var s source
+		if err = gob.NewDecoder(bytes.NewReader(data)).Decode(&s); err == nil {
+			return s, nil
+		}
+		log.Printf("ignoring broken parse cache entry '%s' due: %v", cacheFile, err)
+	} else {
+		releaseFile()
+	}
+
+	s, err := decodeSource(fname, w)
+	if err != nil {
+		return s, err
+	}
+	if err := writeCachedSource(cacheFile, &s); err != nil {
+		log.Printf("unable to write parse cache entry '%s' due: %v", cacheFile, err)
+	}
+	return s, nil
+}
+
+// writeCachedSource writes the cache entry into the output store, like the
+// output files at once.
+func writeCachedSource(cacheFile string, s *source) error {
+	if err := mkdirAll(filepath.Dir(cacheFile)); err != nil {
+		return err
+	}
+	return writeFileAtomic(cacheFile, func(w io.Writer) error { return gob.NewEncoder(w).Encode(s) })
+}
+
+func fileHash(fname string) (string, error) {
+	acquireFile()
+	defer releaseFile()
+	f, err := sourceFiles.Open(fname)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// channelsCommand groups the subcommands working on the channels file:
+//
+//	epgtool -channelsFile channels.csv channels validate
+//	epgtool -outputDir out channels playlist -playlist channels.m3u
+func channelsCommand(args []string) {
+	if len(args) == 0 {
+		log.Fatalf("missing channels subcommand, e.g validate or playlist")
+	}
+	switch args[0] {
+	case "validate":
+		validateChannelsCommand(args[1:])
+	case "playlist":
+		playlistCommand(args[1:])
+	default:
+		log.Fatalf("unknown channels subcommand '%s'", args[0])
+	}
+}
+
+// validateChannelsCommand reports the problems of the channels file and exits
+// with status 1 when there are any, so it can be used as a CI check.
+func validateChannelsCommand(args []string) {
+	fs := flag.NewFlagSet("channels validate", flag.ExitOnError)
+	skipSources := fs.Bool("skipSources", false, "do not check the channel names against the latest source files")
+	fs.Parse(args)
+
+	j := defaultJob()
+	channels, problems, err := parseChannelsFile(j.ChannelsFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var sources *sourceChannels
+	if !*skipSources {
+		_, sources, err = j.channelEvents(context.Background(), newSourceCache())
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer sources.close()
+	}
+	problems = append(problems, validateChannels(j.ChannelsFile, channels, sources)...)
+
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	if unmapped := unmappedChannels(channels, sources); len(unmapped) > 0 {
+		fmt.Printf("%d source channels are neither mapped nor ignored: %s\n", len(unmapped), strings.Join(unmapped, ", "))
+	}
+	if len(problems) > 0 {
+		fmt.Printf("%d problems found in %s\n", len(problems), j.ChannelsFile)
+		os.Exit(1)
+	}
+	fmt.Printf("%s: %d channels OK\n", j.ChannelsFile, len(channels))
+}
+
+// validateChannels checks the channels for duplicate names, for IDs
+// generated for rows with an empty ID, for rows sharing the ID of an earlier
+// row without merge=true or with options differing from the first row and,
+// when sources is not nil, for names matching no source channel. Ignored
+// channels are checked only for their names. Rows with an empty name are
+// reported by parseChannelsFile.
+func validateChannels(fileName string, channels []requestedChannel, sources *sourceChannels) []string {
+	var problems []string
+	unmapped := unmappedChannels(channels, sources)
+	ids := make(map[string]requestedChannel)
+	names := make(map[string]int)
+	for _, c := range channels {
+		if c.generatedID {
+			problems = append(problems, fmt.Sprintf("%s:%d: channel '%s' has an empty ID, it is generated as '%s'",
+				fileName, c.Line, c.Name, c.ID))
+		}
+		if first, ok := ids[c.ID]; !ok {
+			ids[c.ID] = c
+		} else if !c.ignored() && !c.Options.Merge {
+			problems = append(problems, fmt.Sprintf("%s:%d: duplicate channel ID '%s', first defined on line %d, add merge=true to merge '%s' into it",
+				fileName, c.Line, c.ID, first.Line, c.Name))
+		} else if spec := withoutMergeOption(c.OptionSpec); !c.ignored() && spec != "" && spec != withoutMergeOption(first.OptionSpec) {
+			problems = append(problems, fmt.Sprintf("%s:%d: options of channel '%s' are ignored, it is merged into ID '%s' of line %d",
+				fileName, c.Line, c.Name, c.ID, first.Line))
+		}
+		if line, ok := names[c.Name]; ok {
+			problems = append(problems, fmt.Sprintf("%s:%d: duplicate channel name '%s', first defined on line %d", fileName, c.Line, c.Name, line))
+		} else {
+			names[c.Name] = c.Line
+		}
+		if sources != nil {
+			if !sources.has(c.Name) {
+				problem := fmt.Sprintf("%s:%d: channel '%s' matches no channel in the source files", fileName, c.Line, c.Name)
+				if suggestions := suggestChannelNames(c.Name, unmapped, 3); len(suggestions) > 0 {
+					problem += fmt.Sprintf(", did you mean '%s'?", strings.Join(suggestions, "', '"))
+				}
+				problems = append(problems, problem)
+			}
+		}
+	}
+	return problems
+}
+
+// withoutMergeOption returns the options spec of a channel without the merge
+// option, which is the only one a merged row does not share with the first.
+func withoutMergeOption(spec string) string {
+	var kept []string
+	for _, kv := range strings.Split(spec, ";") {
+		if kv = strings.TrimSpace(kv); kv != "" && strings.TrimSpace(strings.SplitN(kv, "=", 2)[0]) != "merge" {
+			kept = append(kept, kv)
+		}
+	}
+	return strings.Join(kept, ";")
+}
+
+// unmappedChannels returns the sorted names of the source channels listed
+// in the channels file neither with an ID nor as ignored.
+func unmappedChannels(channels []requestedChannel, sources *sourceChannels) []string {
+	listed := make(map[string]bool)
+	for _, c := range channels {
+		listed[c.Name] = true
+	}
+	var unmapped []string
+	for _, name := range sources.names() {
+		if !listed[name] {
+			unmapped = append(unmapped, name)
+		}
+	}
+	return unmapped
+}
+
+// suggestChannelNames returns up to n of the candidates closest to name,
+// ignoring case, spaces and punctuation, which are similar enough to be a
+// likely rename of the channel.
+func suggestChannelNames(name string, candidates []string, n int) []string {
+	key := channelNameKey(name)
+	type match struct {
+		name     string
+		distance int
+	}
+	var matches []match
+	for _, c := range candidates {
+		candidate := channelNameKey(c)
+		d := editDistance([]rune(key), []rune(candidate))
+		if d <= len([]rune(key))/5+1 || (key != "" && strings.Contains(candidate, key)) {
+			matches = append(matches, match{c, d})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].distance < matches[j].distance })
+	var result []string
+	for i := 0; i < len(matches) && i < n; i++ {
+		result = append(result, matches[i].name)
+	}
+	return result
+}
+
+func channelNameKey(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+}
+
+// editDistance returns the Levenshtein distance of a and b.
+func editDistance(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j-1] + cost
+			if prev[j]+1 < cur[j] {
+				cur[j] = prev[j] + 1
+			}
+			if cur[j-1]+1 < cur[j] {
+				cur[j] = cur[j-1] + 1
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadRequestedChannelsPersistsGeneratedIDs(t *testing.T) {
+	defer func(v bool) { *generateIDs = v }(*generateIDs)
+	*generateIDs = true
+
+	fileName := filepath.Join(t.TempDir(), "channels.csv")
+	if err := ioutil.WriteFile(fileName, []byte("# lineup\n1,One\n,New\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	channels, err := readRequestedChannels(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := channels[1].ID
+	if id == "" {
+		t.Fatal("no ID was generated")
+	}
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "# lineup\n1,One\n" + id + ",New\n"; string(data) != want {
+		t.Errorf("got\n%s\nwant\n%s", data, want)
+	}
+
+	// Reading it again uses the persisted ID without writing the file.
+	channels, err = readRequestedChannels(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if channels[1].ID != id || channels[1].generatedID {
+		t.Errorf("got ID %s generated %v, want the persisted %s", channels[1].ID, channels[1].generatedID, id)
+	}
+	if data, _ := ioutil.ReadFile(fileName); strings.Count(string(data), id) != 1 {
+		t.Errorf("got\n%s", data)
+	}
+}
+
+func TestValidateChannels(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		generateIDs bool
+		want        []string
+	}{
+		{"valid", "1,One\n2,Two,lang=en\n", false, nil},
+		{"explicit merge", "1,One,lang=en\n1,One Late,merge=true\n1,One Night,lang=en;merge=true\n", false, nil},
+		{"duplicate ID", "1,One\n2,Two\n1,Uno\n", false, []string{
+			"channels.csv:3: duplicate channel ID '1', first defined on line 1, add merge=true to merge 'Uno' into it"}},
+		{"ignored options of merged row", "1,One\n1,One Late,merge=true;lang=en\n", false, []string{
+			"channels.csv:2: options of channel 'One Late' are ignored, it is merged into ID '1' of line 1"}},
+		{"duplicate name", "1,One\n2,One\n", false, []string{
+			"channels.csv:2: duplicate channel name 'One', first defined on line 1"}},
+		{"ignored rows", "-,Shop\n-,Promo\n", false, nil},
+		{"empty fields", "1,One\n,Two\n3,\n , \n", false, []string{
+			"channels.csv:2: skipping channel without ID or name: [\"\" \"Two\"]",
+			"channels.csv:3: skipping channel without ID or name: [\"3\" \"\"]",
+			"channels.csv:4: skipping channel without ID or name: [\"\" \"\"]"}},
+		{"generated ID", "1,One\n,Two\n", true, []string{
+			"channels.csv:2: channel 'Two' has an empty ID, it is generated as '" + generateChannelID("Two", map[string]bool{"1": true}) + "'"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(v bool) { *generateIDs = v }(*generateIDs)
+			*generateIDs = tt.generateIDs
+
+			fileName := filepath.Join(t.TempDir(), "channels.csv")
+			if err := ioutil.WriteFile(fileName, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			channels, problems, err := parseChannelsFile(fileName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			problems = append(problems, validateChannels(fileName, channels, nil)...)
+			for i := range problems {
+				problems[i] = strings.TrimPrefix(problems[i], filepath.Dir(fileName)+string(filepath.Separator))
+			}
+			if !reflect.DeepEqual(problems, tt.want) {
+				t.Errorf("got problems\n%q\nwant\n%q", problems, tt.want)
+			}
+		})
+	}
+}
+
+func TestChannelOptionsWithDelta(t *testing.T) {
+	defer func(v bool) { *deltaOutput = v }(*deltaOutput)
+	tests := []struct {
+		spec    string
+		delta   bool
+		wantErr string
+	}{
+		{"minCoverage=48h", false, ""},
+		{"correctShift=true", false, ""},
+		{"lang=en;window=48h", true, ""},
+		{"minCoverage=48h", true, "minCoverage reads the previous output file"},
+		{"correctShift=true", true, "correctShift reads the previous output file"},
+	}
+	for _, tt := range tests {
+		*deltaOutput = tt.delta
+		_, err := parseChannelOptions(globalChannelOptions(), tt.spec)
+		if (tt.wantErr == "" && err != nil) || (tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr))) {
+			t.Errorf("%q with delta %v: got error %v, want %q", tt.spec, tt.delta, err, tt.wantErr)
+		}
+	}
+}
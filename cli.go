@@ -0,0 +1,167 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+type command struct {
+	name    string
+	summary string
+	example string
+}
+
+var commands = []command{
+	{"convert", "convert once, optionally only previewing the changes", "epgtool convert -preview"},
+	{"run", "run the jobs of a batch config file", "epgtool run -config epgtool.json -all -parallel"},
+	{"serve", "serve the HTTP API and run the conversion periodically", "epgtool serve -addr :8080 -interval 1h"},
+	{"channels", "validate the channels file or cross-check an M3U playlist", "epgtool channels validate"},
+	{"init", "bootstrap a working directory", "epgtool init -dir /srv/epg"},
+	{"archive", "convert once and append the events to the archive", "epgtool archive -dir /srv/epg/archive"},
+	{"reconcile", "compare an as-run log with the published EPG", "epgtool reconcile -asRun asrun.csv"},
+	{"parse", "read the source files into a JSON file of programmes", "epgtool parse > norm.json"},
+	{"transform", "convert the programmes of parse into a JSON file of channels",
+		"epgtool transform -input norm.json > converted.json"},
+	{"publish", "write the channels of transform into the output directory", "epgtool publish -input converted.json"},
+	{"schema", "print the JSON Schema of the parse or transform output", "epgtool schema channels"},
+	{"inspect", "show the source records of a channel at a time and which one won",
+		"epgtool inspect -channel Alfa -at 2024-01-15T20:00"},
+	{"grep", "search the programmes of the source files", "epgtool grep -title Новини -channel Alfa"},
+	{"completion", "print a bash, zsh or fish completion script", "source <(epgtool completion bash)"},
+	{"help", "print this help", "epgtool help"},
+}
+
+// flagGroups lists the global flags by topic for the help output, flags
+// missing here are listed under "Other".
+var flagGroups = []struct {
+	name  string
+	flags []string
+}{
+	{"Sources", []string{"dataDir", "sourcePrefix", "sourceFileLimit", "sourceURL", "fetchTimeout", "fetchRetries",
+		"fetchBackoff", "parseCache", "spillEvents", "strictParse", "validateDTD", "quarantine", "tz", "baseline"}},
+	{"Channels and tenants", []string{"channelsFile", "channelIDPattern", "generateChannelIDs", "tenant"}},
+	{"Conversion", []string{"lang", "overlapStrategy", "overlapTolerance", "repairThreshold", "snapTimes",
+		"snapReportThreshold", "includeCategories", "excludeCategories", "window", "sortTieBreak", "parts", "partGap",
+		"sports", "titleCase", "acronyms", "translit", "translitFields", "normalizeText", "normalizePeople",
+		"maxPeople", "countryCodes", "countryCodesFile", "perex", "plugin", "rules"}},
+	{"Output", []string{"outputDir", "outputFormat", "timeFields", "peopleFormat", "dateLocale", "dateFormat", "delta",
+		"nowNext", "primeTime", "reminders", "redisAddr", "redisPrefix", "parquetDir", "packageTrees", "provenance",
+		"eventHashes", "stats", "headerComment", "tvaAuthority", "indent", "cdata", "fieldPresence", "sign", "encrypt",
+		"deliver", "writeWorkers", "maxOpenFiles"}},
+	{"Guardrails and anomalies", []string{"maxEventsPerChannel", "minEventsPerChannel", "maxOutputSize", "guardrails",
+		"partial", "minCoverage", "timeShift", "maxAnomalyLevel", "expectMinChannels", "expectMinEventsPerChannel",
+		"deadline"}},
+	{"Reporting and notifications", []string{"progress", "logSample", "reportFile", "auditLog", "notifyAnomalyLevel",
+		"smtpAddr", "smtpFrom", "smtpTo", "smtpUser", "smtpTemplate", "slackWebhook", "teamsWebhook",
+		"notifyCollisions", "notifyGaps"}},
+}
+
+func init() {
+	flag.Usage = func() { printUsage(flag.CommandLine.Output()) }
+}
+
+// printUsage prints the commands with examples and the flags grouped by
+// topic.
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: epgtool [flags] [command]\n\n")
+	fmt.Fprintf(w, "Without a command the sources are converted once using the flags, like convert.\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-11s %s\n  %-11s e.g %s\n", c.name, c.summary, "", c.example)
+	}
+
+	grouped := make(map[string]bool)
+	for _, g := range flagGroups {
+		fmt.Fprintf(w, "\n%s:\n", g.name)
+		for _, name := range g.flags {
+			if f := flag.Lookup(name); f != nil {
+				printFlag(w, f)
+				grouped[name] = true
+			}
+		}
+	}
+	var other []*flag.Flag
+	flag.VisitAll(func(f *flag.Flag) {
+		if !grouped[f.Name] {
+			other = append(other, f)
+		}
+	})
+	if len(other) > 0 {
+		fmt.Fprintf(w, "\nOther:\n")
+		for _, f := range other {
+			printFlag(w, f)
+		}
+	}
+	fmt.Fprintf(w, "\nRun 'epgtool <command> -h' for the flags of a command.\n")
+}
+
+func printFlag(w io.Writer, f *flag.Flag) {
+	name, usage := flag.UnquoteUsage(f)
+	line := "  -" + f.Name
+	if name != "" {
+		line += " " + name
+	}
+	fmt.Fprintf(w, "%s\n    \t%s", line, usage)
+	switch {
+	case f.DefValue == "" || f.DefValue == "0" || f.DefValue == "false" || f.DefValue == "0s":
+	case name == "string":
+		fmt.Fprintf(w, " (default %q)", f.DefValue)
+	default:
+		fmt.Fprintf(w, " (default %v)", f.DefValue)
+	}
+	fmt.Fprintln(w)
+}
+
+// completionCommand prints a completion script of the commands and global
+// flags for the given shell.
+func completionCommand(args []string) {
+	if len(args) != 1 {
+		log.Fatalf("usage: epgtool completion bash|zsh|fish")
+	}
+	var names, flags []string
+	for _, c := range commands {
+		names = append(names, c.name)
+	}
+	flag.VisitAll(func(f *flag.Flag) { flags = append(flags, f.Name) })
+	sort.Strings(flags)
+
+	switch args[0] {
+	case "bash", "zsh":
+		if args[0] == "zsh" {
+			fmt.Println("autoload -U +X bashcompinit && bashcompinit")
+		}
+		fmt.Printf(`_epgtool() {
+	local cur="${COMP_WORDS[COMP_CWORD]}"
+	case "${COMP_WORDS[1]}" in
+		channels) [ "$COMP_CWORD" -eq 2 ] && COMPREPLY=($(compgen -W "validate playlist" -- "$cur")); return ;;
+		completion) COMPREPLY=($(compgen -W "bash zsh fish" -- "$cur")); return ;;
+		schema) COMPREPLY=($(compgen -W "programmes channels" -- "$cur")); return ;;
+	esac
+	if [[ "$cur" == -* ]]; then
+		COMPREPLY=($(compgen -W "%s" -- "$cur"))
+	else
+		COMPREPLY=($(compgen -W "%s" -- "$cur"))
+	fi
+}
+complete -o default -F _epgtool epgtool
+`, "-"+strings.Join(flags, " -"), strings.Join(names, " "))
+	case "fish":
+		for _, c := range commands {
+			fmt.Printf("complete -c epgtool -n __fish_use_subcommand -f -a %s -d %q\n", c.name, c.summary)
+		}
+		fmt.Println(`complete -c epgtool -n "__fish_seen_subcommand_from channels" -f -a "validate playlist"`)
+		fmt.Println(`complete -c epgtool -n "__fish_seen_subcommand_from completion" -f -a "bash zsh fish"`)
+		fmt.Println(`complete -c epgtool -n "__fish_seen_subcommand_from schema" -f -a "programmes channels"`)
+		flag.VisitAll(func(f *flag.Flag) {
+			_, usage := flag.UnquoteUsage(f)
+			fmt.Printf("complete -c epgtool -o %s -d %q\n", f.Name, usage)
+		})
+	default:
+		fmt.Fprintf(os.Stderr, "unsupported shell '%s', expected bash, zsh or fish\n", args[0])
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// scheduledEvent is a programme accepted in the channel timeline together
+// with its parsed, and possibly adjusted, start and end times.
+type scheduledEvent struct {
+	programme
+	ID    string
+	Title title
+	Start time.Time
+	End   time.Time
+	// GroupID is the ID of the first part of a programme split into parts.
+	GroupID string
+}
+
+// scheduledEvents reuses the buffers of the accepted events between the
+// conversions of channels, which dominate the allocations of big runs.
+var scheduledEvents = sync.Pool{New: func() interface{} { return new([]scheduledEvent) }}
+
+func convertChannel(channel requestedChannel, events []programme, ids map[string]programme, rules []*rule,
+	report *runReport) (*outputChannel, error) {
+	outputChannel := &outputChannel{Events: outputEvents{Values: make([]outputEvent, 0, len(events))}}
+	outputChannel.ID = channel.ID
+	outputChannel.Name = channel.Name
+	spans := make(timeline, 0, len(events))
+	eventByStartTime := make(map[string]programme, len(events))
+	opts := channel.Options
+	if opts.OverlapStrategy == "last" {
+		reversed := make([]programme, len(events))
+		for i, e := range events {
+			reversed[len(events)-1-i] = e
+		}
+		events = reversed
+	}
+	now := time.Now()
+	buf := scheduledEvents.Get().(*[]scheduledEvent)
+	accepted := (*buf)[:0]
+	defer func() {
+		for i := range accepted {
+			accepted[i] = scheduledEvent{}
+		}
+		*buf = accepted[:0]
+		scheduledEvents.Put(buf)
+	}()
+	for _, event := range events {
+		if !opts.acceptsCategories(event.Category) {
+			continue
+		}
+
+		startTime, err := parseSourceTime(event.Start)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse start time due: %v", err)
+		}
+		endTime, err := parseSourceTime(event.Stop)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse start time due: %v", err)
+		}
+		startTime, endTime = startTime.Add(opts.Offset), endTime.Add(opts.Offset)
+
+		if *snapTimes > 0 {
+			snappedStart, snappedEnd := startTime.Round(*snapTimes), endTime.Round(*snapTimes)
+			if absDuration(snappedStart.Sub(startTime)) > *snapReport || absDuration(snappedEnd.Sub(endTime)) > *snapReport {
+				report.adjusted("snapped", channel, event, snappedStart, snappedEnd)
+			}
+			startTime, endTime = snappedStart, snappedEnd
+		}
+
+		if opts.Window > 0 && (!endTime.After(now) || startTime.After(now.Add(opts.Window))) {
+			continue
+		}
+
+		id := fmt.Sprintf("%d", startTime.UTC().Unix())
+		idc := fmt.Sprintf("%s-%s", id, event.ChannelName)
+
+		v, ok := ids[idc]
+		if !ok {
+			ids[idc] = event
+		} else {
+			if v.ChannelName == event.ChannelName {
+				continue
+			}
+		}
+
+		var t = event.Title[0]
+
+		for i, title := range event.Title {
+			if title.Lang == opts.Lang {
+				t = event.Title[i]
+			}
+		}
+
+		overlaps := spans.intersections(span{start: startTime, end: endTime})
+
+		if len(overlaps) > 0 {
+			if tolerantStart, tolerantEnd, ok := tolerateOverlaps(overlaps, startTime, endTime, *overlapTolerance); ok {
+				startTime, endTime = tolerantStart, tolerantEnd
+				id = fmt.Sprintf("%d", startTime.UTC().Unix())
+			} else if clippedStart, clippedEnd, ok := clipOverlap(overlaps, startTime, endTime, opts.RepairThreshold); ok {
+				report.adjusted("repaired overlap", channel, event, clippedStart, clippedEnd)
+				startTime, endTime = clippedStart, clippedEnd
+				id = fmt.Sprintf("%d", startTime.UTC().Unix())
+			} else {
+				var existing *programme
+				if e, ok := eventByStartTime[endTime.UTC().Format(outDateLayout)]; ok {
+					existing = &e
+				}
+				report.collision(channel, event, existing)
+				continue
+			}
+		}
+		spans.insert(span{start: startTime, end: endTime})
+
+		eventByStartTime[endTime.UTC().Format(outDateLayout)] = event
+		accepted = append(accepted, scheduledEvent{programme: event, ID: id, Title: t, Start: startTime, End: endTime})
+	}
+
+	if opts.RepairThreshold > 0 {
+		closeGaps(channel, accepted, opts.RepairThreshold, report)
+	}
+	if opts.Parts != "off" {
+		accepted = linkParts(channel, accepted, opts.Parts, *partGap, report)
+	}
+
+	var cleanup textCleanup
+	for _, e := range accepted {
+		o := newOutputEvent(e)
+		o.Perex = shortPerex(o.Description, opts.Perex)
+		if *normalizeText {
+			cleanup.cleanEvent(&o)
+		}
+		o.Name = normalizeTitle(o.Name, opts.TitleCase, opts.Acronyms)
+		if opts.Translit != "off" {
+			transliterateEvent(&o, opts.Translit, opts.TranslitFields)
+		}
+		if opts.Sports {
+			o.Sports = parseSportsTitle(o.Name)
+		}
+		o, keep, err := loadedPlugin.transformEvent(channel.ID, o)
+		if err != nil {
+			return nil, err
+		}
+		if !keep {
+			continue
+		}
+		if o, keep, err = applyRules(rules, channel.ID, o); err != nil {
+			return nil, err
+		}
+		if !keep {
+			continue
+		}
+		outputChannel.Events.Values = append(outputChannel.Events.Values, o)
+	}
+	if cleanup != (textCleanup{}) {
+		report.cleaned(channel, cleanup)
+	}
+	return outputChannel, nil
+}
+
+// clipOverlap shortens an event which overlaps a single already accepted
+// event by no more than threshold, so that both become adjacent.
+func clipOverlap(overlaps []span, start, end time.Time, threshold time.Duration) (time.Time, time.Time, bool) {
+	if threshold <= 0 || len(overlaps) != 1 {
+		return start, end, false
+	}
+	o := overlaps[0]
+	if o.end.Sub(o.start) > threshold {
+		return start, end, false
+	}
+	switch {
+	case o.start.Equal(start) && o.end.Before(end):
+		return o.end, end, true
+	case o.end.Equal(end) && o.start.After(start):
+		return start, o.start, true
+	}
+	return start, end, false
+}
+
+// tolerateOverlaps shortens an event overlapping the accepted events at its
+// start, its end or both by no more than tolerance each, as providers
+// rounding the times do, so that it becomes adjacent to them.
+func tolerateOverlaps(overlaps []span, start, end time.Time, tolerance time.Duration) (time.Time, time.Time, bool) {
+	if tolerance <= 0 || len(overlaps) > 2 {
+		return start, end, false
+	}
+	newStart, newEnd := start, end
+	for _, o := range overlaps {
+		switch {
+		case o.end.Sub(o.start) > tolerance:
+			return start, end, false
+		case o.start.Equal(start) && o.end.Before(end):
+			newStart = o.end
+		case o.end.Equal(end) && o.start.After(start):
+			newEnd = o.start
+		default:
+			return start, end, false
+		}
+	}
+	if !newStart.Before(newEnd) {
+		return start, end, false
+	}
+	return newStart, newEnd, true
+}
+
+// closeGaps stretches the end of an event up to the start of the next one
+// when the gap between them does not exceed threshold.
+func closeGaps(channel requestedChannel, events []scheduledEvent, threshold time.Duration, report *runReport) {
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Start.Before(events[j].Start) })
+	for i := 1; i < len(events); i++ {
+		prev, next := &events[i-1], events[i]
+		gap := next.Start.Sub(prev.End)
+		if gap <= 0 || gap > threshold {
+			continue
+		}
+		report.gap(channel, prev.End, next.Start)
+		prev.End = next.Start
+	}
+}
+
+func newOutputEvent(e scheduledEvent) outputEvent {
+	productionYear, originalAirDate := parseProgrammeDate(e.Date)
+	outputEvent := outputEvent{
+		ID:                  e.ID,
+		Name:                e.Title.Name,
+		StartTime:           e.Start.UTC().Format(outDateLayout),
+		EndTime:             e.End.UTC().Format(outDateLayout),
+		Perex:               e.Description.Name,
+		Description:         e.Description.Name,
+		ProductionYear:      productionYear,
+		OriginalAirDate:     originalAirDate,
+		ProductionCountries: strings.Join(e.Country, ", "),
+		VideoQuality:        strings.TrimSpace(e.Video.Quality),
+		VideoAspect:         strings.TrimSpace(e.Video.Aspect),
+		Audio:               strings.TrimSpace(e.Audio.Stereo),
+		CRID:                e.episodeNumber("crid"),
+		ProgramID:           e.episodeNumber("dd_progid"),
+		Tags:                eventTags(e.programme),
+		GroupID:             e.GroupID,
+	}
+
+	if actors, directors := normalizedPeople(e.Credits.Actors), normalizedPeople(e.Credits.Producers); *peopleFormat == "list" {
+		outputEvent.ActorList, outputEvent.DirectorList = actors, directors
+	} else {
+		outputEvent.Actors, outputEvent.Directors = strings.Join(actors, ", "), strings.Join(directors, ", ")
+	}
+	if *countryCodes {
+		outputEvent.ProductionCountries = strings.Join(normalizedCountries(e.Country), ", ")
+	}
+	if *timeFields != "end" {
+		duration := int64(e.End.Sub(e.Start) / time.Second)
+		outputEvent.Duration = &duration
+	}
+	if *timeFields == "duration" {
+		outputEvent.EndTime = ""
+	}
+	if *dateLocale != "" {
+		outputEvent.LocalDate = formatLocalDate(e.Start, *dateFormat, *dateLocale)
+	}
+
+	if len(e.URL) > 0 {
+		outputEvent.URL = strings.TrimSpace(e.URL[0])
+	}
+
+	if *provenance {
+		outputEvent.SourceFile = e.SourceFile
+		outputEvent.Provider = e.Provider
+	}
+	return outputEvent
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConvertChannel(t *testing.T) {
+	prog := func(start, stop, name string, categories ...string) programme {
+		p := programme{Start: "20240101" + start + " +0000", Stop: "20240101" + stop + " +0000", ChannelName: "Channel",
+			Title: []title{{Name: name}}}
+		for _, c := range categories {
+			p.Category = append(p.Category, title{Name: c})
+		}
+		return p
+	}
+	tests := []struct {
+		name      string
+		snap      time.Duration
+		tolerance time.Duration
+		options   func(o *channelOptions)
+		events    []programme
+		want      []string
+	}{
+		{"adjacent", 0, 0, nil,
+			[]programme{prog("180000", "183000", "News"), prog("183000", "190000", "Film")},
+			[]string{"18:00-18:30 News", "18:30-19:00 Film"}},
+		{"snapped", 5 * time.Minute, 0, nil,
+			[]programme{prog("175940", "182950", "News"), prog("182950", "190210", "Film")},
+			[]string{"18:00-18:30 News", "18:30-19:00 Film"}},
+		{"collision keeps first", 0, 0, nil,
+			[]programme{prog("180000", "190000", "News"), prog("183000", "193000", "Film")},
+			[]string{"18:00-19:00 News"}},
+		{"collision keeps last", 0, 0, func(o *channelOptions) { o.OverlapStrategy = "last" },
+			[]programme{prog("180000", "190000", "News"), prog("183000", "193000", "Film")},
+			[]string{"18:30-19:30 Film"}},
+		{"overlap tolerated", 0, 2 * time.Minute, nil,
+			[]programme{prog("180000", "183100", "News"), prog("183000", "190000", "Film")},
+			[]string{"18:00-18:31 News", "18:31-19:00 Film"}},
+		{"overlap over tolerance", 0, time.Minute, nil,
+			[]programme{prog("180000", "183200", "News"), prog("183000", "190000", "Film")},
+			[]string{"18:00-18:32 News"}},
+		{"overlap repaired", 0, 0, func(o *channelOptions) { o.RepairThreshold = 2 * time.Minute },
+			[]programme{prog("180000", "183100", "News"), prog("183000", "190000", "Film")},
+			[]string{"18:00-18:31 News", "18:31-19:00 Film"}},
+		{"gap repaired", 0, 0, func(o *channelOptions) { o.RepairThreshold = 2 * time.Minute },
+			[]programme{prog("180000", "182900", "News"), prog("183000", "190000", "Film")},
+			[]string{"18:00-18:30 News", "18:30-19:00 Film"}},
+		{"gap over threshold", 0, 0, func(o *channelOptions) { o.RepairThreshold = 2 * time.Minute },
+			[]programme{prog("180000", "182500", "News"), prog("183000", "190000", "Film")},
+			[]string{"18:00-18:25 News", "18:30-19:00 Film"}},
+		{"included categories", 0, 0, func(o *channelOptions) { o.IncludeCategories = []string{"sport"} },
+			[]programme{prog("180000", "183000", "News", "News"), prog("183000", "190000", "Match", "Sport")},
+			[]string{"18:30-19:00 Match"}},
+		{"excluded categories", 0, 0, func(o *channelOptions) { o.ExcludeCategories = []string{"News"} },
+			[]programme{prog("180000", "183000", "News", "News"), prog("183000", "190000", "Match", "Sport")},
+			[]string{"18:30-19:00 Match"}},
+		{"parts grouped", 0, 0, func(o *channelOptions) { o.Parts = "group" },
+			[]programme{prog("180000", "190000", "Film, Part 1"), prog("190000", "191000", "News"),
+				prog("191000", "200000", "Film, Part 2")},
+			[]string{"18:00-19:00 Film, Part 1 group 1704132000", "19:00-19:10 News",
+				"19:10-20:00 Film, Part 2 group 1704132000"}},
+		{"parts merged", 0, 0, func(o *channelOptions) { o.Parts = "merge" },
+			[]programme{prog("180000", "190000", "Film (1/2)"), prog("190000", "191000", "News"),
+				prog("191000", "200000", "Film (2/2)")},
+			[]string{"18:00-20:00 Film"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prevSnap, prevTolerance := *snapTimes, *overlapTolerance
+			*snapTimes, *overlapTolerance = tt.snap, tt.tolerance
+			defer func() { *snapTimes, *overlapTolerance = prevSnap, prevTolerance }()
+
+			channel := requestedChannel{ID: "1", Name: "Channel", Options: globalChannelOptions()}
+			if tt.options != nil {
+				tt.options(&channel.Options)
+			}
+			c, err := convertChannel(channel, tt.events, make(map[string]programme), nil, newRunReport(ioutil.Discard))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, e := range c.Events.Values {
+				s := fmt.Sprintf("%s-%s %s", e.StartTime[11:16], e.EndTime[11:16], e.Name)
+				if e.GroupID != "" {
+					s += " group " + e.GroupID
+				}
+				got = append(got, s)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// benchmarkSource returns an XMLTV source with consecutive half-hour
+// programmes of the channels "Channel 0" to "Channel <channels-1>".
+func benchmarkSource(channels, events int) string {
+	var b strings.Builder
+	b.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<tv>\n")
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < events; i++ {
+		start, stop := base.Add(time.Duration(i)*30*time.Minute), base.Add(time.Duration(i+1)*30*time.Minute)
+		for c := 0; c < channels; c++ {
+			fmt.Fprintf(&b, "<programme start=\"%s\" stop=\"%s\" channel=\"Channel %d\"><title>Show %d</title>"+
+				"<desc>Episode %d of the show</desc></programme>\n", start.Format(inDateLayout), stop.Format(inDateLayout), c, i%40, i)
+		}
+	}
+	b.WriteString("</tv>\n")
+	return b.String()
+}
+
+// benchmarkProgrammes returns n consecutive half-hour programmes of a channel.
+func benchmarkProgrammes(n int) []programme {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	events := make([]programme, n)
+	for i := range events {
+		events[i] = programme{
+			Start:       base.Add(time.Duration(i) * 30 * time.Minute).Format(inDateLayout),
+			Stop:        base.Add(time.Duration(i+1) * 30 * time.Minute).Format(inDateLayout),
+			ChannelName: "Channel",
+			Title:       []title{{Name: fmt.Sprintf("Show %d", i%40)}},
+			Description: title{Name: fmt.Sprintf("Episode %d of the show", i)},
+		}
+	}
+	return events
+}
+
+// BenchmarkConvertChannels converts 10 channels of 2000 events one after the
+// other as a run does, once reusing the accepted event buffers through
+// scheduledEvents and once with an empty pool for every channel, which
+// allocates the buffers like the conversion did before pooling them:
+//
+//	go test -run - -bench ConvertChannels -benchmem
+func BenchmarkConvertChannels(b *testing.B) {
+	const channels = 10
+	events := benchmarkProgrammes(2000)
+	channel := requestedChannel{ID: "1", Name: "Channel"}
+	report := newRunReport(ioutil.Discard)
+	convertAll := func(b *testing.B, pooled bool) {
+		for c := 0; c < channels; c++ {
+			if !pooled {
+				scheduledEvents = sync.Pool{New: func() interface{} { return new([]scheduledEvent) }}
+			}
+			if _, err := convertChannel(channel, events, make(map[string]programme), nil, report); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	for _, pooled := range []bool{true, false} {
+		name := "pooled"
+		if !pooled {
+			name = "unpooled"
+		}
+		b.Run(name, func(b *testing.B) {
+			defer func() { scheduledEvents = sync.Pool{New: func() interface{} { return new([]scheduledEvent) }} }()
+			convertAll(b, pooled)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				convertAll(b, pooled)
+			}
+		})
+	}
+}
+
+// BenchmarkConvert measures a whole run over 40 channels of 1000 events each,
+// from parsing the source to writing the output files.
+func BenchmarkConvert(b *testing.B) {
+	const channels, events = 40, 1000
+	dir := b.TempDir()
+	var lines []string
+	for c := 0; c < channels; c++ {
+		lines = append(lines, fmt.Sprintf("%d,\"Channel %d\"", c+1, c))
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "channels.csv"), []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		b.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "data"), 0755); err != nil {
+		b.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "data", "source.xml"), []byte(benchmarkSource(channels, events)), 0644); err != nil {
+		b.Fatal(err)
+	}
+	j := job{DataDir: filepath.Join(dir, "data"), SourceFileLimit: 1, ChannelsFile: filepath.Join(dir, "channels.csv"),
+		OutputDir: filepath.Join(dir, "out")}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := j.run(newSourceCache(), newRunReport(ioutil.Discard)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
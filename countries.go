@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// countryNames lists the ISO 3166-1 alpha-2 codes with their alpha-3 code and
+// the names used by the providers, in Bulgarian, English, German and Russian.
+var countryNames = []struct {
+	code  string
+	names []string
+}{
+	{"AR", []string{"ARG", "Аржентина", "Argentina", "Argentinien"}},
+	{"AT", []string{"AUT", "Австрия", "Austria", "Österreich"}},
+	{"AU", []string{"AUS", "Австралия", "Australia", "Australien"}},
+	{"BE", []string{"BEL", "Белгия", "Belgium", "Belgien", "Бельгия"}},
+	{"BG", []string{"BGR", "България", "Bulgaria", "Bulgarien", "Болгария"}},
+	{"BR", []string{"BRA", "Бразилия", "Brazil", "Brasilien"}},
+	{"CA", []string{"CAN", "Канада", "Canada", "Kanada"}},
+	{"CH", []string{"CHE", "Швейцария", "Switzerland", "Schweiz"}},
+	{"CL", []string{"CHL", "Чили", "Chile"}},
+	{"CN", []string{"CHN", "Китай", "China"}},
+	{"CO", []string{"COL", "Колумбия", "Colombia", "Kolumbien"}},
+	{"CY", []string{"CYP", "Кипър", "Cyprus", "Zypern", "Кипр"}},
+	{"CZ", []string{"CZE", "Чехия", "Czech Republic", "Czechia", "Tschechien", "Чешка република"}},
+	{"DE", []string{"DEU", "Германия", "Germany", "Deutschland"}},
+	{"DK", []string{"DNK", "Дания", "Denmark", "Dänemark"}},
+	{"EE", []string{"EST", "Естония", "Estonia", "Estland", "Эстония"}},
+	{"EG", []string{"EGY", "Египет", "Egypt", "Ägypten"}},
+	{"ES", []string{"ESP", "Испания", "Spain", "Spanien"}},
+	{"FI", []string{"FIN", "Финландия", "Finland", "Finnland", "Финляндия"}},
+	{"FR", []string{"FRA", "Франция", "France", "Frankreich"}},
+	{"GB", []string{"GBR", "UK", "Великобритания", "Обединеното кралство", "United Kingdom", "Great Britain",
+		"England", "Англия", "Großbritannien"}},
+	{"GE", []string{"GEO", "Грузия", "Georgia", "Georgien"}},
+	{"GR", []string{"GRC", "Гърция", "Greece", "Griechenland", "Греция"}},
+	{"HK", []string{"HKG", "Хонконг", "Hong Kong", "Гонконг"}},
+	{"HR", []string{"HRV", "Хърватия", "Croatia", "Kroatien", "Хорватия"}},
+	{"HU", []string{"HUN", "Унгария", "Hungary", "Ungarn", "Венгрия"}},
+	{"IE", []string{"IRL", "Ирландия", "Ireland", "Irland"}},
+	{"IL", []string{"ISR", "Израел", "Israel", "Израиль"}},
+	{"IN", []string{"IND", "Индия", "India", "Indien"}},
+	{"IR", []string{"IRN", "Иран", "Iran"}},
+	{"IS", []string{"ISL", "Исландия", "Iceland", "Island"}},
+	{"IT", []string{"ITA", "Италия", "Italy", "Italien"}},
+	{"JP", []string{"JPN", "Япония", "Japan"}},
+	{"KR", []string{"KOR", "Южна Корея", "Корея", "South Korea", "Korea", "Südkorea", "Южная Корея"}},
+	{"LT", []string{"LTU", "Литва", "Lithuania", "Litauen"}},
+	{"LU", []string{"LUX", "Люксембург", "Luxembourg", "Luxemburg"}},
+	{"LV", []string{"LVA", "Латвия", "Latvia", "Lettland"}},
+	{"MD", []string{"MDA", "Молдова", "Moldova", "Moldau", "Молдавия"}},
+	{"MK", []string{"MKD", "Северна Македония", "Македония", "North Macedonia", "Macedonia", "Nordmazedonien"}},
+	{"MX", []string{"MEX", "Мексико", "Mexico", "Mexiko", "Мексика"}},
+	{"NL", []string{"NLD", "Нидерландия", "Холандия", "Netherlands", "Holland", "Niederlande", "Нидерланды"}},
+	{"NO", []string{"NOR", "Норвегия", "Norway", "Norwegen"}},
+	{"NZ", []string{"NZL", "Нова Зеландия", "New Zealand", "Neuseeland", "Новая Зеландия"}},
+	{"PL", []string{"POL", "Полша", "Poland", "Polen", "Польша"}},
+	{"PT", []string{"PRT", "Португалия", "Portugal"}},
+	{"RO", []string{"ROU", "Румъния", "Romania", "Rumänien", "România", "Румыния"}},
+	{"RS", []string{"SRB", "Сърбия", "Serbia", "Serbien", "Сербия"}},
+	{"RU", []string{"RUS", "Русия", "Russia", "Russland", "Россия"}},
+	{"SE", []string{"SWE", "Швеция", "Sweden", "Schweden"}},
+	{"SI", []string{"SVN", "Словения", "Slovenia", "Slowenien"}},
+	{"SK", []string{"SVK", "Словакия", "Slovakia", "Slowakei"}},
+	{"TH", []string{"THA", "Тайланд", "Thailand", "Таиланд"}},
+	{"TR", []string{"TUR", "Турция", "Turkey", "Türkei", "Türkiye"}},
+	{"TW", []string{"TWN", "Тайван", "Taiwan", "Тайвань"}},
+	{"UA", []string{"UKR", "Украйна", "Ukraine", "Украина"}},
+	{"US", []string{"USA", "САЩ", "United States", "United States of America", "America", "Vereinigte Staaten",
+		"США"}},
+	{"ZA", []string{"ZAF", "Южна Африка", "ЮАР", "South Africa", "Südafrika"}},
+}
+
+// countryIndex maps the names and codes of countryNames, normalized by
+// countryKey, to their alpha-2 code. The -countryCodesFile overrides are
+// added to it.
+var countryIndex = func() map[string]string {
+	result := make(map[string]string)
+	for _, c := range countryNames {
+		result[countryKey(c.code)] = c.code
+		for _, name := range c.names {
+			result[countryKey(name)] = c.code
+		}
+	}
+	return result
+}()
+
+// countryKey lowercases the name and drops its dots and surrounding spaces,
+// so "U.S.A." matches "USA".
+func countryKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(strings.ReplaceAll(name, ".", "")))
+}
+
+// readCountryOverrides adds the name,code rows of the CSV file to
+// countryIndex.
+func readCountryOverrides(fileName string) error {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return fmt.Errorf("unable to open country codes file due: %v", err)
+	}
+	defer f.Close()
+	cr := csv.NewReader(f)
+	cr.FieldsPerRecord = 2
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("unable to parse country codes file due: %v", err)
+		}
+		code := strings.ToUpper(strings.TrimSpace(rec[1]))
+		if len(code) != 2 {
+			return fmt.Errorf("invalid country code '%s' for '%s', expected an ISO 3166-1 alpha-2 code", rec[1], rec[0])
+		}
+		countryIndex[countryKey(rec[0])] = code
+	}
+}
+
+// normalizedCountries maps the countries, which may list several names
+// separated by commas or slashes, to their alpha-2 codes, dropping repeated
+// ones. Unknown names are kept as they are.
+func normalizedCountries(countries []string) []string {
+	var result []string
+	for _, c := range countries {
+		for _, name := range strings.FieldsFunc(c, func(r rune) bool { return r == ',' || r == '/' }) {
+			name = strings.TrimSpace(name)
+			if code, ok := countryIndex[countryKey(name)]; ok {
+				name = code
+			}
+			if name != "" && !containsString(result, name) {
+				result = append(result, name)
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizedCountries(t *testing.T) {
+	tests := []struct {
+		name      string
+		countries []string
+		want      []string
+	}{
+		{"none", nil, nil},
+		{"code", []string{"DE"}, []string{"DE"}},
+		{"alpha-3", []string{"GBR"}, []string{"GB"}},
+		{"english", []string{"United States"}, []string{"US"}},
+		{"bulgarian", []string{"България"}, []string{"BG"}},
+		{"german umlaut", []string{"Österreich"}, []string{"AT"}},
+		{"case and dots", []string{"u.s.a."}, []string{"US"}},
+		{"separated", []string{"Germany, France / Italy"}, []string{"DE", "FR", "IT"}},
+		{"repeated", []string{"USA", "United States of America", "США"}, []string{"US"}},
+		{"unknown kept", []string{"Atlantis", "Germany"}, []string{"Atlantis", "DE"}},
+		{"empty names dropped", []string{" , /Germany"}, []string{"DE"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizedCountries(tt.countries); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountryNamesUnique(t *testing.T) {
+	seen := make(map[string]string)
+	for _, c := range countryNames {
+		for _, name := range append([]string{c.code}, c.names...) {
+			if code, ok := seen[countryKey(name)]; ok && code != c.code {
+				t.Errorf("'%s' names both %s and %s", name, code, c.code)
+			}
+			seen[countryKey(name)] = c.code
+		}
+	}
+}
+
+func TestReadCountryOverrides(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		lookup  string
+		want    string
+		wantErr string
+	}{
+		{"new name", "Atlantis,at\n", "atlantis", "AT", ""},
+		{"replaced name", "\"Holland\", be\n", "Holland", "BE", ""},
+		{"invalid code", "Atlantis,ATL\n", "", "", "invalid country code 'ATL' for 'Atlantis'"},
+		{"missing code", "Atlantis\n", "", "", "unable to parse country codes file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prev := make(map[string]string)
+			for k, v := range countryIndex {
+				prev[k] = v
+			}
+			defer func() { countryIndex = prev }()
+
+			fileName := filepath.Join(t.TempDir(), "countries.csv")
+			if err := ioutil.WriteFile(fileName, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			err := readCountryOverrides(fileName)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := normalizedCountries([]string{tt.lookup}); !reflect.DeepEqual(got, []string{tt.want}) {
+				t.Errorf("got %q, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if err := readCountryOverrides(filepath.Join(t.TempDir(), "missing.csv")); err == nil {
+		t.Error("read a missing country codes file")
+	}
+}
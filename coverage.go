@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseCoverage parses a minimum coverage, a Go duration such as 36h or a
+// number of days such as 2d.
+func parseCoverage(v string) (time.Duration, error) {
+	if strings.HasSuffix(v, "d") {
+		days, err := strconv.ParseFloat(strings.TrimSuffix(v, "d"), 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid coverage '%s'", v)
+		}
+		return time.Duration(days * float64(24*time.Hour)), nil
+	}
+	return time.ParseDuration(v)
+}
+
+// upcomingCoverage sums the durations of the channel events after now.
+func upcomingCoverage(c *outputChannel, now time.Time) time.Duration {
+	var total time.Duration
+	for _, e := range c.Events.Values {
+		start, end, err := eventTimes(e)
+		if err != nil || !end.After(now) {
+			continue
+		}
+		if start.Before(now) {
+			start = now
+		}
+		total += end.Sub(start)
+	}
+	return total
+}
+
+// coverageRegression is a channel whose new events cover less than its
+// minimum coverage, so its previous output was published again.
+type coverageRegression struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Coverage string `json:"coverage"`
+	Previous string `json:"previous"`
+	Minimum  string `json:"minimum"`
+}
+
+// keepCoveredOutput replaces the events of the converted channels covering
+// less than their minimum coverage after now with the ones of their current
+// output file, as long as that file still covers the minimum, so a
+// near-empty correction of a provider doesn't overwrite a good schedule.
+func keepCoveredOutput(t tenant, dir string, summary *tenantSummary, report *runReport, now time.Time) {
+	for i, c := range summary.converted {
+		min := c.channel.Options.MinCoverage
+		if min <= 0 {
+			continue
+		}
+		coverage := upcomingCoverage(c.output, now)
+		if coverage >= min {
+			continue
+		}
+		prev, err := readPreviousOutput(t, dir, c.channel)
+		if err != nil {
+			continue
+		}
+		prevCoverage := upcomingCoverage(prev, now)
+		if prevCoverage < min {
+			continue
+		}
+		r := coverageRegression{ID: c.channel.ID, Name: c.channel.Name, Coverage: coverage.Round(time.Minute).String(),
+			Previous: prevCoverage.Round(time.Minute).String(), Minimum: min.String()}
+		report.coverage(c.channel, fmt.Sprintf("new events cover %s, below the minimum of %s, kept the previous "+
+			"output covering %s", r.Coverage, r.Minimum, r.Previous))
+		summary.CoverageRegressions = append(summary.CoverageRegressions, r)
+		summary.converted[i].output = prev
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// runDeadline aborts a run which did not start writing its output within the
+// time budget. The conversion is cancelled and stops without touching the
+// output.
+type runDeadline struct {
+	mu      sync.Mutex
+	limit   time.Duration
+	stage   string
+	writing bool
+	expired bool
+}
+
+// enter records the stage the run is in for the abort message.
+func (d *runDeadline) enter(stage string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.stage = stage
+}
+
+// startWriting reports an error when the run was aborted. Once the output is
+// being written the deadline no longer aborts the run.
+func (d *runDeadline) startWriting() error {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.expired {
+		return d.err()
+	}
+	d.writing = true
+	return nil
+}
+
+// expire aborts the run unless it is already writing its output.
+func (d *runDeadline) expire() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.writing {
+		return nil
+	}
+	d.expired = true
+	return d.err()
+}
+
+func (d *runDeadline) err() error {
+	return fmt.Errorf("run exceeded the deadline of %v while %s, no output was written and the previous output is kept",
+		d.limit, d.stage)
+}
+
+// convertWithin converts the job, cancelling it when the output is not being
+// written before the deadline. It returns once the cancelled conversion
+// stopped, which is after the source file or channel being converted.
+func (j job) convertWithin(limit time.Duration, cache *sourceCache, report *runReport) (*runSummary, error) {
+	if limit <= 0 {
+		return j.convert(context.Background(), cache, report, nil)
+	}
+	type result struct {
+		summary *runSummary
+		err     error
+	}
+	d := &runDeadline{limit: limit, stage: "starting"}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan result, 1)
+	go func() {
+		summary, err := j.convert(ctx, cache, report, d)
+		done <- result{summary, err}
+	}()
+
+	timer := time.NewTimer(limit)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.summary, r.err
+	case <-timer.C:
+		if err := d.expire(); err != nil {
+			cancel()
+			<-done
+			return nil, err
+		}
+		r := <-done
+		return r.summary, r.err
+	}
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mgenov/epgtool/store"
+)
+
+// destination is a remote target the output files are uploaded to after a
+// successful run: an http(s) URL the files are PUT below, s3://bucket/prefix
+// or sftp://user@host/dir.
+type destination struct {
+	URL string `json:"url"`
+	// Retries overrides -fetchRetries for the destination.
+	Retries *int `json:"retries,omitempty"`
+	// Format and Channels select the output format and the channel IDs the
+	// destination receives instead of the written output files.
+	Format   string   `json:"format,omitempty"`
+	Channels []string `json:"channels,omitempty"`
+}
+
+// written reports whether the destination receives the written output files.
+func (d destination) written() bool {
+	return (d.Format == "" || d.Format == *outputFormat) && len(d.Channels) == 0
+}
+
+func deliverDestinations() []destination {
+	var result []destination
+	for _, u := range deliverURLs {
+		result = append(result, destination{URL: u})
+	}
+	return result
+}
+
+// deliveryResult is the outcome of uploading the files to a destination.
+type deliveryResult struct {
+	Destination string   `json:"destination"`
+	Delivered   int      `json:"delivered"`
+	Failed      []string `json:"failed,omitempty"`
+}
+
+// deliverOutput delivers the output of the published tenants to every
+// destination. Destinations with their own format or channels get files
+// generated from the converted channels into a temporary directory.
+func deliverOutput(destinations []destination, outputDir string, tenants []*tenantSummary, comment string) []deliveryResult {
+	var written []string
+	for _, ts := range tenants {
+		written = append(written, ts.files...)
+	}
+	results := make([]deliveryResult, 0, len(destinations))
+	for _, d := range destinations {
+		if d.written() {
+			results = append(results, deliver(d, outputDir, written))
+			continue
+		}
+		dir, files, err := stageDestination(d, tenants, comment)
+		if err != nil {
+			results = append(results, deliveryResult{Destination: redactURL(d.URL), Failed: []string{err.Error()}})
+			continue
+		}
+		results = append(results, deliver(d, dir, files))
+		os.RemoveAll(dir)
+	}
+	return results
+}
+
+// stageDestination writes the channels of the destination in its format into
+// a temporary directory, returning it and the files relative to it.
+func stageDestination(d destination, tenants []*tenantSummary, comment string) (string, []string, error) {
+	dir, err := ioutil.TempDir("", "epgtool-deliver")
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to create delivery directory due: %v", err)
+	}
+	selected := make(map[string]bool)
+	for _, id := range d.Channels {
+		selected[id] = true
+	}
+	format := d.Format
+	if format == "" {
+		format = *outputFormat
+	}
+
+	var tasks []writeTask
+	for _, ts := range tenants {
+		t := tenant{Name: ts.Name}
+		tenantDir := t.outputDir(dir)
+		if err := mkdirAll(tenantDir); err != nil {
+			os.RemoveAll(dir)
+			return "", nil, fmt.Errorf("unable to create delivery directory due: %v", err)
+		}
+		for _, c := range ts.converted {
+			if len(selected) > 0 && !selected[c.channel.ID] {
+				continue
+			}
+			fileName, encode := channelFile(t, format, tenantDir, comment, c)
+			tasks = append(tasks, writeTask{channel: c.channel, fileName: fileName, encode: encode})
+		}
+	}
+	encodeFiles(tasks, *writeWorkers)
+	writeFiles(tasks, *writeWorkers)
+
+	var files []string
+	for _, task := range tasks {
+		if task.err != nil {
+			os.RemoveAll(dir)
+			return "", nil, fmt.Errorf("could not write to delivery file '%s' due: %v", task.fileName, task.err)
+		}
+		rel, _ := filepath.Rel(dir, task.fileName)
+		files = append(files, rel)
+		if *signOutput {
+			files = append(files, rel+".sig")
+		}
+	}
+	return dir, files, nil
+}
+
+// deliver uploads the files, given relative to dir, to the destination,
+// retrying every file with exponential backoff.
+func deliver(d destination, dir string, files []string) deliveryResult {
+	result := deliveryResult{Destination: redactURL(d.URL)}
+	retries := *fetchRetries
+	if d.Retries != nil {
+		retries = *d.Retries
+	}
+	if u, err := url.Parse(d.URL); err == nil && u.Scheme == "sftp" {
+		// A single sftp session uploads all files, so the batch is retried
+		// as a whole.
+		if err := retry(retries, func() (bool, error) { return true, sftpPut(u, dir, files) }); err != nil {
+			result.Failed = append(result.Failed, err.Error())
+		} else {
+			result.Delivered = len(files)
+		}
+		log.Printf("delivered %d of %d files to %s", result.Delivered, len(files), result.Destination)
+		return result
+	}
+	upload, err := uploader(d.URL)
+	if err != nil {
+		result.Failed = append(result.Failed, err.Error())
+		return result
+	}
+	for _, name := range files {
+		err := retry(retries, func() (bool, error) { return upload(filepath.Join(dir, name), filepath.ToSlash(name)) })
+		if err != nil {
+			result.Failed = append(result.Failed, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		result.Delivered++
+	}
+	log.Printf("delivered %d of %d files to %s", result.Delivered, len(files), result.Destination)
+	return result
+}
+
+// retry calls f until it succeeds, fails permanently or was retried the given
+// number of times, waiting -fetchBackoff doubled on every attempt.
+func retry(retries int, f func() (bool, error)) error {
+	backoff := *fetchBackoff
+	for attempt := 0; ; attempt++ {
+		temporary, err := f()
+		if err == nil || !temporary || attempt >= retries {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func redactURL(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		return u.Redacted()
+	}
+	return rawURL
+}
+
+// uploader returns the function uploading a local file to the given path
+// below the destination, which reports whether a failure is worth retrying.
+// The sftp destinations are uploaded in a batch by sftpPut instead.
+func uploader(rawURL string) (func(fileName, name string) (bool, error), error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid destination '%s' due: %v", redactURL(rawURL), err)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return func(fileName, name string) (bool, error) {
+			target := *u
+			target.Path = path.Join(u.Path, name)
+			return putFile(&target, fileName, nil)
+		}, nil
+	case "s3":
+		return func(fileName, name string) (bool, error) {
+			return putS3(u.Host, path.Join(strings.TrimPrefix(u.Path, "/"), name), fileName)
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported destination '%s', expected http(s)://, s3:// or sftp://", redactURL(rawURL))
+}
+
+// putFile uploads the file with a PUT request, using the user of the URL for
+// basic authentication and sign to add further headers.
+func putFile(u *url.URL, fileName string, sign func(req *http.Request, body []byte)) (bool, error) {
+	body, err := outputFiles.ReadFile(fileName)
+	if err != nil {
+		return false, err
+	}
+	target := *u
+	target.User = nil
+	req, err := http.NewRequest(http.MethodPut, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	if ct := mime.TypeByExtension(path.Ext(fileName)); ct != "" {
+		req.Header.Set("Content-Type", ct)
+	}
+	if u.User != nil {
+		password, _ := u.User.Password()
+		req.SetBasicAuth(u.User.Username(), password)
+	}
+	if sign != nil {
+		sign(req, body)
+	}
+
+	client := &http.Client{Timeout: *fetchTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("unexpected status %s", resp.Status)
+	case resp.StatusCode >= 300:
+		return false, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return false, nil
+}
+
+// putS3 uploads the file into the bucket with a request signed with AWS
+// Signature Version 4, using the credentials and region of the AWS_*
+// environment variables. AWS_ENDPOINT_URL selects an S3 compatible service
+// addressed with path style URLs.
+func putS3(bucket, key, fileName string) (bool, error) {
+	s, err := store.NewS3(bucket, "")
+	if err != nil {
+		return false, err
+	}
+	u, err := s.ObjectURL(key)
+	if err != nil {
+		return false, err
+	}
+	return putFile(u, fileName, s.Sign)
+}
+
+// sftpPut uploads the files, given relative to dir, below the path of the
+// URL in a single session of the sftp command, authenticating with the SSH
+// keys and known hosts of the user running the tool. Every file is put under
+// a temporary name and then renamed, which OpenSSH servers do with the
+// posix-rename extension, replacing the previous file at once so readers never
+// miss it or see it partly written. The sftp command reads local files, so
+// the files of another output store are copied into a temporary directory.
+func sftpPut(u *url.URL, dir string, files []string) error {
+	if _, ok := outputFiles.(store.Local); !ok {
+		local, err := localCopy(dir, files)
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(local)
+		dir = local
+	}
+	args := []string{"-b", "-", "-o", "BatchMode=yes"}
+	if u.Port() != "" {
+		args = append(args, "-P", u.Port())
+	}
+	host := u.Hostname()
+	if u.User != nil {
+		host = u.User.Username() + "@" + host
+	}
+
+	script, err := sftpScript(u.Path, dir, files)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command("sftp", append(args, host)...)
+	cmd.Stdin = strings.NewReader(script)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("sftp failed due: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// localCopy copies the files, given relative to dir, from the output store
+// into a new temporary directory, returning it.
+func localCopy(dir string, files []string) (string, error) {
+	local, err := ioutil.TempDir("", "epgtool-sftp")
+	if err != nil {
+		return "", fmt.Errorf("unable to create sftp directory due: %v", err)
+	}
+	for _, name := range files {
+		data, err := outputFiles.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			if err = os.MkdirAll(filepath.Dir(filepath.Join(local, name)), os.ModePerm); err == nil {
+				err = ioutil.WriteFile(filepath.Join(local, name), data, 0644)
+			}
+		}
+		if err != nil {
+			os.RemoveAll(local)
+			return "", fmt.Errorf("unable to copy '%s' for sftp due: %v", name, err)
+		}
+	}
+	return local, nil
+}
+
+// sftpScript returns the sftp batch uploading the files, given relative to
+// dir, below the remote root. It creates the missing remote directories and
+// puts every file under a hidden temporary name renamed into place.
+func sftpScript(root, dir string, files []string) (string, error) {
+	var script strings.Builder
+	created := make(map[string]bool)
+	for _, name := range files {
+		remote := path.Join(root, filepath.ToSlash(name))
+		var dirs []string
+		for d := path.Dir(remote); d != "/" && d != "." && !created[d]; d = path.Dir(d) {
+			created[d] = true
+			dirs = append([]string{d}, dirs...)
+		}
+		local, tmp := filepath.ToSlash(filepath.Join(dir, name)), path.Join(path.Dir(remote), "."+path.Base(remote)+".tmp")
+		for _, p := range append(dirs, local, remote) {
+			if err := sftpArgument(p); err != nil {
+				return "", err
+			}
+		}
+		for _, d := range dirs {
+			fmt.Fprintf(&script, "-mkdir \"%s\"\n", d)
+		}
+		fmt.Fprintf(&script, "put \"%s\" \"%s\"\nrename \"%s\" \"%s\"\n", local, tmp, tmp, remote)
+	}
+
+	return script.String(), nil
+}
+
+// sftpArgument checks that a path can be passed double quoted in an sftp
+// batch. Inside the quotes sftp only unescapes \", keeps other backslashes
+// and expands glob patterns, and a batch has one command per line, so paths
+// with quotes, backslashes, glob characters or control characters are
+// rejected rather than escaped.
+func sftpArgument(p string) error {
+	if i := strings.IndexFunc(p, func(r rune) bool { return r < ' ' || r == 0x7f || strings.ContainsRune(`"\*?[`, r) }); i >= 0 {
+		return fmt.Errorf("unable to upload '%s' with sftp due: unsupported character %q", p, p[i])
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSFTPScript(t *testing.T) {
+	tests := []struct {
+		name    string
+		root    string
+		files   []string
+		want    string
+		wantErr string
+	}{
+		{"nested", "/epg", []string{"n_events_1.xml", filepath.Join("a", "n_events_2.xml"), filepath.Join("a", "n_events_3.xml")}, `-mkdir "/epg"
+put "stage/n_events_1.xml" "/epg/.n_events_1.xml.tmp"
+rename "/epg/.n_events_1.xml.tmp" "/epg/n_events_1.xml"
+-mkdir "/epg/a"
+put "stage/a/n_events_2.xml" "/epg/a/.n_events_2.xml.tmp"
+rename "/epg/a/.n_events_2.xml.tmp" "/epg/a/n_events_2.xml"
+put "stage/a/n_events_3.xml" "/epg/a/.n_events_3.xml.tmp"
+rename "/epg/a/.n_events_3.xml.tmp" "/epg/a/n_events_3.xml"
+`, ""},
+		{"spaces and quotes", "/my epg", []string{"n_events_it's.xml"}, `-mkdir "/my epg"
+put "stage/n_events_it's.xml" "/my epg/.n_events_it's.xml.tmp"
+rename "/my epg/.n_events_it's.xml.tmp" "/my epg/n_events_it's.xml"
+`, ""},
+		{"double quote", "/epg", []string{`n_events_"1".xml`}, "", `unsupported character '"'`},
+		{"newline", "/epg", []string{"n_events_1\nput x y.xml"}, "", `unsupported character '\n'`},
+		{"glob", "/epg", []string{"n_events_*.xml"}, "", `unsupported character '*'`},
+		{"backslash in root", `/e\pg`, []string{"n_events_1.xml"}, "", `unsupported character '\\'`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sftpScript(tt.root, "stage", tt.files)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("got error %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const manifestFileName = "manifest.json"
+
+// manifest lists the events of every written channel together with a hash
+// of their content, so the next run can tell which events changed.
+type manifest struct {
+	Generated time.Time                   `json:"generated"`
+	Channels  map[string]*manifestChannel `json:"channels"`
+}
+
+type manifestChannel struct {
+	Name string `json:"name"`
+	// File is the output file of the channel relative to the manifest, in
+	// the output format and package directory it was written to.
+	File   string            `json:"file"`
+	Events map[string]string `json:"events"`
+	// Failed is the conversion error of a channel left out of a partial
+	// publish, whose events are those of its previous output.
+	Failed string `json:"failed,omitempty"`
+	// EITEventIDs are the EIT event_ids of the events, kept between runs,
+	// and NextEITEventID the next one of the channel's sequence.
+	EITEventIDs    map[string]uint16 `json:"eitEventIds,omitempty"`
+	NextEITEventID uint16            `json:"nextEitEventId,omitempty"`
+}
+
+func newManifest(t tenant, converted []convertedChannel) *manifest {
+	m := &manifest{Generated: time.Now().UTC(), Channels: make(map[string]*manifestChannel)}
+	for _, c := range converted {
+		file := filepath.ToSlash(outputFileName(t, "", c.channel))
+		mc := &manifestChannel{Name: c.channel.Name, File: file, Events: make(map[string]string)}
+		for _, e := range c.output.Events.Values {
+			mc.Events[e.ID] = eventHash(e)
+		}
+		m.Channels[c.channel.ID] = mc
+	}
+	return m
+}
+
+// markFailedChannels adds the channels which failed to convert to the
+// manifest, keeping their entries of the previous manifest as their previous
+// output files are kept too.
+func markFailedChannels(m, prev *manifest, failed []failedChannel) {
+	for _, f := range failed {
+		mc := &manifestChannel{Name: f.Name, Events: make(map[string]string)}
+		if prev != nil && prev.Channels[f.ID] != nil {
+			kept := *prev.Channels[f.ID]
+			mc = &kept
+		}
+		mc.Failed = f.Error
+		m.Channels[f.ID] = mc
+	}
+}
+
+// readManifest reads the manifest of the previous run, returning nil when
+// there is none.
+func readManifest(dir string) (*manifest, error) {
+	data, err := readOutputFile(filepath.Join(dir, manifestFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read manifest due: %v", err)
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("unable to parse manifest due: %v", err)
+	}
+	return &m, nil
+}
+
+func writeManifest(dir string, m *manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = writeOutputFile(filepath.Join(dir, manifestFileName), func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("unable to write manifest due: %v", err)
+	}
+	return nil
+}
+
+// eventHash returns a hash of the event content. The provenance and hash
+// attributes are not part of the content, nor is the -cdata encoding of the
+// text.
+func eventHash(e outputEvent) string {
+	e.SourceFile, e.Provider, e.Hash = "", "", ""
+	h := sha256.New()
+	xml.NewEncoder(h).EncodeElement(plainEvent(e), xml.StartElement{Name: xml.Name{Local: "outputEvent"}})
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+type outputDelta struct {
+	XMLName struct{}     `xml:"channel"`
+	Name    string       `xml:"name,attr"`
+	ID      string       `xml:"id,attr"`
+	Added   outputEvents `xml:"added"`
+	Updated outputEvents `xml:"updated"`
+	Deleted []string     `xml:"deleted>id"`
+}
+
+// channelDelta compares the channel with its events in the previous
+// manifest. Without a previous manifest all events are added.
+func channelDelta(c *outputChannel, prev *manifest) *outputDelta {
+	d := &outputDelta{Name: c.Name, ID: c.ID}
+	var prevEvents map[string]string
+	if prev != nil && prev.Channels[c.ID] != nil {
+		prevEvents = prev.Channels[c.ID].Events
+	}
+
+	current := make(map[string]bool)
+	for _, e := range c.Events.Values {
+		current[e.ID] = true
+		hash, ok := prevEvents[e.ID]
+		switch {
+		case !ok:
+			d.Added.Values = append(d.Added.Values, e)
+		case hash != eventHash(e):
+			d.Updated.Values = append(d.Updated.Values, e)
+		}
+	}
+	for id := range prevEvents {
+		if !current[id] {
+			d.Deleted = append(d.Deleted, id)
+		}
+	}
+	sort.Strings(d.Deleted)
+	return d
+}
+
+// removedChannelDelta deletes all events of a channel which is no longer
+// written.
+func removedChannelDelta(id string, prev *manifestChannel) *outputDelta {
+	d := &outputDelta{Name: prev.Name, ID: id}
+	for eventID := range prev.Events {
+		d.Deleted = append(d.Deleted, eventID)
+	}
+	sort.Strings(d.Deleted)
+	return d
+}
+
+func marshalDelta(fileName string, comment string, d *outputDelta) error {
+	return writeXMLFile(fileName, comment, d)
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// dtdParticle is a child element of a content model with its cardinality,
+// max -1 meaning unbounded.
+type dtdParticle struct {
+	name     string
+	min, max int
+}
+
+// dtdElement describes an element of the XMLTV DTD: its ordered children,
+// whether it contains text and its attributes. Attributes map to the list
+// of allowed values, nil allowing any value.
+type dtdElement struct {
+	children []dtdParticle
+	text     bool
+	empty    bool
+	attrs    map[string][]string
+	required []string
+}
+
+func zeroOrMore(name string) dtdParticle { return dtdParticle{name, 0, -1} }
+func oneOrMore(name string) dtdParticle  { return dtdParticle{name, 1, -1} }
+func optional(name string) dtdParticle   { return dtdParticle{name, 0, 1} }
+func one(name string) dtdParticle        { return dtdParticle{name, 1, 1} }
+
+func textElement(attrs ...string) dtdElement {
+	e := dtdElement{text: true, attrs: make(map[string][]string)}
+	for _, a := range attrs {
+		e.attrs[a] = nil
+	}
+	return e
+}
+
+// xmltvDTD follows xmltv.dtd as distributed with XMLTV.
+var xmltvDTD = map[string]dtdElement{
+	"tv": {
+		children: []dtdParticle{zeroOrMore("channel"), zeroOrMore("programme")},
+		attrs: map[string][]string{"date": nil, "source-info-url": nil, "source-info-name": nil, "source-data-url": nil,
+			"generator-info-name": nil, "generator-info-url": nil},
+	},
+	"channel": {
+		children: []dtdParticle{oneOrMore("display-name"), zeroOrMore("icon"), zeroOrMore("url")},
+		attrs:    map[string][]string{"id": nil},
+		required: []string{"id"},
+	},
+	"programme": {
+		children: []dtdParticle{oneOrMore("title"), zeroOrMore("sub-title"), zeroOrMore("desc"), optional("credits"),
+			optional("date"), zeroOrMore("category"), zeroOrMore("keyword"), optional("language"), optional("orig-language"),
+			optional("length"), zeroOrMore("icon"), zeroOrMore("url"), zeroOrMore("country"), zeroOrMore("episode-num"),
+			optional("video"), optional("audio"), optional("previously-shown"), optional("premiere"), optional("last-chance"),
+			optional("new"), zeroOrMore("subtitles"), zeroOrMore("rating"), zeroOrMore("star-rating"), zeroOrMore("review"),
+			zeroOrMore("image")},
+		attrs: map[string][]string{"start": nil, "stop": nil, "pdc-start": nil, "vps-start": nil, "showview": nil,
+			"videoplus": nil, "channel": nil, "clumpidx": nil},
+		required: []string{"start", "channel"},
+	},
+	"credits": {
+		children: []dtdParticle{zeroOrMore("director"), zeroOrMore("actor"), zeroOrMore("writer"), zeroOrMore("adapter"),
+			zeroOrMore("producer"), zeroOrMore("composer"), zeroOrMore("editor"), zeroOrMore("presenter"),
+			zeroOrMore("commentator"), zeroOrMore("guest")},
+	},
+	"video": {children: []dtdParticle{optional("present"), optional("colour"), optional("aspect"), optional("quality")}},
+	"audio": {children: []dtdParticle{optional("present"), optional("stereo")}},
+	"subtitles": {
+		children: []dtdParticle{optional("language")},
+		attrs:    map[string][]string{"type": {"teletext", "onscreen", "deaf-signed"}},
+	},
+	"rating":           {children: []dtdParticle{one("value"), zeroOrMore("icon")}, attrs: map[string][]string{"system": nil}},
+	"star-rating":      {children: []dtdParticle{one("value"), zeroOrMore("icon")}, attrs: map[string][]string{"system": nil}},
+	"icon":             {empty: true, attrs: map[string][]string{"src": nil, "width": nil, "height": nil}, required: []string{"src"}},
+	"previously-shown": {empty: true, attrs: map[string][]string{"start": nil, "channel": nil}},
+	"new":              {empty: true},
+	"length": {text: true, attrs: map[string][]string{"units": {"seconds", "minutes", "hours"}},
+		required: []string{"units"}},
+	"review": {text: true, attrs: map[string][]string{"type": {"text", "url"}, "source": nil, "reviewer": nil, "lang": nil},
+		required: []string{"type"}},
+	"display-name":  textElement("lang"),
+	"title":         textElement("lang"),
+	"sub-title":     textElement("lang"),
+	"desc":          textElement("lang"),
+	"category":      textElement("lang"),
+	"keyword":       textElement("lang"),
+	"language":      textElement("lang"),
+	"orig-language": textElement("lang"),
+	"country":       textElement("lang"),
+	"premiere":      textElement("lang"),
+	"last-chance":   textElement("lang"),
+	"url":           textElement("system"),
+	"episode-num":   textElement("system"),
+	"image":         textElement("type", "size", "orient", "system"),
+	"actor":         textElement("role", "guest"),
+	"director":      textElement(),
+	"writer":        textElement(),
+	"adapter":       textElement(),
+	"producer":      textElement(),
+	"composer":      textElement(),
+	"editor":        textElement(),
+	"presenter":     textElement(),
+	"commentator":   textElement(),
+	"guest":         textElement(),
+	"date":          textElement(),
+	"present":       textElement(),
+	"colour":        textElement(),
+	"aspect":        textElement(),
+	"quality":       textElement(),
+	"stereo":        textElement(),
+	"value":         textElement(),
+}
+
+// checkDTD validates an XML source file against the XMLTV DTD, returning
+// every violation prefixed with its file, line and column. JSON sources
+// are not checked.
+func checkDTD(fname string) ([]string, error) {
+	r, closeSource, err := openSource(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer closeSource()
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read source file '%s' due: %v", fname, err)
+	}
+	// blank the byte order mark keeping the offsets of the following tokens
+	if bytes.HasPrefix(data, []byte("\xef\xbb\xbf")) {
+		copy(data, "   ")
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return nil, nil
+	}
+	return dtdViolations(fname, data), nil
+}
+
+// dtdViolations walks the tokens of the document checking every element
+// against its content model.
+func dtdViolations(fname string, data []byte) []string {
+	var problems []string
+	position := func(offset int64) string {
+		line := bytes.Count(data[:offset], []byte("\n")) + 1
+		col := int(offset) - bytes.LastIndexByte(data[:offset], '\n')
+		return fmt.Sprintf("%s:%d:%d", fname, line, col)
+	}
+
+	type open struct {
+		name     string
+		pos      string
+		children []string
+	}
+	var stack []open
+	seenRoot := false
+
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		offset := dec.InputOffset()
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", position(dec.InputOffset()), err))
+			break
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			pos := position(offset)
+			name := t.Name.Local
+			if len(stack) == 0 {
+				if seenRoot || name != "tv" {
+					problems = append(problems, fmt.Sprintf("%s: root element must be <tv>, found <%s>", pos, name))
+				}
+				seenRoot = true
+			} else {
+				stack[len(stack)-1].children = append(stack[len(stack)-1].children, name)
+			}
+			problems = append(problems, checkDTDAttrs(pos, name, t.Attr)...)
+			stack = append(stack, open{name: name, pos: pos})
+		case xml.EndElement:
+			if len(stack) == 0 {
+				continue
+			}
+			e := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if msg := checkDTDChildren(e.name, e.children); msg != "" {
+				problems = append(problems, fmt.Sprintf("%s: %s", e.pos, msg))
+			}
+		case xml.CharData:
+			if len(stack) == 0 || len(bytes.TrimSpace(t)) == 0 {
+				continue
+			}
+			name := stack[len(stack)-1].name
+			if def, ok := xmltvDTD[name]; ok && !def.text {
+				problems = append(problems, fmt.Sprintf("%s: text is not allowed in <%s>", position(offset), name))
+			}
+		}
+	}
+	return problems
+}
+
+func checkDTDAttrs(pos string, name string, attrs []xml.Attr) []string {
+	def, ok := xmltvDTD[name]
+	if !ok {
+		return []string{fmt.Sprintf("%s: element <%s> is not declared", pos, name)}
+	}
+	var problems []string
+	present := make(map[string]bool)
+	for _, a := range attrs {
+		attr := a.Name.Local
+		if a.Name.Space == "xmlns" || (a.Name.Space == "" && attr == "xmlns") {
+			continue
+		}
+		present[attr] = true
+		allowed, ok := def.attrs[attr]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: attribute '%s' is not declared for <%s>", pos, attr, name))
+			continue
+		}
+		if allowed != nil && !containsString(allowed, a.Value) {
+			problems = append(problems, fmt.Sprintf("%s: attribute '%s' of <%s> has value '%s', expected one of %s",
+				pos, attr, name, a.Value, strings.Join(allowed, ", ")))
+		}
+	}
+	for _, r := range def.required {
+		if !present[r] {
+			problems = append(problems, fmt.Sprintf("%s: <%s> is missing the required attribute '%s'", pos, name, r))
+		}
+	}
+	return problems
+}
+
+// checkDTDChildren matches the children of an element against its content
+// model, returning a description of the first mismatch.
+func checkDTDChildren(name string, children []string) string {
+	def, ok := xmltvDTD[name]
+	if !ok {
+		return ""
+	}
+	if def.empty || def.children == nil {
+		if len(children) > 0 {
+			return fmt.Sprintf("<%s> must not contain elements, found <%s>", name, children[0])
+		}
+		return ""
+	}
+
+	i := 0
+	for _, p := range def.children {
+		n := 0
+		for i < len(children) && children[i] == p.name && (p.max < 0 || n < p.max) {
+			i++
+			n++
+		}
+		if n < p.min {
+			return fmt.Sprintf("<%s> is missing <%s>", name, p.name)
+		}
+	}
+	if i < len(children) {
+		return fmt.Sprintf("<%s> is not allowed at this position in <%s>", children[i], name)
+	}
+	return ""
+}
+
+func containsString(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
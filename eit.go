@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// eitService is the intermediate representation of the DVB event
+// information of a single service, as consumed by EIT carousel generators:
+// the present/following table and the schedule table.
+type eitService struct {
+	ServiceID   string     `json:"service_id"`
+	ServiceName string     `json:"service_name"`
+	Generated   string     `json:"generated"`
+	Present     *eitEvent  `json:"present"`
+	Following   *eitEvent  `json:"following"`
+	Schedule    []eitEvent `json:"schedule"`
+}
+
+type eitEvent struct {
+	EventID      uint16 `json:"event_id"`
+	StartTime    string `json:"start_time"`
+	Duration     string `json:"duration"`
+	Language     string `json:"language"`
+	Name         string `json:"name"`
+	Text         string `json:"text,omitempty"`
+	ExtendedText string `json:"extended_text,omitempty"`
+	CRID         string `json:"crid,omitempty"`
+}
+
+// eitEventIDs assigns the 16 bit EIT event_ids to the events of a service.
+// An event keeps the ID it had in prev, the others get the following IDs of
+// the service's sequence starting at next, skipping 0 and the IDs in use. It
+// returns the IDs by event ID and the start of the sequence for the next run.
+func eitEventIDs(events []outputEvent, prev map[string]uint16, next uint16) (map[string]uint16, uint16, error) {
+	if len(events) >= 1<<16-1 {
+		return nil, next, fmt.Errorf("%d events exceed the EIT event_ids of a service", len(events))
+	}
+	ids := make(map[string]uint16, len(events))
+	used := make(map[uint16]bool, len(events))
+	for _, e := range events {
+		if id, ok := prev[e.ID]; ok && !used[id] {
+			ids[e.ID] = id
+			used[id] = true
+		}
+	}
+	for _, e := range events {
+		if _, ok := ids[e.ID]; ok {
+			continue
+		}
+		for next == 0 || used[next] {
+			next++
+		}
+		ids[e.ID] = next
+		used[next] = true
+		next++
+	}
+	return ids, next, nil
+}
+
+// assignEITEventIDs assigns the EIT event_ids of the converted channels,
+// continuing the sequences of the previous manifest and recording them in m,
+// so an event keeps its event_id between runs.
+func assignEITEventIDs(m, prev *manifest, converted []convertedChannel) error {
+	for _, c := range converted {
+		var prevIDs map[string]uint16
+		var next uint16
+		if prev != nil && prev.Channels[c.channel.ID] != nil {
+			prevIDs, next = prev.Channels[c.channel.ID].EITEventIDs, prev.Channels[c.channel.ID].NextEITEventID
+		}
+		ids, next, err := eitEventIDs(c.output.Events.Values, prevIDs, next)
+		if err != nil {
+			return fmt.Errorf("channel %s due: %v", c.channel.ID, err)
+		}
+		c.output.eitIDs = ids
+		if mc := m.Channels[c.channel.ID]; mc != nil {
+			mc.EITEventIDs, mc.NextEITEventID = ids, next
+		}
+	}
+	return nil
+}
+
+func newEITEvent(e outputEvent, id uint16, lang string) (eitEvent, error) {
+	start, end, err := eventTimes(e)
+	if err != nil {
+		return eitEvent{}, fmt.Errorf("invalid time of event '%s' due: %v", e.ID, err)
+	}
+	d := end.Sub(start).Round(time.Second)
+	return eitEvent{
+		EventID:      id,
+		StartTime:    e.StartTime,
+		Duration:     fmt.Sprintf("%02d:%02d:%02d", d/time.Hour, d%time.Hour/time.Minute, d%time.Minute/time.Second),
+		Language:     lang,
+		Name:         e.Name,
+		Text:         e.Perex,
+		ExtendedText: e.Description,
+		CRID:         e.CRID,
+	}, nil
+}
+
+// eitDocument converts a channel to its EIT tables in the language lang,
+// with the present/following events taken relative to the given time. The
+// event_ids are those assigned by assignEITEventIDs, a channel without them
+// numbers its events from 1.
+func eitDocument(c *outputChannel, lang string, at time.Time) (*eitService, error) {
+	ids := c.eitIDs
+	if ids == nil {
+		var err error
+		if ids, _, err = eitEventIDs(c.Events.Values, nil, 1); err != nil {
+			return nil, err
+		}
+	}
+	doc := &eitService{ServiceID: c.ID, ServiceName: c.Name, Generated: at.UTC().Format(outDateLayout)}
+	for _, e := range c.Events.Values {
+		ee, err := newEITEvent(e, ids[e.ID], lang)
+		if err != nil {
+			return nil, err
+		}
+		doc.Schedule = append(doc.Schedule, ee)
+	}
+
+	current := nowNext([]*outputChannel{c}, at)[0]
+	if current.Now != nil {
+		ee, _ := newEITEvent(*current.Now, ids[current.Now.ID], lang)
+		doc.Present = &ee
+	}
+	if current.Next != nil {
+		ee, _ := newEITEvent(*current.Next, ids[current.Next.ID], lang)
+		doc.Following = &ee
+	}
+	return doc, nil
+}
+
+func encodeEIT(w io.Writer, channel *outputChannel, lang string) error {
+	doc, err := eitDocument(channel, lang, time.Now())
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to marshall content due: %v", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("unable to write output file due: %v", err)
+	}
+	return nil
+}
+
+// decodeEIT converts the EIT tables written by encodeEIT back to a channel,
+// with the event_id as the event ID.
+func decodeEIT(data []byte) (*outputChannel, error) {
+	var doc eitService
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	c := &outputChannel{ID: doc.ServiceID, Name: doc.ServiceName}
+	for _, ee := range doc.Schedule {
+		start, err := time.Parse(outDateLayout, ee.StartTime)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start of event %d due: %v", ee.EventID, err)
+		}
+		var h, m, s int
+		if _, err := fmt.Sscanf(ee.Duration, "%d:%d:%d", &h, &m, &s); err != nil {
+			return nil, fmt.Errorf("invalid duration of event %d due: %v", ee.EventID, err)
+		}
+		end := start.Add(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(s)*time.Second)
+		c.Events.Values = append(c.Events.Values, outputEvent{
+			ID:          strconv.Itoa(int(ee.EventID)),
+			Name:        ee.Name,
+			StartTime:   ee.StartTime,
+			EndTime:     end.UTC().Format(outDateLayout),
+			Perex:       ee.Text,
+			Description: ee.ExtendedText,
+			CRID:        ee.CRID,
+		})
+	}
+	return c, nil
+}
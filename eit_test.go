@@ -0,0 +1,41 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEITEventIDs(t *testing.T) {
+	events := func(ids ...string) []outputEvent {
+		var es []outputEvent
+		for _, id := range ids {
+			es = append(es, outputEvent{ID: id})
+		}
+		return es
+	}
+	tests := []struct {
+		name     string
+		events   []outputEvent
+		prev     map[string]uint16
+		next     uint16
+		want     map[string]uint16
+		wantNext uint16
+	}{
+		{"first run", events("a", "b"), nil, 0, map[string]uint16{"a": 1, "b": 2}, 3},
+		{"kept", events("a", "b", "c"), map[string]uint16{"a": 1, "b": 2}, 3, map[string]uint16{"a": 1, "b": 2, "c": 3}, 4},
+		{"dropped not reused", events("b", "c"), map[string]uint16{"a": 1, "b": 2}, 3, map[string]uint16{"b": 2, "c": 3}, 4},
+		{"wrapped", events("a", "b", "c"), map[string]uint16{"a": 1}, 65535, map[string]uint16{"a": 1, "b": 65535, "c": 2}, 3},
+		{"used skipped", events("a", "b", "c"), map[string]uint16{"a": 5, "b": 6}, 5, map[string]uint16{"a": 5, "b": 6, "c": 7}, 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, next, err := eitEventIDs(tt.events, tt.prev, tt.next)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) || next != tt.wantNext {
+				t.Errorf("got %v next %d, want %v next %d", got, next, tt.want, tt.wantNext)
+			}
+		})
+	}
+}
@@ -0,0 +1,5 @@
+// Package epgpb holds the gRPC service of the serve command generated from
+// epg.proto.
+package epgpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative epg.proto
@@ -0,0 +1,551 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: epg.proto
+
+// Package epgtool.v1 serves the published channels and their events, the
+// gRPC counterpart of /api/channels of the serve command.
+
+package epgpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ListChannelsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListChannelsRequest) Reset() {
+	*x = ListChannelsRequest{}
+	mi := &file_epg_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListChannelsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListChannelsRequest) ProtoMessage() {}
+
+func (x *ListChannelsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_epg_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListChannelsRequest.ProtoReflect.Descriptor instead.
+func (*ListChannelsRequest) Descriptor() ([]byte, []int) {
+	return file_epg_proto_rawDescGZIP(), []int{0}
+}
+
+type ListChannelsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Channels      []*Channel             `protobuf:"bytes,1,rep,name=channels,proto3" json:"channels,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListChannelsResponse) Reset() {
+	*x = ListChannelsResponse{}
+	mi := &file_epg_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListChannelsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListChannelsResponse) ProtoMessage() {}
+
+func (x *ListChannelsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_epg_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListChannelsResponse.ProtoReflect.Descriptor instead.
+func (*ListChannelsResponse) Descriptor() ([]byte, []int) {
+	return file_epg_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ListChannelsResponse) GetChannels() []*Channel {
+	if x != nil {
+		return x.Channels
+	}
+	return nil
+}
+
+type Channel struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Events        int32                  `protobuf:"varint,3,opt,name=events,proto3" json:"events,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Channel) Reset() {
+	*x = Channel{}
+	mi := &file_epg_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Channel) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Channel) ProtoMessage() {}
+
+func (x *Channel) ProtoReflect() protoreflect.Message {
+	mi := &file_epg_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Channel.ProtoReflect.Descriptor instead.
+func (*Channel) Descriptor() ([]byte, []int) {
+	return file_epg_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Channel) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Channel) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Channel) GetEvents() int32 {
+	if x != nil {
+		return x.Events
+	}
+	return 0
+}
+
+type StreamEventsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// channel is the id of the channel.
+	Channel string `protobuf:"bytes,1,opt,name=channel,proto3" json:"channel,omitempty"`
+	// from and to limit the events to those overlapping [from, to), an unset
+	// bound is open.
+	From          *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=from,proto3" json:"from,omitempty"`
+	To            *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=to,proto3" json:"to,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamEventsRequest) Reset() {
+	*x = StreamEventsRequest{}
+	mi := &file_epg_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamEventsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamEventsRequest) ProtoMessage() {}
+
+func (x *StreamEventsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_epg_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamEventsRequest.ProtoReflect.Descriptor instead.
+func (*StreamEventsRequest) Descriptor() ([]byte, []int) {
+	return file_epg_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *StreamEventsRequest) GetChannel() string {
+	if x != nil {
+		return x.Channel
+	}
+	return ""
+}
+
+func (x *StreamEventsRequest) GetFrom() *timestamppb.Timestamp {
+	if x != nil {
+		return x.From
+	}
+	return nil
+}
+
+func (x *StreamEventsRequest) GetTo() *timestamppb.Timestamp {
+	if x != nil {
+		return x.To
+	}
+	return nil
+}
+
+// Event is a published event, the fields follow the output elements.
+type Event struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	Id                  string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name                string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Start               *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=start,proto3" json:"start,omitempty"`
+	End                 *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=end,proto3" json:"end,omitempty"`
+	LocalDate           string                 `protobuf:"bytes,5,opt,name=local_date,json=localDate,proto3" json:"local_date,omitempty"`
+	Perex               string                 `protobuf:"bytes,6,opt,name=perex,proto3" json:"perex,omitempty"`
+	Description         string                 `protobuf:"bytes,7,opt,name=description,proto3" json:"description,omitempty"`
+	Actors              []string               `protobuf:"bytes,8,rep,name=actors,proto3" json:"actors,omitempty"`
+	Directors           []string               `protobuf:"bytes,9,rep,name=directors,proto3" json:"directors,omitempty"`
+	ProductionYear      string                 `protobuf:"bytes,10,opt,name=production_year,json=productionYear,proto3" json:"production_year,omitempty"`
+	OriginalAirDate     string                 `protobuf:"bytes,11,opt,name=original_air_date,json=originalAirDate,proto3" json:"original_air_date,omitempty"`
+	ProductionCountries string                 `protobuf:"bytes,12,opt,name=production_countries,json=productionCountries,proto3" json:"production_countries,omitempty"`
+	VideoQuality        string                 `protobuf:"bytes,13,opt,name=video_quality,json=videoQuality,proto3" json:"video_quality,omitempty"`
+	VideoAspect         string                 `protobuf:"bytes,14,opt,name=video_aspect,json=videoAspect,proto3" json:"video_aspect,omitempty"`
+	Audio               string                 `protobuf:"bytes,15,opt,name=audio,proto3" json:"audio,omitempty"`
+	Url                 string                 `protobuf:"bytes,16,opt,name=url,proto3" json:"url,omitempty"`
+	Crid                string                 `protobuf:"bytes,17,opt,name=crid,proto3" json:"crid,omitempty"`
+	ProgramId           string                 `protobuf:"bytes,18,opt,name=program_id,json=programId,proto3" json:"program_id,omitempty"`
+	Tags                []string               `protobuf:"bytes,19,rep,name=tags,proto3" json:"tags,omitempty"`
+	GroupId             string                 `protobuf:"bytes,20,opt,name=group_id,json=groupId,proto3" json:"group_id,omitempty"`
+	Source              string                 `protobuf:"bytes,21,opt,name=source,proto3" json:"source,omitempty"`
+	Provider            string                 `protobuf:"bytes,22,opt,name=provider,proto3" json:"provider,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_epg_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_epg_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_epg_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Event) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Event) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Event) GetStart() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Start
+	}
+	return nil
+}
+
+func (x *Event) GetEnd() *timestamppb.Timestamp {
+	if x != nil {
+		return x.End
+	}
+	return nil
+}
+
+func (x *Event) GetLocalDate() string {
+	if x != nil {
+		return x.LocalDate
+	}
+	return ""
+}
+
+func (x *Event) GetPerex() string {
+	if x != nil {
+		return x.Perex
+	}
+	return ""
+}
+
+func (x *Event) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *Event) GetActors() []string {
+	if x != nil {
+		return x.Actors
+	}
+	return nil
+}
+
+func (x *Event) GetDirectors() []string {
+	if x != nil {
+		return x.Directors
+	}
+	return nil
+}
+
+func (x *Event) GetProductionYear() string {
+	if x != nil {
+		return x.ProductionYear
+	}
+	return ""
+}
+
+func (x *Event) GetOriginalAirDate() string {
+	if x != nil {
+		return x.OriginalAirDate
+	}
+	return ""
+}
+
+func (x *Event) GetProductionCountries() string {
+	if x != nil {
+		return x.ProductionCountries
+	}
+	return ""
+}
+
+func (x *Event) GetVideoQuality() string {
+	if x != nil {
+		return x.VideoQuality
+	}
+	return ""
+}
+
+func (x *Event) GetVideoAspect() string {
+	if x != nil {
+		return x.VideoAspect
+	}
+	return ""
+}
+
+func (x *Event) GetAudio() string {
+	if x != nil {
+		return x.Audio
+	}
+	return ""
+}
+
+func (x *Event) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *Event) GetCrid() string {
+	if x != nil {
+		return x.Crid
+	}
+	return ""
+}
+
+func (x *Event) GetProgramId() string {
+	if x != nil {
+		return x.ProgramId
+	}
+	return ""
+}
+
+func (x *Event) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *Event) GetGroupId() string {
+	if x != nil {
+		return x.GroupId
+	}
+	return ""
+}
+
+func (x *Event) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *Event) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+var File_epg_proto protoreflect.FileDescriptor
+
+const file_epg_proto_rawDesc = "" +
+	"\n" +
+	"\tepg.proto\x12\n" +
+	"epgtool.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\x15\n" +
+	"\x13ListChannelsRequest\"G\n" +
+	"\x14ListChannelsResponse\x12/\n" +
+	"\bchannels\x18\x01 \x03(\v2\x13.epgtool.v1.ChannelR\bchannels\"E\n" +
+	"\aChannel\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x16\n" +
+	"\x06events\x18\x03 \x01(\x05R\x06events\"\x8b\x01\n" +
+	"\x13StreamEventsRequest\x12\x18\n" +
+	"\achannel\x18\x01 \x01(\tR\achannel\x12.\n" +
+	"\x04from\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x04from\x12*\n" +
+	"\x02to\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\x02to\"\xa6\x05\n" +
+	"\x05Event\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x120\n" +
+	"\x05start\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\x05start\x12,\n" +
+	"\x03end\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\x03end\x12\x1d\n" +
+	"\n" +
+	"local_date\x18\x05 \x01(\tR\tlocalDate\x12\x14\n" +
+	"\x05perex\x18\x06 \x01(\tR\x05perex\x12 \n" +
+	"\vdescription\x18\a \x01(\tR\vdescription\x12\x16\n" +
+	"\x06actors\x18\b \x03(\tR\x06actors\x12\x1c\n" +
+	"\tdirectors\x18\t \x03(\tR\tdirectors\x12'\n" +
+	"\x0fproduction_year\x18\n" +
+	" \x01(\tR\x0eproductionYear\x12*\n" +
+	"\x11original_air_date\x18\v \x01(\tR\x0foriginalAirDate\x121\n" +
+	"\x14production_countries\x18\f \x01(\tR\x13productionCountries\x12#\n" +
+	"\rvideo_quality\x18\r \x01(\tR\fvideoQuality\x12!\n" +
+	"\fvideo_aspect\x18\x0e \x01(\tR\vvideoAspect\x12\x14\n" +
+	"\x05audio\x18\x0f \x01(\tR\x05audio\x12\x10\n" +
+	"\x03url\x18\x10 \x01(\tR\x03url\x12\x12\n" +
+	"\x04crid\x18\x11 \x01(\tR\x04crid\x12\x1d\n" +
+	"\n" +
+	"program_id\x18\x12 \x01(\tR\tprogramId\x12\x12\n" +
+	"\x04tags\x18\x13 \x03(\tR\x04tags\x12\x19\n" +
+	"\bgroup_id\x18\x14 \x01(\tR\agroupId\x12\x16\n" +
+	"\x06source\x18\x15 \x01(\tR\x06source\x12\x1a\n" +
+	"\bprovider\x18\x16 \x01(\tR\bprovider2\x9e\x01\n" +
+	"\x03EPG\x12Q\n" +
+	"\fListChannels\x12\x1f.epgtool.v1.ListChannelsRequest\x1a .epgtool.v1.ListChannelsResponse\x12D\n" +
+	"\fStreamEvents\x12\x1f.epgtool.v1.StreamEventsRequest\x1a\x11.epgtool.v1.Event0\x01B!Z\x1fgithub.com/mgenov/epgtool/epgpbb\x06proto3"
+
+var (
+	file_epg_proto_rawDescOnce sync.Once
+	file_epg_proto_rawDescData []byte
+)
+
+func file_epg_proto_rawDescGZIP() []byte {
+	file_epg_proto_rawDescOnce.Do(func() {
+		file_epg_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_epg_proto_rawDesc), len(file_epg_proto_rawDesc)))
+	})
+	return file_epg_proto_rawDescData
+}
+
+var file_epg_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_epg_proto_goTypes = []any{
+	(*ListChannelsRequest)(nil),   // 0: epgtool.v1.ListChannelsRequest
+	(*ListChannelsResponse)(nil),  // 1: epgtool.v1.ListChannelsResponse
+	(*Channel)(nil),               // 2: epgtool.v1.Channel
+	(*StreamEventsRequest)(nil),   // 3: epgtool.v1.StreamEventsRequest
+	(*Event)(nil),                 // 4: epgtool.v1.Event
+	(*timestamppb.Timestamp)(nil), // 5: google.protobuf.Timestamp
+}
+var file_epg_proto_depIdxs = []int32{
+	2, // 0: epgtool.v1.ListChannelsResponse.channels:type_name -> epgtool.v1.Channel
+	5, // 1: epgtool.v1.StreamEventsRequest.from:type_name -> google.protobuf.Timestamp
+	5, // 2: epgtool.v1.StreamEventsRequest.to:type_name -> google.protobuf.Timestamp
+	5, // 3: epgtool.v1.Event.start:type_name -> google.protobuf.Timestamp
+	5, // 4: epgtool.v1.Event.end:type_name -> google.protobuf.Timestamp
+	0, // 5: epgtool.v1.EPG.ListChannels:input_type -> epgtool.v1.ListChannelsRequest
+	3, // 6: epgtool.v1.EPG.StreamEvents:input_type -> epgtool.v1.StreamEventsRequest
+	1, // 7: epgtool.v1.EPG.ListChannels:output_type -> epgtool.v1.ListChannelsResponse
+	4, // 8: epgtool.v1.EPG.StreamEvents:output_type -> epgtool.v1.Event
+	7, // [7:9] is the sub-list for method output_type
+	5, // [5:7] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_epg_proto_init() }
+func file_epg_proto_init() {
+	if File_epg_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_epg_proto_rawDesc), len(file_epg_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   5,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_epg_proto_goTypes,
+		DependencyIndexes: file_epg_proto_depIdxs,
+		MessageInfos:      file_epg_proto_msgTypes,
+	}.Build()
+	File_epg_proto = out.File
+	file_epg_proto_goTypes = nil
+	file_epg_proto_depIdxs = nil
+}
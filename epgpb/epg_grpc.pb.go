@@ -0,0 +1,172 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: epg.proto
+
+// Package epgtool.v1 serves the published channels and their events, the
+// gRPC counterpart of /api/channels of the serve command.
+
+package epgpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	EPG_ListChannels_FullMethodName = "/epgtool.v1.EPG/ListChannels"
+	EPG_StreamEvents_FullMethodName = "/epgtool.v1.EPG/StreamEvents"
+)
+
+// EPGClient is the client API for EPG service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type EPGClient interface {
+	// ListChannels lists the published channels.
+	ListChannels(ctx context.Context, in *ListChannelsRequest, opts ...grpc.CallOption) (*ListChannelsResponse, error)
+	// StreamEvents streams the events of a channel overlapping the optional
+	// time range.
+	StreamEvents(ctx context.Context, in *StreamEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error)
+}
+
+type ePGClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewEPGClient(cc grpc.ClientConnInterface) EPGClient {
+	return &ePGClient{cc}
+}
+
+func (c *ePGClient) ListChannels(ctx context.Context, in *ListChannelsRequest, opts ...grpc.CallOption) (*ListChannelsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListChannelsResponse)
+	err := c.cc.Invoke(ctx, EPG_ListChannels_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ePGClient) StreamEvents(ctx context.Context, in *StreamEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &EPG_ServiceDesc.Streams[0], EPG_StreamEvents_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamEventsRequest, Event]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type EPG_StreamEventsClient = grpc.ServerStreamingClient[Event]
+
+// EPGServer is the server API for EPG service.
+// All implementations must embed UnimplementedEPGServer
+// for forward compatibility.
+type EPGServer interface {
+	// ListChannels lists the published channels.
+	ListChannels(context.Context, *ListChannelsRequest) (*ListChannelsResponse, error)
+	// StreamEvents streams the events of a channel overlapping the optional
+	// time range.
+	StreamEvents(*StreamEventsRequest, grpc.ServerStreamingServer[Event]) error
+	mustEmbedUnimplementedEPGServer()
+}
+
+// UnimplementedEPGServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedEPGServer struct{}
+
+func (UnimplementedEPGServer) ListChannels(context.Context, *ListChannelsRequest) (*ListChannelsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListChannels not implemented")
+}
+func (UnimplementedEPGServer) StreamEvents(*StreamEventsRequest, grpc.ServerStreamingServer[Event]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamEvents not implemented")
+}
+func (UnimplementedEPGServer) mustEmbedUnimplementedEPGServer() {}
+func (UnimplementedEPGServer) testEmbeddedByValue()             {}
+
+// UnsafeEPGServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to EPGServer will
+// result in compilation errors.
+type UnsafeEPGServer interface {
+	mustEmbedUnimplementedEPGServer()
+}
+
+func RegisterEPGServer(s grpc.ServiceRegistrar, srv EPGServer) {
+	// If the following call pancis, it indicates UnimplementedEPGServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&EPG_ServiceDesc, srv)
+}
+
+func _EPG_ListChannels_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListChannelsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EPGServer).ListChannels(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EPG_ListChannels_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EPGServer).ListChannels(ctx, req.(*ListChannelsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EPG_StreamEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(EPGServer).StreamEvents(m, &grpc.GenericServerStream[StreamEventsRequest, Event]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type EPG_StreamEventsServer = grpc.ServerStreamingServer[Event]
+
+// EPG_ServiceDesc is the grpc.ServiceDesc for EPG service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var EPG_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "epgtool.v1.EPG",
+	HandlerType: (*EPGServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListChannels",
+			Handler:    _EPG_ListChannels_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamEvents",
+			Handler:       _EPG_StreamEvents_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "epg.proto",
+}
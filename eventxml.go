@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// plainEvent is an outputEvent encoded with the default XML marshalling.
+type plainEvent outputEvent
+
+// cdataElements are the free text elements of an event wrapped with -cdata.
+var cdataElements = map[string]bool{
+	"name": true, "perex": true, "description": true, "actors": true, "directors": true, "actor": true,
+	"director": true, "production_countries": true, "url": true,
+}
+
+type cdataText struct {
+	Value string `xml:",cdata"`
+}
+
+// fieldPresence maps the optional event elements which are written even
+// when empty to the text written for them, parsed from -fieldPresence.
+var fieldPresence map[string]string
+
+// parseFieldPresence parses a list such as "description=empty,perex=default:TBA".
+// An element is omitted when empty, which is the default, written as an empty
+// element or written with the given default text.
+func parseFieldPresence(spec string) (map[string]string, error) {
+	optional := make(map[string]bool)
+	t := reflect.TypeOf(outputEvent{})
+	for i := 0; i < t.NumField(); i++ {
+		parts := strings.Split(t.Field(i).Tag.Get("xml"), ",")
+		if containsString(parts[1:], "omitempty") && !containsString(parts[1:], "attr") {
+			optional[parts[0]] = true
+		}
+	}
+
+	result := make(map[string]string)
+	for _, item := range splitList(spec) {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 || !optional[kv[0]] {
+			return nil, fmt.Errorf("invalid field presence '%s', expected <optional event element>=omit|empty|default:<text>", item)
+		}
+		switch mode := kv[1]; {
+		case mode == "omit":
+		case mode == "empty":
+			result[kv[0]] = ""
+		case strings.HasPrefix(mode, "default:"):
+			result[kv[0]] = strings.TrimPrefix(mode, "default:")
+		default:
+			return nil, fmt.Errorf("invalid field presence mode '%s' of '%s'", mode, kv[0])
+		}
+	}
+	return result, nil
+}
+
+// MarshalXML encodes the event, wrapping its free text elements in CDATA
+// sections when -cdata is set and writing the empty optional elements
+// configured with -fieldPresence. encoding/xml splits any "]]>" in the text
+// over two sections.
+func (e outputEvent) MarshalXML(enc *xml.Encoder, start xml.StartElement) error {
+	if !*cdataOutput && len(fieldPresence) == 0 {
+		return enc.EncodeElement(plainEvent(e), start)
+	}
+
+	v := reflect.ValueOf(e)
+	type child struct {
+		name  string
+		value interface{}
+	}
+	var children []child
+	for i := 0; i < v.NumField(); i++ {
+		tag := v.Type().Field(i).Tag.Get("xml")
+		if tag == "-" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		name, opts := parts[0], parts[1:]
+		f := v.Field(i)
+		if containsString(opts, "attr") {
+			if !containsString(opts, "omitempty") || !f.IsZero() {
+				start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: name}, Value: f.String()})
+			}
+			continue
+		}
+		if containsString(opts, "omitempty") && f.IsZero() {
+			if text, ok := fieldPresence[name]; ok {
+				children = append(children, child{name, text})
+			}
+			continue
+		}
+		children = append(children, child{name, f.Interface()})
+	}
+
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, c := range children {
+		el := xml.StartElement{Name: xml.Name{Local: c.name}}
+		value := c.value
+		if *cdataOutput && cdataElements[c.name] {
+			switch v := value.(type) {
+			case string:
+				value = cdataText{v}
+			case []string:
+				texts := make([]cdataText, len(v))
+				for i, text := range v {
+					texts[i] = cdataText{text}
+				}
+				value = texts
+			}
+		}
+		if err := enc.EncodeElement(value, el); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
@@ -0,0 +1,34 @@
+package main
+
+import "fmt"
+
+// expectationViolations checks the converted channels of the tenants against
+// -expectMinChannels and -expectMinEventsPerChannel. Requested channels
+// without any source events count as channels with no events.
+func expectationViolations(tenants []*tenantSummary) []string {
+	var violations []string
+	for _, ts := range tenants {
+		var prefix string
+		if ts.Name != "" {
+			prefix = "tenant " + ts.Name + ": "
+		}
+		if *expectChannels > 0 && len(ts.converted) < *expectChannels {
+			violations = append(violations, fmt.Sprintf("%s%d channels converted, expected at least %d", prefix,
+				len(ts.converted), *expectChannels))
+		}
+		if *expectEvents <= 0 {
+			continue
+		}
+		for _, c := range ts.converted {
+			if n := len(c.output.Events.Values); n < *expectEvents {
+				violations = append(violations, fmt.Sprintf("%schannel %s %q has %d events, expected at least %d", prefix,
+					c.channel.ID, c.channel.Name, n, *expectEvents))
+			}
+		}
+		for _, c := range ts.EmptyChannels {
+			violations = append(violations, fmt.Sprintf("%schannel %s %q has 0 events, expected at least %d", prefix,
+				c.ID, c.Name, *expectEvents))
+		}
+	}
+	return violations
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mgenov/epgtool/store"
+)
+
+// fetchMeta is kept next to a downloaded source file to make conditional
+// requests on the following runs.
+type fetchMeta struct {
+	ETag         string `json:"etag"`
+	LastModified string `json:"lastModified"`
+}
+
+// fetchSource downloads the source file at rawURL, an http(s):// or s3://
+// URL, into dir of the source store under the name given by fetchedName.
+// Failed attempts are retried with exponential backoff until ctx is done and
+// a file which has not changed since the previous download is not downloaded
+// again.
+func fetchSource(ctx context.Context, rawURL string, dir string, prefix string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid source URL '%s' due: %v", redactURL(rawURL), err)
+	}
+	switch u.Scheme {
+	case "http", "https", "s3":
+	default:
+		return fmt.Errorf("unsupported source URL '%s', expected http(s):// or s3://", redactURL(rawURL))
+	}
+	name, err := fetchedName(u, prefix)
+	if err != nil {
+		return err
+	}
+	files, err := dataFiles()
+	if err != nil {
+		return fmt.Errorf("unable to download '%s' due: %v", redactURL(rawURL), err)
+	}
+	if err := files.MkdirAll(dir); err != nil {
+		return fmt.Errorf("unable to create data directory due: %v", err)
+	}
+	fileName := filepath.Join(dir, name)
+	metaFileName := filepath.Join(dir, ".fetch", name+".json")
+
+	var meta fetchMeta
+	if f, err := sourceFiles.Open(fileName); err == nil {
+		f.Close()
+		if data, err := files.ReadFile(metaFileName); err == nil {
+			json.Unmarshal(data, &meta)
+		}
+	}
+
+	backoff := *fetchBackoff
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		meta, retry, err = download(ctx, u, files, fileName, meta)
+		if err == nil {
+			break
+		}
+		if !retry || attempt >= *fetchRetries || ctx.Err() != nil {
+			return fmt.Errorf("unable to download '%s' due: %v", redactURL(rawURL), err)
+		}
+		log.Printf("download of '%s' failed due: %v, retrying in %s", redactURL(rawURL), err, backoff)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("unable to download '%s' due: %v", redactURL(rawURL), ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+
+	if err := files.MkdirAll(filepath.Dir(metaFileName)); err != nil {
+		return err
+	}
+	data, _ := json.Marshal(meta)
+	return files.Write(metaFileName, func(w io.Writer) error { _, err := w.Write(data); return err })
+}
+
+// fetchedName returns the name of the file downloaded from u: the last
+// element of its path, starting with the source prefix so the run reads it,
+// and ending with a hash of the URL before the extension, so files of the
+// same name from different URLs do not replace each other, e.g
+// "CMS-guide-db391992.xml" for https://example.com/epg/guide.xml.
+func fetchedName(u *url.URL, prefix string) (string, error) {
+	name := path.Base(u.Path)
+	if name == "/" || name == "." || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("source URL '%s' has no file name", u.Redacted())
+	}
+	if !strings.HasPrefix(name, prefix) {
+		name = prefix + "-" + name
+	}
+	key := *u
+	key.User = nil
+	h := fnv.New32a()
+	h.Write([]byte(key.String()))
+	ext := path.Ext(name)
+	return fmt.Sprintf("%s-%08x%s", strings.TrimSuffix(name, ext), h.Sum32(), ext), nil
+}
+
+// download makes a single conditional request for u, writing the body to
+// fileName of the store. It reports whether a failed request is worth
+// retrying.
+func download(ctx context.Context, u *url.URL, files store.OutputStore, fileName string, meta fetchMeta) (fetchMeta, bool, error) {
+	client := &http.Client{Timeout: *fetchTimeout}
+	target, sign, err := fetchTarget(u)
+	if err != nil {
+		return meta, false, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return meta, false, err
+	}
+	if meta.ETag != "" {
+		req.Header.Set("If-None-Match", meta.ETag)
+	}
+	if meta.LastModified != "" {
+		req.Header.Set("If-Modified-Since", meta.LastModified)
+	}
+	if sign != nil {
+		sign(req, nil)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return meta, ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		log.Printf("source '%s' not modified", u.Redacted())
+		return meta, false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return meta, true, fmt.Errorf("unexpected status %s", resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		return meta, false, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	// The body is written at once, so a failed download leaves the previous
+	// file in place.
+	var interrupted bool
+	err = files.Write(fileName, func(w io.Writer) error {
+		_, err := io.Copy(w, resp.Body)
+		interrupted = err != nil
+		return err
+	})
+	if err != nil {
+		return meta, interrupted, err
+	}
+	log.Printf("downloaded '%s' to '%s'", u.Redacted(), fileName)
+	return fetchMeta{ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}, false, nil
+}
+
+// fetchTarget returns the HTTP URL a source URL is downloaded from and the
+// function signing the request, nil when it is not signed. s3://bucket/key
+// URLs are signed like the uploads of putS3.
+func fetchTarget(u *url.URL) (*url.URL, func(req *http.Request, body []byte), error) {
+	if u.Scheme != "s3" {
+		return u, nil, nil
+	}
+	bucket, err := store.NewS3(u.Host, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	target, err := bucket.ObjectURL(strings.TrimPrefix(u.Path, "/"))
+	if err != nil {
+		return nil, nil, err
+	}
+	return target, bucket.Sign, nil
+}
+
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
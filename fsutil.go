@@ -0,0 +1,16 @@
+package main
+
+import "io"
+
+// mkdirAll creates the directory with its parents in the output store.
+func mkdirAll(dir string) error {
+	return outputFiles.MkdirAll(dir)
+}
+
+// writeFileAtomic writes the file into the output store, so readers of the
+// output never see a partly written file.
+func writeFileAtomic(fileName string, write func(w io.Writer) error) error {
+	acquireFile()
+	defer releaseFile()
+	return outputFiles.Write(fileName, write)
+}
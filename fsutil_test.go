@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	content := func(s string) func(w io.Writer) error {
+		return func(w io.Writer) error { _, err := io.WriteString(w, s); return err }
+	}
+	failed := errors.New("failed")
+	tests := []struct {
+		name    string
+		before  string
+		write   func(w io.Writer) error
+		want    string
+		wantErr error
+	}{
+		{"new file", "", content("new"), "new", nil},
+		{"replaced file", "old", content("new"), "new", nil},
+		{"failed write keeps the file", "old", func(w io.Writer) error { io.WriteString(w, "partial"); return failed }, "old", failed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "out", "tenant")
+			if err := mkdirAll(dir); err != nil {
+				t.Fatal(err)
+			}
+			fileName := filepath.Join(dir, "n_events_1.xml")
+			if tt.before != "" {
+				if err := writeFileAtomic(fileName, content(tt.before)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := writeFileAtomic(fileName, tt.write); err != tt.wantErr {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if data, err := ioutil.ReadFile(fileName); err != nil || string(data) != tt.want {
+				t.Errorf("read %q, %v", data, err)
+			}
+			if files, err := ioutil.ReadDir(dir); err != nil || len(files) != 1 {
+				t.Errorf("left temporary files %v, %v", files, err)
+			}
+		})
+	}
+}
+
+func TestMkdirAll(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "a", "b")
+	for i := 0; i < 2; i++ {
+		if err := mkdirAll(dir); err != nil {
+			t.Fatalf("attempt %d: %v", i+1, err)
+		}
+	}
+	file := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := mkdirAll(file); err == nil {
+		t.Error("created a directory over a file")
+	}
+}
@@ -0,0 +1,557 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// The GraphQL endpoint supports the subset of the language needed to query
+// the published EPG: a single query operation, named or anonymous, with
+// variable definitions and their defaults, nested selections, aliases,
+// __typename and arguments given as scalar literals or variables. Fragments,
+// directives, list and object values, block strings, introspection,
+// mutations and subscriptions are rejected with an error naming them. The
+// schema is
+//
+//	type Query {
+//	  channels(id: String): [Channel]
+//	  events(channel: String, from: String, to: String, text: String): [Event]
+//	}
+//	type Channel { id: String, name: String, events(from: String, to: String, text: String): [Event] }
+//
+// where Event has the fields of the JSON event output, e.g time_from or tags,
+// and channel, the ID of its channel.
+
+type gqlField struct {
+	Alias     string
+	Name      string
+	Args      map[string]interface{}
+	Selection []*gqlField
+}
+
+type gqlParser struct {
+	src  []rune
+	pos  int
+	vars map[string]interface{}
+	// defined are the variables defined by the operation.
+	defined map[string]bool
+}
+
+func parseGraphQL(query string, vars map[string]interface{}) ([]*gqlField, error) {
+	p := &gqlParser{src: []rune(query), vars: make(map[string]interface{}), defined: make(map[string]bool)}
+	switch name := p.peekName(); name {
+	case "query":
+		p.name()
+		p.name() // the operation name, if any
+		if p.peek() == '(' {
+			if err := p.variableDefinitions(); err != nil {
+				return nil, err
+			}
+		}
+		if p.peek() == '@' {
+			return nil, fmt.Errorf("directives are not supported")
+		}
+	case "":
+	case "fragment":
+		return nil, fmt.Errorf("fragments are not supported")
+	default:
+		return nil, fmt.Errorf("unsupported operation '%s', only queries are supported", name)
+	}
+	for name, v := range vars {
+		if p.defined[name] {
+			p.vars[name] = v
+		}
+	}
+	sel, err := p.selection()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != 0 {
+		if name := p.peekName(); name == "fragment" {
+			return nil, fmt.Errorf("fragments are not supported")
+		}
+		return nil, fmt.Errorf("only a single operation is supported, unexpected '%c' at %d", p.src[p.pos], p.pos)
+	}
+	return sel, nil
+}
+
+// variableDefinitions parses the variable definitions of the operation, e.g
+// ($id: String = "1", $from: String!), recording the defaults as the values
+// of the variables missing in the request.
+func (p *gqlParser) variableDefinitions() error {
+	p.pos++
+	for p.peek() != ')' {
+		if err := p.expect('$'); err != nil {
+			return err
+		}
+		name := p.name()
+		if name == "" {
+			return fmt.Errorf("expected variable name at %d", p.pos)
+		}
+		if err := p.expect(':'); err != nil {
+			return err
+		}
+		if err := p.variableType(); err != nil {
+			return err
+		}
+		p.defined[name] = true
+		if p.peek() == '=' {
+			p.pos++
+			v, err := p.value()
+			if err != nil {
+				return err
+			}
+			p.vars[name] = v
+		}
+	}
+	p.pos++
+	return nil
+}
+
+// variableType skips the type of a variable definition, e.g [String!]!.
+func (p *gqlParser) variableType() error {
+	if p.peek() == '[' {
+		p.pos++
+		if err := p.variableType(); err != nil {
+			return err
+		}
+		if err := p.expect(']'); err != nil {
+			return err
+		}
+	} else if p.name() == "" {
+		return fmt.Errorf("expected type at %d", p.pos)
+	}
+	if p.peek() == '!' {
+		p.pos++
+	}
+	return nil
+}
+
+func (p *gqlParser) skip() {
+	for p.pos < len(p.src) {
+		r := p.src[p.pos]
+		switch {
+		case r == '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		case unicode.IsSpace(r) || r == ',':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (p *gqlParser) peek() rune {
+	p.skip()
+	if p.pos >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *gqlParser) expect(r rune) error {
+	if p.peek() != r {
+		return fmt.Errorf("expected '%c' at %d", r, p.pos)
+	}
+	p.pos++
+	return nil
+}
+
+func isNameRune(r rune, first bool) bool {
+	return r == '_' || unicode.IsLetter(r) || (!first && unicode.IsDigit(r))
+}
+
+func (p *gqlParser) peekName() string {
+	p.skip()
+	end := p.pos
+	for end < len(p.src) && isNameRune(p.src[end], end == p.pos) {
+		end++
+	}
+	return string(p.src[p.pos:end])
+}
+
+func (p *gqlParser) name() string {
+	n := p.peekName()
+	p.pos += len([]rune(n))
+	return n
+}
+
+func (p *gqlParser) selection() ([]*gqlField, error) {
+	if err := p.expect('{'); err != nil {
+		return nil, err
+	}
+	var fields []*gqlField
+	for p.peek() != '}' {
+		switch p.peek() {
+		case 0:
+			return nil, fmt.Errorf("unterminated selection")
+		case '.':
+			return nil, fmt.Errorf("fragments are not supported")
+		}
+		f := &gqlField{Name: p.name()}
+		if f.Name == "" {
+			return nil, fmt.Errorf("expected field name at %d", p.pos)
+		}
+		if p.peek() == ':' {
+			p.pos++
+			f.Alias = f.Name
+			if f.Name = p.name(); f.Name == "" {
+				return nil, fmt.Errorf("expected field name at %d", p.pos)
+			}
+		}
+		if f.Alias == "" {
+			f.Alias = f.Name
+		}
+		if p.peek() == '(' {
+			args, err := p.arguments()
+			if err != nil {
+				return nil, err
+			}
+			f.Args = args
+		}
+		if p.peek() == '@' {
+			return nil, fmt.Errorf("directives are not supported")
+		}
+		if p.peek() == '{' {
+			sel, err := p.selection()
+			if err != nil {
+				return nil, err
+			}
+			f.Selection = sel
+		}
+		fields = append(fields, f)
+	}
+	p.pos++
+	return fields, nil
+}
+
+func (p *gqlParser) arguments() (map[string]interface{}, error) {
+	p.pos++
+	args := make(map[string]interface{})
+	for p.peek() != ')' {
+		name := p.name()
+		if name == "" {
+			return nil, fmt.Errorf("expected argument name at %d", p.pos)
+		}
+		if err := p.expect(':'); err != nil {
+			return nil, err
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		args[name] = v
+	}
+	p.pos++
+	return args, nil
+}
+
+func (p *gqlParser) value() (interface{}, error) {
+	switch r := p.peek(); {
+	case r == '$':
+		p.pos++
+		name := p.name()
+		if !p.defined[name] {
+			return nil, fmt.Errorf("variable '$%s' is not defined by the operation", name)
+		}
+		return p.vars[name], nil
+	case r == '[' || r == '{':
+		return nil, fmt.Errorf("list and object values are not supported")
+	case strings.HasPrefix(string(p.src[p.pos:]), `"""`):
+		return nil, fmt.Errorf("block strings are not supported")
+	case r == '"':
+		var b strings.Builder
+		for p.pos++; p.pos < len(p.src); p.pos++ {
+			c := p.src[p.pos]
+			if c == '"' {
+				p.pos++
+				return b.String(), nil
+			}
+			if c == '\\' && p.pos+1 < len(p.src) {
+				p.pos++
+				c = p.src[p.pos]
+			}
+			b.WriteRune(c)
+		}
+		return nil, fmt.Errorf("unterminated string")
+	case r == '-' || unicode.IsDigit(r):
+		start := p.pos
+		for p.pos++; p.pos < len(p.src) && (unicode.IsDigit(p.src[p.pos]) || p.src[p.pos] == '.'); p.pos++ {
+		}
+		var n float64
+		_, err := fmt.Sscan(string(p.src[start:p.pos]), &n)
+		return n, err
+	default:
+		switch n := p.name(); n {
+		case "true", "false":
+			return n == "true", nil
+		case "null":
+			return nil, nil
+		case "":
+			return nil, fmt.Errorf("expected value at %d", p.pos)
+		default:
+			return n, nil
+		}
+	}
+}
+
+// gqlObject is a JSON object keeping the order of the selected fields.
+type gqlObject []gqlValue
+
+type gqlValue struct {
+	Key   string
+	Value interface{}
+}
+
+func (o gqlObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, kv := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(kv.Key)
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := json.Marshal(kv.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func stringArg(args map[string]interface{}, name string) string {
+	s, _ := args[name].(string)
+	return s
+}
+
+// gqlFields are the fields of the object types by type, with the type of the
+// objects they select or "" for scalars. The fields of Event are added from
+// the JSON event output.
+var gqlFields = map[string]map[string]string{
+	"Query":   {"channels": "Channel", "events": "Event"},
+	"Channel": {"id": "", "name": "", "events": "Event"},
+	"Event":   {"channel": ""},
+}
+
+// gqlArguments are the arguments of the fields by type and field name, all of
+// them String.
+var gqlArguments = map[string][]string{
+	"Query.channels": {"id"},
+	"Query.events":   {"channel", "from", "to", "text"},
+	"Channel.events": {"from", "to", "text"},
+}
+
+// validateGraphQL checks the selection of the type against the schema before
+// anything is resolved, so a query is rejected even when it matches no
+// channels or events.
+func validateGraphQL(typeName string, fields []*gqlField) error {
+	for _, f := range fields {
+		var object string
+		switch f.Name {
+		case "__typename":
+		case "__schema", "__type":
+			return fmt.Errorf("introspection is not supported")
+		default:
+			var ok bool
+			if object, ok = gqlFields[typeName][f.Name]; !ok {
+				return fmt.Errorf("unknown field '%s' on %s", f.Name, typeName)
+			}
+		}
+		known := gqlArguments[typeName+"."+f.Name]
+		for name, v := range f.Args {
+			found := false
+			for _, k := range known {
+				found = found || k == name
+			}
+			if !found {
+				return fmt.Errorf("unknown argument '%s' of field '%s' on %s", name, f.Name, typeName)
+			}
+			if _, ok := v.(string); !ok && v != nil {
+				return fmt.Errorf("argument '%s' of field '%s' on %s must be a String", name, f.Name, typeName)
+			}
+		}
+		switch {
+		case object != "" && len(f.Selection) == 0:
+			return fmt.Errorf("field '%s' on %s must have a selection", f.Name, typeName)
+		case object == "" && len(f.Selection) > 0:
+			return fmt.Errorf("field '%s' on %s cannot have a selection", f.Name, typeName)
+		case object != "":
+			if err := validateGraphQL(object, f.Selection); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func executeGraphQL(fields []*gqlField, channels []*outputChannel) (gqlObject, error) {
+	if err := validateGraphQL("Query", fields); err != nil {
+		return nil, err
+	}
+	var result gqlObject
+	for _, f := range fields {
+		switch f.Name {
+		case "__typename":
+			result = append(result, gqlValue{f.Alias, "Query"})
+		case "channels":
+			list := make([]gqlObject, 0)
+			for _, c := range channels {
+				if id := stringArg(f.Args, "id"); id != "" && id != c.ID {
+					continue
+				}
+				obj, err := resolveChannel(f.Selection, c)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, obj)
+			}
+			result = append(result, gqlValue{f.Alias, list})
+		case "events":
+			list := make([]gqlObject, 0)
+			for _, c := range channels {
+				if id := stringArg(f.Args, "channel"); id != "" && id != c.ID {
+					continue
+				}
+				events, err := resolveEvents(f, c)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, events...)
+			}
+			result = append(result, gqlValue{f.Alias, list})
+		}
+	}
+	return result, nil
+}
+
+func resolveChannel(fields []*gqlField, c *outputChannel) (gqlObject, error) {
+	var obj gqlObject
+	for _, f := range fields {
+		switch f.Name {
+		case "__typename":
+			obj = append(obj, gqlValue{f.Alias, "Channel"})
+		case "id":
+			obj = append(obj, gqlValue{f.Alias, c.ID})
+		case "name":
+			obj = append(obj, gqlValue{f.Alias, c.Name})
+		case "events":
+			events, err := resolveEvents(f, c)
+			if err != nil {
+				return nil, err
+			}
+			obj = append(obj, gqlValue{f.Alias, events})
+		}
+	}
+	return obj, nil
+}
+
+func resolveEvents(f *gqlField, c *outputChannel) ([]gqlObject, error) {
+	var from, to time.Time
+	var err error
+	if v := stringArg(f.Args, "from"); v != "" {
+		if from, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, fmt.Errorf("invalid from due: %v", err)
+		}
+	}
+	if v := stringArg(f.Args, "to"); v != "" {
+		if to, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, fmt.Errorf("invalid to due: %v", err)
+		}
+	}
+	text := strings.ToLower(stringArg(f.Args, "text"))
+
+	list := make([]gqlObject, 0)
+	for _, e := range c.Events.Values {
+		if !eventInRange(e, from, to) {
+			continue
+		}
+		if text != "" && !strings.Contains(strings.ToLower(e.Name), text) && !strings.Contains(strings.ToLower(e.Description), text) {
+			continue
+		}
+		data, err := json.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+		var values map[string]interface{}
+		json.Unmarshal(data, &values)
+
+		var obj gqlObject
+		for _, sf := range f.Selection {
+			switch sf.Name {
+			case "__typename":
+				obj = append(obj, gqlValue{sf.Alias, "Event"})
+			case "channel":
+				obj = append(obj, gqlValue{sf.Alias, c.ID})
+			default:
+				obj = append(obj, gqlValue{sf.Alias, values[sf.Name]})
+			}
+		}
+		list = append(list, obj)
+	}
+	return list, nil
+}
+
+func init() {
+	t := reflect.TypeOf(outputEvent{})
+	for i := 0; i < t.NumField(); i++ {
+		if name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]; name != "" && name != "-" {
+			gqlFields["Event"][name] = ""
+		}
+	}
+}
+
+// handleGraphQL serves POST /graphql with a {"query": ..., "variables": ...}
+// body, as well as GET /graphql?query=.
+func (s *server) handleGraphQL(w http.ResponseWriter, req *http.Request) {
+	var body struct {
+		Query     string                 `json:"query"`
+		Variables map[string]interface{} `json:"variables"`
+	}
+	switch req.Method {
+	case http.MethodGet:
+		body.Query = req.URL.Query().Get("query")
+	case http.MethodPost:
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			writeGraphQLError(w, fmt.Errorf("invalid request due: %v", err))
+			return
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	fields, err := parseGraphQL(body.Query, body.Variables)
+	if err != nil {
+		writeGraphQLError(w, err)
+		return
+	}
+	channels, err := loadPublishedChannels(*outputDir)
+	if err != nil {
+		writeGraphQLError(w, err)
+		return
+	}
+	data, err := executeGraphQL(fields, channels)
+	if err != nil {
+		writeGraphQLError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": data})
+}
+
+func writeGraphQLError(w http.ResponseWriter, err error) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"errors": []map[string]string{{"message": err.Error()}},
+	})
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func graphQLChannels() []*outputChannel {
+	return []*outputChannel{
+		{ID: "1", Name: "One", Events: outputEvents{Values: []outputEvent{
+			{ID: "11", Name: "News", StartTime: "2024-01-01T18:00:00Z", EndTime: "2024-01-01T18:30:00Z", Description: "Daily"},
+			{ID: "12", Name: "Film", StartTime: "2024-01-01T18:30:00Z", EndTime: "2024-01-01T20:00:00Z"},
+		}}},
+		{ID: "2", Name: "Two", Events: outputEvents{Values: []outputEvent{
+			{ID: "21", Name: "Sport", StartTime: "2024-01-01T18:00:00Z", EndTime: "2024-01-01T19:00:00Z",
+				Tags: &outputTags{Values: []string{"live"}}},
+		}}},
+	}
+}
+
+func TestGraphQL(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		vars  map[string]interface{}
+		want  string
+	}{
+		{"shorthand", `{ channels { id name } }`,
+			nil, `{"channels":[{"id":"1","name":"One"},{"id":"2","name":"Two"}]}`},
+		{"named query", `query Lineup { channels(id: "2") { name } }`,
+			nil, `{"channels":[{"name":"Two"}]}`},
+		{"aliases and comments", "{ # the lineup\n first: channels(id: \"1\") { label: name }, second: channels(id: \"2\") { name } }",
+			nil, `{"first":[{"label":"One"}],"second":[{"name":"Two"}]}`},
+		{"nested events", `{ channels(id: "1") { id events(from: "2024-01-01T18:40:00Z") { id name } } }`,
+			nil, `{"channels":[{"id":"1","events":[{"id":"12","name":"Film"}]}]}`},
+		{"events of all channels", `{ events(to: "2024-01-01T18:10:00Z") { channel name tags } }`,
+			nil, `{"events":[{"channel":"1","name":"News","tags":null},{"channel":"2","name":"Sport","tags":["live"]}]}`},
+		{"text", `{ events(text: "DAILY") { name description } }`,
+			nil, `{"events":[{"name":"News","description":"Daily"}]}`},
+		{"variables", `query Q($id: String!, $text: String) { events(channel: $id, text: $text) { id } }`,
+			map[string]interface{}{"id": "1", "text": "film"}, `{"events":[{"id":"12"}]}`},
+		{"variable default", `query Q($id: String = "2") { channels(id: $id) { id } }`,
+			nil, `{"channels":[{"id":"2"}]}`},
+		{"variable overriding its default", `query Q($id: String = "2") { channels(id: $id) { id } }`,
+			map[string]interface{}{"id": "1"}, `{"channels":[{"id":"1"}]}`},
+		{"null argument", `{ channels(id: null) { id } }`,
+			nil, `{"channels":[{"id":"1"},{"id":"2"}]}`},
+		{"typename", `{ __typename channels(id: "2") { __typename events { __typename id } } }`,
+			nil, `{"__typename":"Query","channels":[{"__typename":"Channel","events":[{"__typename":"Event","id":"21"}]}]}`},
+		{"escaped string", `{ channels(id: "\"1\"") { id } }`,
+			nil, `{"channels":[]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields, err := parseGraphQL(tt.query, tt.vars)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := executeGraphQL(fields, graphQLChannels())
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := json.Marshal(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGraphQLErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		// unsupported language features
+		{`mutation { channels { id } }`, "unsupported operation 'mutation', only queries are supported"},
+		{`subscription { events { id } }`, "unsupported operation 'subscription'"},
+		{`fragment F on Channel { id }`, "fragments are not supported"},
+		{`{ channels { ...F } }`, "fragments are not supported"},
+		{`{ channels { ... on Channel { id } } }`, "fragments are not supported"},
+		{`{ channels { id } } fragment F on Channel { id }`, "fragments are not supported"},
+		{`{ channels @include(if: true) { id } }`, "directives are not supported"},
+		{`query Q @cached { channels { id } }`, "directives are not supported"},
+		{`{ channels { id } } { events { id } }`, "only a single operation is supported"},
+		{`{ channels(id: ["1"]) { id } }`, "list and object values are not supported"},
+		{`{ channels(id: {a: 1}) { id } }`, "list and object values are not supported"},
+		{`{ channels(id: """1""") { id } }`, "block strings are not supported"},
+		{`{ __schema { types { name } } }`, "introspection is not supported"},
+		{`{ channels { __type(name: "Event") { name } } }`, "introspection is not supported"},
+		// syntax
+		{`{ channels { id }`, "unterminated selection"},
+		{`{ channels(id: "1) { id } }`, "unterminated string"},
+		{`{ channels(: "1") { id } }`, "expected argument name"},
+		{`{ channels(id "1") { id } }`, "expected ':'"},
+		{`channels { id }`, "unsupported operation 'channels'"},
+		{`query Q($id) { channels { id } }`, "expected ':'"},
+		{`query Q(id: String) { channels { id } }`, "expected '$'"},
+		// validation
+		{`{ channels(id: $id) { id } }`, "variable '$id' is not defined by the operation"},
+		{`{ programmes { id } }`, "unknown field 'programmes' on Query"},
+		{`{ channels { logo } }`, "unknown field 'logo' on Channel"},
+		{`{ events { rating } }`, "unknown field 'rating' on Event"},
+		{`{ channels(name: "One") { id } }`, "unknown argument 'name' of field 'channels' on Query"},
+		{`{ channels { events(channel: "1") { id } } }`, "unknown argument 'channel' of field 'events' on Channel"},
+		{`{ channels(id: 1) { id } }`, "argument 'id' of field 'channels' on Query must be a String"},
+		{`{ channels }`, "field 'channels' on Query must have a selection"},
+		{`{ channels { events } }`, "field 'events' on Channel must have a selection"},
+		{`{ channels { id { value } } }`, "field 'id' on Channel cannot have a selection"},
+		{`{ events { name(lang: "bg") } }`, "unknown argument 'lang' of field 'name' on Event"},
+		{`{ events(from: "yesterday") { id } }`, "invalid from"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			fields, err := parseGraphQL(tt.query, nil)
+			if err == nil {
+				_, err = executeGraphQL(fields, graphQLChannels())
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGraphQLUnknownEventFieldWithoutEvents(t *testing.T) {
+	fields, err := parseGraphQL(`{ events(channel: "none") { rating } }`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := executeGraphQL(fields, graphQLChannels()); err == nil {
+		t.Error("an unknown field was accepted when no event matched")
+	}
+}
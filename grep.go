@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// grepMatch is a programme found by the grep command.
+type grepMatch struct {
+	File        string `json:"file"`
+	Channel     string `json:"channel"`
+	Start       string `json:"start"`
+	Stop        string `json:"stop"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+}
+
+// grepCommand searches the parsed programmes of the source files, which
+// unlike a text search is not confused by multiline XML:
+//
+//	epgtool -dataDir data grep -title "Новини" -channel Alfa
+func grepCommand(args []string) {
+	fs := flag.NewFlagSet("grep", flag.ExitOnError)
+	titlePattern := fs.String("title", "", "regular expression matched case-insensitively against the titles in all languages")
+	descPattern := fs.String("desc", "", "regular expression matched case-insensitively against the description")
+	channelName := fs.String("channel", "", "source channel name, or the ID or name of a channel from the channels file")
+	jsonOutput := fs.Bool("json", false, "print the matches as JSON lines")
+	fs.Parse(args)
+
+	title, err := compileGrepPattern(*titlePattern)
+	if err != nil {
+		log.Fatalf("invalid title pattern due: %v", err)
+	}
+	desc, err := compileGrepPattern(*descPattern)
+	if err != nil {
+		log.Fatalf("invalid desc pattern due: %v", err)
+	}
+	channels := grepChannelNames(*channelName)
+
+	j := defaultJob()
+	files, err := listSourceFiles(j.DataDir, j.SourcePrefix, j.SourceFileLimit)
+	if err != nil {
+		log.Fatal(err)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	var matches int
+	for _, fname := range files {
+		s, err := readSource(fname, sourceWindow{})
+		if err != nil {
+			log.Print(err)
+			continue
+		}
+		for _, e := range s.ProgramList {
+			if channels != nil && !channels[strings.ToLower(e.ChannelName)] {
+				continue
+			}
+			if !grepTitles(title, e.Title) || (desc != nil && !desc.MatchString(e.Description.Name)) {
+				continue
+			}
+			matches++
+			m := grepMatch{File: filepath.Base(fname), Channel: e.ChannelName, Start: e.Start, Stop: e.Stop,
+				Title: titleOf(e, *preferredLang), Description: strings.TrimSpace(e.Description.Name)}
+			if *jsonOutput {
+				enc.Encode(m)
+				continue
+			}
+			fmt.Printf("%s: %s %s - %s %q\n", m.File, m.Channel, m.Start, m.Stop, m.Title)
+		}
+	}
+	if !*jsonOutput {
+		fmt.Printf("%d matches in %d source files\n", matches, len(files))
+	}
+}
+
+func compileGrepPattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile("(?i)" + pattern)
+}
+
+func grepTitles(pattern *regexp.Regexp, titles []title) bool {
+	if pattern == nil {
+		return true
+	}
+	for _, t := range titles {
+		if pattern.MatchString(t.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// grepChannelNames returns the lower cased source channel names selected by
+// the argument, nil when all channels are searched. A channel of the channels
+// file selects the source channels merged into it.
+func grepChannelNames(arg string) map[string]bool {
+	if arg == "" {
+		return nil
+	}
+	names := map[string]bool{strings.ToLower(arg): true}
+	channels, err := readRequestedChannels(*channelsFile)
+	if err != nil {
+		return names
+	}
+	for _, c := range channels {
+		if c.ID == arg || strings.EqualFold(c.Name, arg) {
+			for _, other := range channels {
+				if other.ID == c.ID {
+					names[strings.ToLower(other.Name)] = true
+				}
+			}
+		}
+	}
+	return names
+}
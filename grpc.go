@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/mgenov/epgtool/epgpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcServer serves the published channels of the output directory over the
+// EPG gRPC service of epgpb/epg.proto.
+type grpcServer struct {
+	epgpb.UnimplementedEPGServer
+}
+
+// newGRPCServer returns the gRPC server of the EPG service, which requires the
+// token as bearer authorization metadata when it is not empty.
+func newGRPCServer(token string) *grpc.Server {
+	var opts []grpc.ServerOption
+	if token != "" {
+		opts = append(opts, grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, h grpc.UnaryHandler) (interface{}, error) {
+			if err := checkGRPCToken(ctx, token); err != nil {
+				return nil, err
+			}
+			return h(ctx, req)
+		}), grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, h grpc.StreamHandler) error {
+			if err := checkGRPCToken(ss.Context(), token); err != nil {
+				return err
+			}
+			return h(srv, ss)
+		}))
+	}
+	s := grpc.NewServer(opts...)
+	epgpb.RegisterEPGServer(s, &grpcServer{})
+	return s
+}
+
+// checkGRPCToken checks the "authorization: Bearer <token>" metadata of the
+// call.
+func checkGRPCToken(ctx context.Context, token string) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var given string
+	if values := md.Get("authorization"); len(values) > 0 {
+		given = strings.TrimPrefix(values[0], "Bearer ")
+	}
+	if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+		return status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	return nil
+}
+
+// serveGRPC listens on addr and serves the EPG service until it fails.
+func serveGRPC(addr, token string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	if token == "" && !loopbackAddr(addr) {
+		log.Printf("Warning: %s accepts remote connections without EPGTOOL_API_TOKEN\n", addr)
+	}
+	log.Printf("gRPC listening on %s\n", addr)
+	return newGRPCServer(token).Serve(l)
+}
+
+// ListChannels lists the published channels like GET /api/channels.
+func (s *grpcServer) ListChannels(ctx context.Context, _ *epgpb.ListChannelsRequest) (*epgpb.ListChannelsResponse, error) {
+	channels, err := loadPublishedChannels(*outputDir)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	result := &epgpb.ListChannelsResponse{}
+	for _, c := range channels {
+		result.Channels = append(result.Channels, &epgpb.Channel{Id: c.ID, Name: c.Name, Events: int32(len(c.Events.Values))})
+	}
+	return result, nil
+}
+
+// StreamEvents streams the events of the channel overlapping the time range
+// of the request like GET /api/channels/{id}/events.
+func (s *grpcServer) StreamEvents(req *epgpb.StreamEventsRequest, stream epgpb.EPG_StreamEventsServer) error {
+	var from, to time.Time
+	if req.From != nil {
+		if err := req.From.CheckValid(); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid from due: %v", err)
+		}
+		from = req.From.AsTime()
+	}
+	if req.To != nil {
+		if err := req.To.CheckValid(); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid to due: %v", err)
+		}
+		to = req.To.AsTime()
+	}
+
+	channels, err := loadPublishedChannels(*outputDir)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	for _, c := range channels {
+		if c.ID != req.Channel {
+			continue
+		}
+		for _, e := range c.Events.Values {
+			if !eventInRange(e, from, to) {
+				continue
+			}
+			if err := stream.Send(protoEvent(e)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return status.Errorf(codes.NotFound, "channel '%s' is not published", req.Channel)
+}
+
+// protoEvent converts the published event to its gRPC message.
+func protoEvent(e outputEvent) *epgpb.Event {
+	start, end, _ := eventTimes(e)
+	pe := &epgpb.Event{
+		Id:                  e.ID,
+		Name:                e.Name,
+		Start:               timestamppb.New(start),
+		End:                 timestamppb.New(end),
+		LocalDate:           e.LocalDate,
+		Perex:               e.Perex,
+		Description:         e.Description,
+		Actors:              peopleList(e.ActorList, e.Actors),
+		Directors:           peopleList(e.DirectorList, e.Directors),
+		ProductionYear:      e.ProductionYear,
+		OriginalAirDate:     e.OriginalAirDate,
+		ProductionCountries: e.ProductionCountries,
+		VideoQuality:        e.VideoQuality,
+		VideoAspect:         e.VideoAspect,
+		Audio:               e.Audio,
+		Url:                 e.URL,
+		Crid:                e.CRID,
+		ProgramId:           e.ProgramID,
+		GroupId:             e.GroupID,
+		Source:              e.SourceFile,
+		Provider:            e.Provider,
+	}
+	if e.Tags != nil {
+		pe.Tags = e.Tags.Values
+	}
+	return pe
+}
+
+// peopleList returns the people of an event written either as elements or
+// joined into a single one.
+func peopleList(list []string, joined string) []string {
+	if len(list) > 0 || joined == "" {
+		return list
+	}
+	return strings.Split(joined, ", ")
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mgenov/epgtool/epgpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcClient publishes the Nickelodeon channel of source.xml and returns a
+// client of the EPG service serving it, requiring the token when it is not
+// empty.
+func grpcClient(t *testing.T, token string) epgpb.EPGClient {
+	dir := t.TempDir()
+	source, err := ioutil.ReadFile("source.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "data"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "data", "source.xml"), source, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "channels.csv"), []byte("151,\"Nickelodeon\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	j := job{DataDir: filepath.Join(dir, "data"), SourceFileLimit: 1, ChannelsFile: filepath.Join(dir, "channels.csv"),
+		OutputDir: filepath.Join(dir, "out")}
+	if _, err := j.run(newSourceCache(), newRunReport(ioutil.Discard)); err != nil {
+		t.Fatal(err)
+	}
+	prev := *outputDir
+	*outputDir = j.OutputDir
+	t.Cleanup(func() { *outputDir = prev })
+
+	l := bufconn.Listen(1 << 20)
+	s := newGRPCServer(token)
+	go s.Serve(l)
+	t.Cleanup(s.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return l.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return epgpb.NewEPGClient(conn)
+}
+
+func streamedEvents(ctx context.Context, t *testing.T, client epgpb.EPGClient, req *epgpb.StreamEventsRequest) ([]*epgpb.Event, error) {
+	stream, err := client.StreamEvents(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []*epgpb.Event
+	for {
+		e, err := stream.Recv()
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return events, err
+		}
+		events = append(events, e)
+	}
+}
+
+func TestGRPCService(t *testing.T) {
+	client := grpcClient(t, "")
+	ctx := context.Background()
+
+	channels, err := client.ListChannels(ctx, &epgpb.ListChannelsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(channels.Channels) != 1 || channels.Channels[0].Id != "151" || channels.Channels[0].Events == 0 {
+		t.Fatalf("got channels %v", channels.Channels)
+	}
+	c := channels.Channels[0]
+
+	all, err := streamedEvents(ctx, t, client, &epgpb.StreamEventsRequest{Channel: c.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != int(c.Events) {
+		t.Fatalf("streamed %d of %d events", len(all), c.Events)
+	}
+	for _, e := range all {
+		if e.Id == "" || e.Name == "" || !e.End.AsTime().After(e.Start.AsTime()) {
+			t.Errorf("got event %v", e)
+		}
+	}
+
+	from := all[1].Start.AsTime()
+	to := all[2].End.AsTime()
+	ranged, err := streamedEvents(ctx, t, client, &epgpb.StreamEventsRequest{Channel: c.Id,
+		From: timestamppb.New(from), To: timestamppb.New(to.Add(-time.Second))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ranged) != 2 || ranged[0].Id != all[1].Id || ranged[1].Id != all[2].Id {
+		t.Errorf("got events %v in [%v, %v)", ranged, from, to)
+	}
+}
+
+func TestGRPCErrors(t *testing.T) {
+	client := grpcClient(t, "")
+	tests := []struct {
+		name string
+		req  *epgpb.StreamEventsRequest
+		code codes.Code
+	}{
+		{"unknown channel", &epgpb.StreamEventsRequest{Channel: "999"}, codes.NotFound},
+		{"invalid from", &epgpb.StreamEventsRequest{Channel: "151", From: &timestamppb.Timestamp{Nanos: -1}}, codes.InvalidArgument},
+		{"invalid to", &epgpb.StreamEventsRequest{Channel: "151", To: &timestamppb.Timestamp{Seconds: 1 << 62}}, codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := streamedEvents(context.Background(), t, client, tt.req)
+			if status.Code(err) != tt.code {
+				t.Errorf("got %v, want %v", err, tt.code)
+			}
+		})
+	}
+}
+
+func TestGRPCToken(t *testing.T) {
+	client := grpcClient(t, "secret")
+	tests := []struct {
+		name          string
+		authorization string
+		code          codes.Code
+	}{
+		{"missing", "", codes.Unauthenticated},
+		{"wrong", "Bearer guess", codes.Unauthenticated},
+		{"bearer", "Bearer secret", codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.authorization != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, "authorization", tt.authorization)
+			}
+			if _, err := client.ListChannels(ctx, &epgpb.ListChannelsRequest{}); status.Code(err) != tt.code {
+				t.Errorf("ListChannels got %v, want %v", err, tt.code)
+			}
+			if _, err := streamedEvents(ctx, t, client, &epgpb.StreamEventsRequest{Channel: "151"}); status.Code(err) != tt.code {
+				t.Errorf("StreamEvents got %v, want %v", err, tt.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"embed"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+//go:embed scaffold
+var scaffold embed.FS
+
+// initCommand bootstraps a working directory with the embedded commented
+// config, an example channels mapping and a sample source file:
+//
+//	epgtool init -dir /srv/epg
+func initCommand(args []string) {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	dir := fs.String("dir", ".", "directory to initialize")
+	force := fs.Bool("force", false, "overwrite existing files")
+	fs.Parse(args)
+
+	for _, d := range []string{"data", "out"} {
+		if err := os.MkdirAll(filepath.Join(*dir, d), os.ModePerm); err != nil {
+			log.Fatalf("unable to create directory due: %v", err)
+		}
+	}
+	files := []struct {
+		name   string
+		target string
+	}{
+		{"scaffold/channels.csv", "channels.csv"},
+		{"scaffold/epgtool.json", "epgtool.json"},
+		{"scaffold/CMS-sample.xml", filepath.Join("data", "CMS-sample.xml")},
+	}
+	for _, f := range files {
+		fileName := filepath.Join(*dir, f.target)
+		if _, err := os.Stat(fileName); err == nil && !*force {
+			fmt.Printf("%s exists, skipped\n", fileName)
+			continue
+		}
+		data, err := scaffold.ReadFile(f.name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fileName, data, 0644); err != nil {
+			log.Fatalf("unable to write '%s' due: %v", fileName, err)
+		}
+		fmt.Printf("%s written\n", fileName)
+	}
+	fmt.Printf("put the source files into %s and run 'epgtool run -all' from %s\n", filepath.Join(*dir, "data"), *dir)
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// inspectCommand shows the source records of a channel covering a time, the
+// output event converted from them and why the other records lost:
+//
+//	epgtool -channelsFile channels.csv inspect -channel Alfa -at 2024-01-15T20:00
+func inspectCommand(args []string) {
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	channelArg := fs.String("channel", "", "ID or name of the channel from the channels file, or a source channel name")
+	atArg := fs.String("at", "", "time covered by the inspected events, in the -tz timezone unless it has an offset")
+	fs.Parse(args)
+	if *channelArg == "" || *atArg == "" {
+		log.Fatalf("missing -channel or -at")
+	}
+	at, err := parseAsRunTime(*atArg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	*provenance = true
+
+	j := defaultJob()
+	_, sources, err := j.channelEvents(context.Background(), newSourceCache())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer sources.close()
+	channel, events := inspectedChannel(j.ChannelsFile, *channelArg, sources)
+	if channel.ID == "" {
+		log.Fatalf("channel '%s' is neither in the channels file nor in the source files", *channelArg)
+	}
+
+	output, err := convertChannel(channel, events, make(map[string]programme), transformRules, newRunReport(ioutil.Discard))
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("channel %s %q at %s\n", channel.ID, channel.Name, at.Format(time.RFC3339))
+	var winners []outputEvent
+	for _, e := range output.Events.Values {
+		if start, end, err := eventTimes(e); err == nil && !start.After(at) && end.After(at) {
+			winners = append(winners, e)
+		}
+	}
+	if len(winners) == 0 {
+		fmt.Println("output: no event")
+	}
+	for _, e := range winners {
+		fmt.Printf("output: %s - %s %q from %s\n", e.StartTime, e.EndTime, e.Name, filepath.Base(e.SourceFile))
+	}
+
+	records := coveringRecords(events, at)
+	if len(records) == 0 {
+		fmt.Println("no source record covers the time")
+		return
+	}
+	fmt.Println("source records:")
+	for _, r := range records {
+		fmt.Printf("  %s\n", filepath.Base(r.SourceFile))
+		fmt.Printf("    %s - %s %q\n", r.Start, r.Stop, titleOf(r, channel.Options.Lang))
+		if d := strings.TrimSpace(r.Description.Name); d != "" {
+			fmt.Printf("    %s\n", truncateText(d, 100))
+		}
+		fmt.Printf("    -> %s\n", recordVerdict(channel, r, events, winners))
+	}
+}
+
+// inspectedChannel finds the channel by ID or name in the channels file,
+// falling back to a source channel of the name with the global options.
+func inspectedChannel(channelsFile, arg string, sources *sourceChannels) (requestedChannel, []programme) {
+	channels, err := readRequestedChannels(channelsFile)
+	if err != nil && !os.IsNotExist(err) {
+		log.Fatal(err)
+	}
+	merged, sourceNames := mergeChannels(channels, sources)
+	for _, c := range merged {
+		if !c.ignored() && (c.ID == arg || strings.EqualFold(c.Name, arg)) {
+			events, err := mergedEvents(sources, sourceNames[c.ID])
+			if err != nil {
+				log.Fatal(err)
+			}
+			return c, events
+		}
+	}
+	for _, name := range sources.names() {
+		if strings.EqualFold(name, arg) {
+			events, err := sources.events(name)
+			if err != nil {
+				log.Fatal(err)
+			}
+			return requestedChannel{ID: name, Name: name, Options: globalChannelOptions()}, events
+		}
+	}
+	return requestedChannel{}, nil
+}
+
+// coveringRecords returns the records running at the time, in the order the
+// conversion sees them.
+func coveringRecords(events []programme, at time.Time) []programme {
+	var result []programme
+	for _, e := range events {
+		start, err1 := parseSourceTime(e.Start)
+		end, err2 := parseSourceTime(e.Stop)
+		if err1 == nil && err2 == nil && !start.After(at) && end.After(at) {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+func titleOf(e programme, lang string) string {
+	if len(e.Title) == 0 {
+		return ""
+	}
+	t := e.Title[0]
+	for _, title := range e.Title {
+		if title.Lang == lang {
+			t = title
+		}
+	}
+	return t.Name
+}
+
+func truncateText(s string, n int) string {
+	r := []rune(strings.Join(strings.Fields(s), " "))
+	if len(r) <= n {
+		return string(r)
+	}
+	return string(r[:n]) + "…"
+}
+
+// recordVerdict explains what the conversion did with the record, following
+// the checks of convertChannel in their order.
+func recordVerdict(channel requestedChannel, r programme, events []programme, winners []outputEvent) string {
+	opts := channel.Options
+	if !opts.acceptsCategories(r.Category) {
+		return "dropped: excluded by the category filter"
+	}
+	start, _ := parseSourceTime(r.Start)
+	end, _ := parseSourceTime(r.Stop)
+	if opts.Window > 0 && (!end.After(time.Now()) || start.After(time.Now().Add(opts.Window))) {
+		return "dropped: outside the channel window"
+	}
+	for _, w := range winners {
+		if wStart, wEnd, _ := eventTimes(w); w.SourceFile == r.SourceFile && wStart.Equal(start) && wEnd.Equal(end) {
+			return "won"
+		}
+	}
+	for _, w := range winners {
+		wStart, wEnd, _ := eventTimes(w)
+		if w.SourceFile == r.SourceFile && !wStart.Before(start) && !wEnd.After(end) && !convertedUnchanged(w, events) {
+			return fmt.Sprintf("won, adjusted to %s - %s", w.StartTime, w.EndTime)
+		}
+	}
+
+	order := "first"
+	seen := events
+	if opts.OverlapStrategy == "last" {
+		order = "last"
+		seen = make([]programme, len(events))
+		for i, e := range events {
+			seen[len(events)-1-i] = e
+		}
+	}
+	for _, e := range seen {
+		if e.SourceFile == r.SourceFile && e.Start == r.Start && e.Stop == r.Stop {
+			break
+		}
+		if s, err := parseSourceTime(e.Start); err == nil && s.Equal(start) && e.ChannelName == r.ChannelName {
+			return fmt.Sprintf("dropped: a record of %s with the same start came first", filepath.Base(e.SourceFile))
+		}
+	}
+	if len(winners) == 0 {
+		return "dropped: removed by a rule, the plugin or the overlap with an event outside the time"
+	}
+	return fmt.Sprintf("dropped: overlaps %q, with overlapStrategy %s the record seen %s wins", winners[0].Name,
+		opts.OverlapStrategy, order)
+}
+
+// convertedUnchanged reports whether a record of the same source file has the
+// times of the output event.
+func convertedUnchanged(w outputEvent, events []programme) bool {
+	wStart, wEnd, _ := eventTimes(w)
+	for _, e := range events {
+		start, err1 := parseSourceTime(e.Start)
+		end, err2 := parseSourceTime(e.Stop)
+		if err1 == nil && err2 == nil && e.SourceFile == w.SourceFile && start.Equal(wStart) && end.Equal(wEnd) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,456 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// job is a single conversion of a set of source files to the output of one
+// or more tenants.
+type job struct {
+	Name            string   `json:"name"`
+	DataDir         string   `json:"dataDir"`
+	SourcePrefix    string   `json:"sourcePrefix"`
+	SourceFileLimit int      `json:"sourceFileLimit"`
+	ChannelsFile    string   `json:"channelsFile"`
+	OutputDir       string   `json:"outputDir"`
+	Tenants         []tenant `json:"tenants"`
+	SourceURLs      []string `json:"sourceUrls"`
+	// Destinations receive the written output files after a successful run.
+	Destinations []destination `json:"destinations"`
+
+	// options are the settings of the run, nil taking them from the command
+	// line flags when the job runs.
+	options *runOptions
+}
+
+// runOptions are the settings of a run read while converting, staging and
+// writing the tenants. They are passed to every job explicitly, so jobs
+// running in parallel each print to their own output and keep their rules.
+type runOptions struct {
+	// out receives the progress of the job, the preview of its changes and
+	// its failed expectations.
+	out   io.Writer
+	rules []*rule
+
+	preview       bool
+	baseline      bool
+	eventHashes   bool
+	statsOutput   bool
+	headerComment bool
+	deltaOutput   bool
+	timeShiftMode string
+	partialMode   string
+	guardrailMode string
+	outputFormat  string
+	writeWorkers  int
+	maxOutputSize int64
+}
+
+// flagRunOptions returns the run options given by the command line flags,
+// printing to the standard output. Only convert -preview previews a run.
+func flagRunOptions() *runOptions {
+	return &runOptions{
+		out:           os.Stdout,
+		rules:         transformRules,
+		baseline:      *baseline,
+		eventHashes:   *eventHashes,
+		statsOutput:   *statsOutput,
+		headerComment: *headerComment,
+		deltaOutput:   *deltaOutput,
+		timeShiftMode: *timeShiftMode,
+		partialMode:   *partialMode,
+		guardrailMode: *guardrailMode,
+		outputFormat:  *outputFormat,
+		writeWorkers:  *writeWorkers,
+		maxOutputSize: *maxOutputSize,
+	}
+}
+
+// runOptions returns the options of the job.
+func (j job) runOptions() *runOptions {
+	if j.options != nil {
+		return j.options
+	}
+	return flagRunOptions()
+}
+
+// defaultJob returns the job described by the command line flags.
+func defaultJob() job {
+	return job{
+		DataDir:         *dataDir,
+		SourcePrefix:    *sourceFilePrefix,
+		SourceFileLimit: *sourceFileLimit,
+		ChannelsFile:    *channelsFile,
+		OutputDir:       *outputDir,
+		Tenants:         tenants,
+		SourceURLs:      sourceURLs,
+		Destinations:    deliverDestinations(),
+	}
+}
+
+// run converts the job and sends the notifications about its outcome.
+func (j job) run(cache *sourceCache, report *runReport) (*runSummary, error) {
+	summary, err := j.convertWithin(*runTimeLimit, cache, report)
+	report.flush()
+	if !j.runOptions().preview {
+		notifyRun(newRunNotice(j, summary, report, err))
+	}
+	return summary, err
+}
+
+// convert reads the sources of the job, converts and stages every tenant and
+// then writes them. Cancelling ctx stops the run before the output is written.
+func (j job) convert(ctx context.Context, cache *sourceCache, report *runReport, deadline *runDeadline) (*runSummary, error) {
+	if err := j.validate(); err != nil {
+		return nil, err
+	}
+	opts := j.runOptions()
+	deadline.enter("reading the sources")
+	files, sources, err := j.channelEvents(ctx, cache)
+	if err != nil {
+		return nil, err
+	}
+	defer sources.close()
+	fmt.Fprintln(opts.out, "Source file count: ", len(files))
+	fmt.Fprintln(opts.out, "Events: ", sources.len())
+
+	summary := &runSummary{SourceFiles: len(files), Channels: sources.len()}
+	var staged []*stagedTenant
+	for _, t := range j.tenantList() {
+		deadline.enter("converting the channels")
+		ts, err := convertTenant(ctx, t, j.OutputDir, sources, report, opts)
+		if err != nil {
+			return nil, err
+		}
+		s, err := stageTenant(t, j.OutputDir, files, ts, report, opts)
+		if err != nil {
+			return nil, err
+		}
+		staged = append(staged, s)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if summary.Tenants, err = writeTenants(staged, deadline, opts); err != nil {
+		return nil, err
+	}
+	return j.complete(summary, files)
+}
+
+func logCreatedFiles(ts *tenantSummary) {
+	if ts.Name == "" {
+		log.Printf("Created files: %d\n", ts.WrittenFiles)
+	} else {
+		log.Printf("Created files for tenant %s: %d\n", ts.Name, ts.WrittenFiles)
+	}
+}
+
+// complete checks the expectations of the published tenants and delivers
+// their output to the destinations of the job.
+func (j job) complete(summary *runSummary, files []string) (*runSummary, error) {
+	opts := j.runOptions()
+	if violations := expectationViolations(summary.Tenants); len(violations) > 0 {
+		for _, v := range violations {
+			fmt.Fprintln(opts.out, "expectation failed:", v)
+		}
+		return summary, fmt.Errorf("%d expectations failed", len(violations))
+	}
+
+	if len(j.Destinations) > 0 && !opts.preview {
+		var comment string
+		if opts.headerComment {
+			comment = generatedComment(files, time.Now())
+		}
+		summary.Deliveries = deliverOutput(j.Destinations, j.OutputDir, summary.Tenants, comment)
+		for _, d := range summary.Deliveries {
+			if len(d.Failed) > 0 {
+				return summary, fmt.Errorf("delivery to %s failed: %s", d.Destination, strings.Join(d.Failed, "; "))
+			}
+		}
+	}
+	return summary, nil
+}
+
+// validate checks the tenant names and destination formats of the job.
+func (j job) validate() error {
+	for _, t := range j.Tenants {
+		if err := validatePathName("tenant", t.Name); err != nil {
+			return err
+		}
+	}
+	for _, d := range j.Destinations {
+		switch d.Format {
+		case "", "native", "tva", "eit":
+		default:
+			return fmt.Errorf("unsupported format '%s' of destination %s", d.Format, redactURL(d.URL))
+		}
+	}
+	return nil
+}
+
+// tenantList returns the tenants of the job, the unnamed default tenant when
+// there are none, with the channels file of the job when they have none.
+func (j job) tenantList() []tenant {
+	result := []tenant{{}}
+	if len(j.Tenants) > 0 {
+		result = append([]tenant(nil), j.Tenants...)
+	}
+	for i := range result {
+		if result[i].ChannelsFile == "" {
+			result[i].ChannelsFile = j.ChannelsFile
+		}
+	}
+	return result
+}
+
+// channelEvents reads the job's source files and groups their programmes by
+// source channel name as the files are parsed. The caller closes the result.
+func (j job) channelEvents(ctx context.Context, cache *sourceCache) ([]string, *sourceChannels, error) {
+	for _, u := range j.SourceURLs {
+		if err := fetchSource(ctx, u, j.DataDir, j.SourcePrefix); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	files, err := listSourceFiles(j.DataDir, j.SourcePrefix, j.SourceFileLimit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parseCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	sources := newSourceChannels(*spillEvents)
+	batches := streamSources(parseCtx, files, cache, filepath.Join(j.DataDir, quarantineDirName), j.sourceWindow(time.Now()))
+	for b := range batches {
+		if b.err != nil {
+			sources.close()
+			return nil, nil, b.err
+		}
+		for _, e := range b.programmes {
+			if loadedPlugin != nil && loadedPlugin.programme != nil {
+				var ok bool
+				var err error
+				if e, ok, err = loadedPlugin.transformProgramme(e); err != nil {
+					sources.close()
+					return nil, nil, err
+				}
+				if !ok {
+					continue
+				}
+			}
+			if err := sources.add(e); err != nil {
+				sources.close()
+				return nil, nil, err
+			}
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		sources.close()
+		return nil, nil, err
+	}
+	return files, sources, nil
+}
+
+// batchConfig is the file describing the named jobs executed by the run
+// command, e.g
+//
+//	{"jobs": [{"name": "opA", "channelsFile": "a.csv", "outputDir": "out/a"}]}
+//
+// Fields which are not set in a job are taken from the command line flags.
+type batchConfig struct {
+	Jobs []job `json:"jobs"`
+	// Rules are transform rules applied to the output events of all jobs.
+	Rules []string `json:"rules"`
+}
+
+// readBatchConfig reads the JSON config file, in which lines starting with
+// // are comments.
+func readBatchConfig(fileName string) (*batchConfig, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open config file due: %v", err)
+	}
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "//") {
+			lines[i] = ""
+		}
+	}
+
+	var cfg batchConfig
+	if err := json.Unmarshal([]byte(strings.Join(lines, "\n")), &cfg); err != nil {
+		return nil, fmt.Errorf("unable to parse config file due: %v", err)
+	}
+
+	defaults := defaultJob()
+	for i := range cfg.Jobs {
+		j := &cfg.Jobs[i]
+		if j.Name == "" {
+			return nil, fmt.Errorf("job %d has no name", i+1)
+		}
+		if j.DataDir == "" {
+			j.DataDir = defaults.DataDir
+		}
+		if j.SourcePrefix == "" {
+			j.SourcePrefix = defaults.SourcePrefix
+		}
+		if j.SourceFileLimit == 0 {
+			j.SourceFileLimit = defaults.SourceFileLimit
+		}
+		if j.ChannelsFile == "" {
+			j.ChannelsFile = defaults.ChannelsFile
+		}
+		if j.OutputDir == "" {
+			j.OutputDir = defaults.OutputDir
+		}
+		if len(j.Destinations) == 0 {
+			j.Destinations = defaults.Destinations
+		}
+	}
+	return &cfg, nil
+}
+
+// runCommand executes the jobs of the batch config selected either by name
+// or with -all:
+//
+//	epgtool run -config epgtool.json -all -parallel
+//	epgtool run -config epgtool.json opA opB
+func runCommand(args []string, cache *sourceCache) {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	configFile := fs.String("config", "epgtool.json", "batch config file with the job definitions")
+	all := fs.Bool("all", false, "run all jobs from the config file")
+	parallel := fs.Bool("parallel", false, "run the jobs in parallel")
+	fs.Parse(args)
+
+	cfg, err := readBatchConfig(*configFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	rules := transformRules
+	if len(cfg.Rules) > 0 {
+		if rules, err = parseRules(cfg.Rules, *configFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var selected []job
+	if *all {
+		selected = cfg.Jobs
+	} else {
+		for _, name := range fs.Args() {
+			found := false
+			for _, j := range cfg.Jobs {
+				if j.Name == name {
+					selected = append(selected, j)
+					found = true
+				}
+			}
+			if !found {
+				log.Fatalf("job '%s' is not defined in '%s'", name, *configFile)
+			}
+		}
+	}
+	if len(selected) == 0 {
+		log.Fatalf("no jobs selected, pass job names or -all")
+	}
+
+	// Every job has its own report and options, so its notifications and
+	// sampling only cover its own entries. Parallel jobs print into a buffer
+	// written once they all finished, keeping their output apart.
+	reports := make([]*runReport, len(selected))
+	outputs := make([]bytes.Buffer, len(selected))
+	for i := range selected {
+		opts := flagRunOptions()
+		opts.rules = rules
+		if *parallel {
+			opts.out = &outputs[i]
+		}
+		selected[i].options = opts
+		reports[i] = newRunReport(opts.out)
+	}
+	errs := make([]error, len(selected))
+	var wg sync.WaitGroup
+	for i, j := range selected {
+		log.Printf("Running job %s\n", j.Name)
+		if !*parallel {
+			_, errs[i] = j.run(cache, reports[i])
+			continue
+		}
+		wg.Add(1)
+		go func(i int, j job) {
+			defer wg.Done()
+			_, errs[i] = j.run(cache, reports[i])
+		}(i, j)
+	}
+	wg.Wait()
+
+	total := newRunReport(nil)
+	for i, r := range reports {
+		if *parallel && outputs[i].Len() > 0 {
+			fmt.Printf("Report of job %s:\n", selected[i].Name)
+			outputs[i].WriteTo(os.Stdout)
+		}
+		total.merge(r)
+	}
+	if len(total.Entries) > 0 {
+		log.Printf("Report of %d jobs: %s\n", len(selected), total.summary())
+	}
+
+	failed := 0
+	for i, err := range errs {
+		if err != nil {
+			log.Printf("job %s failed due: %v", selected[i].Name, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		log.Fatalf("%d of %d jobs failed", failed, len(selected))
+	}
+}
+
+// sourceCache keeps the parsed source files so jobs reading the same files
+// parse them only once.
+type sourceCache struct {
+	mu      sync.Mutex
+	entries map[string]*cachedSource
+}
+
+type cachedSource struct {
+	once   sync.Once
+	source source
+	err    error
+}
+
+func newSourceCache() *sourceCache {
+	return &sourceCache{entries: make(map[string]*cachedSource)}
+}
+
+// get returns the parsed source file, which a nil cache reads without keeping
+// it.
+func (c *sourceCache) get(fname string, w sourceWindow) (source, error) {
+	if c == nil {
+		return readSource(fname, w)
+	}
+	c.mu.Lock()
+	e, ok := c.entries[fname+w.key()]
+	if !ok {
+		e = &cachedSource{}
+		c.entries[fname+w.key()] = e
+	}
+	c.mu.Unlock()
+
+	e.once.Do(func() { e.source, e.err = readSource(fname, w) })
+	return e.source, e.err
+}
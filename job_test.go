@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mgenov/epgtool/store"
+)
+
+// memoryJob returns a job converting the source.xml of the repository from
+// a store.Memory into its out directory.
+func memoryJob(t *testing.T) (job, *store.Memory) {
+	store := useMemoryStore(t)
+	source, err := ioutil.ReadFile("source.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeMemoryFile(t, store, filepath.Join("data", "source.xml"), string(source))
+	channelsFile := "channels.csv"
+	writeMemoryFile(t, store, channelsFile, "151,\"Nickelodeon\"\n")
+	return job{DataDir: "data", SourceFileLimit: 1, ChannelsFile: channelsFile, OutputDir: "out"}, store
+}
+
+func TestConvertStagesAllTenants(t *testing.T) {
+	j, store := memoryJob(t)
+	j.Tenants = []tenant{{Name: "a"}, {Name: "b", ChannelsFile: "missing.csv"}}
+	if _, err := j.convert(context.Background(), nil, newRunReport(ioutil.Discard), nil); err == nil {
+		t.Fatal("the run of a tenant without channels file succeeded")
+	}
+	if files, _ := store.List("out"); len(files) != 0 {
+		t.Errorf("a failed run published %v", files)
+	}
+
+	j.Tenants[1].ChannelsFile = ""
+	summary, err := j.convert(context.Background(), nil, newRunReport(ioutil.Discard), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.Tenants) != 2 || summary.Tenants[0].WrittenFiles != 1 || summary.Tenants[1].WrittenFiles != 1 {
+		t.Errorf("got tenants %+v", summary.Tenants)
+	}
+}
+
+func TestConvertCancelled(t *testing.T) {
+	j, store := memoryJob(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := j.convert(ctx, nil, newRunReport(ioutil.Discard), nil); err != context.Canceled {
+		t.Fatalf("got error %v, want %v", err, context.Canceled)
+	}
+	if files, _ := store.List("out"); len(files) != 0 {
+		t.Errorf("a cancelled run published %v", files)
+	}
+}
+
+func TestConvertWithinDeadline(t *testing.T) {
+	j, store := memoryJob(t)
+	_, err := j.convertWithin(time.Nanosecond, nil, newRunReport(ioutil.Discard))
+	if err == nil || !strings.Contains(err.Error(), "exceeded the deadline") {
+		t.Fatalf("got error %v", err)
+	}
+	// convertWithin returned after the cancelled conversion stopped, so
+	// nothing is written later.
+	if files, _ := store.List("out"); len(files) != 0 {
+		t.Errorf("an aborted run published %v", files)
+	}
+}
+
+func TestConvertOptions(t *testing.T) {
+	j, store := memoryJob(t)
+	rules, err := parseRules([]string{"drop()"}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	opts := flagRunOptions()
+	opts.out, opts.rules, opts.preview = &out, rules, true
+	j.options = opts
+	summary, err := j.convert(context.Background(), nil, newRunReport(ioutil.Discard), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Source file count:", "Channels:"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("the output %q of the job misses %q", out.String(), want)
+		}
+	}
+	if ts := summary.Tenants[0]; len(ts.converted) != 1 || len(ts.converted[0].output.Events.Values) != 0 {
+		t.Errorf("the rules of the job did not drop the events of %+v", ts.converted)
+	}
+	if files, _ := store.List("out"); len(files) != 0 {
+		t.Errorf("a preview published %v", files)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// lineupChange is a channel ID which appeared in or disappeared from the
+// output since the previous run.
+type lineupChange struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Change string `json:"change"`
+}
+
+// lineupChanges compares the channel IDs of the manifest with the ones of the
+// previous manifest, telling why a disappeared ID is missing. Without a
+// previous manifest there are no changes.
+func lineupChanges(prev, m *manifest, summary *tenantSummary) []lineupChange {
+	if prev == nil {
+		return nil
+	}
+	empty := make(map[string]bool)
+	for _, c := range summary.EmptyChannels {
+		empty[c.ID] = true
+	}
+	var result []lineupChange
+	for id, c := range m.Channels {
+		if _, ok := prev.Channels[id]; !ok {
+			result = append(result, lineupChange{ID: id, Name: c.Name, Change: "added"})
+		}
+	}
+	for id, c := range prev.Channels {
+		if _, ok := m.Channels[id]; ok {
+			continue
+		}
+		change := lineupChange{ID: id, Name: c.Name, Change: "removed, not published"}
+		switch {
+		case empty[id]:
+			change.Change = "removed, no events in the source files"
+		case summary.mapped != nil && !summary.mapped[id]:
+			change.Change = "removed, not in the channels file"
+		case summary.inactive[id].ID != "":
+			change.Change = "removed, inactive"
+		}
+		result = append(result, change)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
+
+// lineup records a channel ID which appeared or disappeared since the
+// previous run.
+func (r *runReport) lineup(c lineupChange) {
+	r.add(reportEntry{
+		Kind:      "lineup",
+		ChannelID: c.ID,
+		Channel:   c.Name,
+		Detail:    c.Change,
+	}, fmt.Sprintf("lineup changed %s channel=\"%s\": %s\n", c.ID, c.Name, c.Change))
+}
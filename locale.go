@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// localeNames holds the weekday and month names of a locale, the months in
+// the form used after a day number.
+type localeNames struct {
+	weekdays    [7]string
+	weekdaysAbr [7]string
+	months      [12]string
+	monthsAbr   [12]string
+}
+
+var dateLocales = map[string]localeNames{
+	"bg": {
+		weekdays:    [7]string{"Неделя", "Понеделник", "Вторник", "Сряда", "Четвъртък", "Петък", "Събота"},
+		weekdaysAbr: [7]string{"Нд", "Пн", "Вт", "Ср", "Чт", "Пт", "Сб"},
+		months: [12]string{"януари", "февруари", "март", "април", "май", "юни", "юли", "август", "септември", "октомври",
+			"ноември", "декември"},
+		monthsAbr: [12]string{"яну", "фев", "мар", "апр", "май", "юни", "юли", "авг", "сеп", "окт", "ное", "дек"},
+	},
+	"en": {
+		weekdays:    [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+		weekdaysAbr: [7]string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"},
+		months: [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September",
+			"October", "November", "December"},
+		monthsAbr: [12]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+	},
+	"de": {
+		weekdays:    [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+		weekdaysAbr: [7]string{"So", "Mo", "Di", "Mi", "Do", "Fr", "Sa"},
+		months: [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober",
+			"November", "Dezember"},
+		monthsAbr: [12]string{"Jan", "Feb", "Mär", "Apr", "Mai", "Jun", "Jul", "Aug", "Sep", "Okt", "Nov", "Dez"},
+	},
+	"ro": {
+		weekdays:    [7]string{"Duminică", "Luni", "Marți", "Miercuri", "Joi", "Vineri", "Sâmbătă"},
+		weekdaysAbr: [7]string{"Dum", "Lun", "Mar", "Mie", "Joi", "Vin", "Sâm"},
+		months: [12]string{"ianuarie", "februarie", "martie", "aprilie", "mai", "iunie", "iulie", "august", "septembrie",
+			"octombrie", "noiembrie", "decembrie"},
+		monthsAbr: [12]string{"ian", "feb", "mar", "apr", "mai", "iun", "iul", "aug", "sep", "oct", "noi", "dec"},
+	},
+	"ru": {
+		weekdays:    [7]string{"Воскресенье", "Понедельник", "Вторник", "Среда", "Четверг", "Пятница", "Суббота"},
+		weekdaysAbr: [7]string{"Вс", "Пн", "Вт", "Ср", "Чт", "Пт", "Сб"},
+		months: [12]string{"января", "февраля", "марта", "апреля", "мая", "июня", "июля", "августа", "сентября", "октября",
+			"ноября", "декабря"},
+		monthsAbr: [12]string{"янв", "фев", "мар", "апр", "мая", "июн", "июл", "авг", "сен", "окт", "ноя", "дек"},
+	},
+}
+
+// validateDateLocale checks the -dateLocale value, empty disables the field.
+func validateDateLocale(locale string) error {
+	if _, ok := dateLocales[locale]; ok || locale == "" {
+		return nil
+	}
+	var names []string
+	for name := range dateLocales {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return fmt.Errorf("unsupported dateLocale value '%s', expected one of %s", locale, strings.Join(names, ", "))
+}
+
+// formatLocalDate formats the local time with the Go layout, replacing the
+// English weekday and month names with the ones of the locale.
+func formatLocalDate(t time.Time, layout, locale string) string {
+	l := dateLocales[locale]
+	t = t.In(time.Local)
+	en := dateLocales["en"]
+	replacer := strings.NewReplacer(
+		en.weekdays[t.Weekday()], l.weekdays[t.Weekday()],
+		en.weekdaysAbr[t.Weekday()], l.weekdaysAbr[t.Weekday()],
+		en.months[t.Month()-1], l.months[t.Month()-1],
+		en.monthsAbr[t.Month()-1], l.monthsAbr[t.Month()-1],
+	)
+	return replacer.Replace(t.Format(layout))
+}
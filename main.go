@@ -1,41 +1,153 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/csv"
+	"encoding/json"
 	"encoding/xml"
 	"flag"
 	"fmt"
+	"hash/fnv"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
-
-	timespan "github.com/senseyeio/spaniel"
+	"unicode"
 )
 
 const (
-	inDateLayout  = "20060102150405 -0700"
-	outDateLayout = "2006-01-02T15:04:05Z"
+	inDateLayout      = "20060102150405 -0700"
+	inLocalDateLayout = "20060102150405"
+	outDateLayout     = "2006-01-02T15:04:05Z"
 )
 
+// version is the version of the tool, set at build time with
+// -ldflags "-X main.version=1.2.0".
+var version = "dev"
+
 var (
 	dataDir          = flag.String("dataDir", "data", "data directory")
 	sourceFileLimit  = flag.Int("sourceFileLimit", 5, "the maximum number of files to be read")
 	sourceFilePrefix = flag.String("sourcePrefix", "CMS", "prefixed used to filter specific source files, e.g CMS-20210114")
 	channelsFile     = flag.String("channelsFile", "channels.csv", "the mapping file for the channels")
 	outputDir        = flag.String("outputDir", ".", "output directory where result will be written")
+	strictParse      = flag.String("strictParse", "off", "report elements and attributes of source files which are not part of the XMLTV DTD: off, warn or fail")
+	validateDTD      = flag.String("validateDTD", "off", "check XML source files against the XMLTV DTD reporting violations with their line and column: off, warn or fail")
+	quarantineMode   = flag.String("quarantine", "off", "what to do with source files failing to parse: off (fail the run), move or copy them into the quarantine directory of the data directory")
+	timeFields       = flag.String("timeFields", "end", "which event end fields to emit: end (time_till), duration (duration_seconds) or both")
+	snapTimes        = flag.Duration("snapTimes", 0, "round event start and stop times to the nearest multiple of the given duration, e.g 1m or 5m")
+	snapReport       = flag.Duration("snapReportThreshold", 30*time.Second, "report events whose times were moved by more than this duration when snapping")
+	repairThreshold  = flag.Duration("repairThreshold", 0, "close gaps and clip overlaps between adjacent events up to this duration, e.g 2m")
+	preferredLang    = flag.String("lang", "bg", "preferred language of event titles")
+	overlapStrategy  = flag.String("overlapStrategy", "first", "which of two overlapping events is kept: first or last seen")
+	includeCats      = flag.String("includeCategories", "", "comma separated categories, when set only events in one of them are kept")
+	excludeCats      = flag.String("excludeCategories", "", "comma separated categories of events to drop, e.g Teleshopping")
+	window           = flag.Duration("window", 0, "keep only events overlapping the given duration from now, e.g 168h")
+	parseCacheDir    = flag.String("parseCache", "", "directory for caching parsed source files between runs, disabled when empty")
+	maxChannelEvents = flag.Int("maxEventsPerChannel", 0, "guardrail: maximum number of events of a channel, disabled when 0")
+	expectChannels   = flag.Int("expectMinChannels", 0, "fail the run when fewer channels are converted, disabled when 0")
+	expectEvents     = flag.Int("expectMinEventsPerChannel", 0, "fail the run when a requested channel has fewer events, disabled when 0")
+	minChannelEvents = flag.Int("minEventsPerChannel", 0, "guardrail: minimum number of events of a channel, disabled when 0")
+	maxOutputSize    = flag.Int64("maxOutputSize", 0, "guardrail: maximum total size in bytes of the written files, disabled when 0")
+	guardrailMode    = flag.String("guardrails", "warn", "what to do with channels violating guardrails: warn, skip (do not write them) or fail")
+	minCoverageSpec  = flag.String("minCoverage", "", "keep the current output file of a channel covering this upcoming time when the new events cover less, e.g 48h or 2d; disabled when empty")
+	minCoverage      time.Duration
+	timeShiftMode    = flag.String("timeShift", "off", "detect channels whose schedule moved by a constant offset since the previous output: off, warn or correct (moving back the providers confirmed shifted by another provider)")
+	partialMode      = flag.String("partial", "deny", "what to do when some channels fail to convert: allow publishing the others, marking the failed ones in the manifest, or deny publishing")
+	maxAnomaly       = anomalyCritical
+	progressMode     = flag.String("progress", "auto", "show progress bars with an ETA on stderr: auto when it is a terminal, on or off")
+	runTimeLimit     = flag.Duration("deadline", 0, "abort a run which did not start writing its output within the duration, keeping the previous output, 0 disables it")
+	fetchTimeout     = flag.Duration("fetchTimeout", 30*time.Second, "timeout of a single source download attempt")
+	fetchRetries     = flag.Int("fetchRetries", 3, "number of retries of a failed source download")
+	fetchBackoff     = flag.Duration("fetchBackoff", 2*time.Second, "delay before the first retry of a source download, doubled on every retry")
+	deltaOutput      = flag.Bool("delta", false, "write only per-channel delta files with the events added, updated and deleted since the previous run's manifest")
+	signOutput       = flag.Bool("sign", false, "write a detached Ed25519 signature <file>.sig of every channel output file, the key is read from EPGTOOL_SIGNING_KEY")
+	encryptOutput    = flag.Bool("encrypt", false, "encrypt every channel output file with AES-256-GCM, the key is read from EPGTOOL_ENCRYPTION_KEY")
+	parquetDir       = flag.String("parquetDir", "", "also write the events as Parquet files partitioned by date and channel into the directory")
+	redisAddr        = flag.String("redisAddr", "", "also keep the now, next and later events of every channel in Redis at the address, the password is read from EPGTOOL_REDIS_PASSWORD")
+	redisPrefix      = flag.String("redisPrefix", "epg:", "prefix of the Redis keys")
+	primeTimeSpec    = flag.String("primeTime", "", "also write prime_time.json with the events of every channel starting in the daily local window, e.g 19:00-23:00")
+	primeTimeHours   primeTimeWindow
+	remindersFile    = flag.String("reminders", "", "JSON file of subscriptions, also write reminders.json with the upcoming events they match")
+	nowNextOutput    = flag.Bool("nowNext", false, "also write now_next.json with the current and next event of every channel")
+	packageTrees     = flag.Bool("packageTrees", false, "write channels into a subdirectory per package instead of tagging them only")
+	sourceURLs       stringList
+	deliverURLs      stringList
+	tenants          tenantList
+	outputFormat     = flag.String("outputFormat", "native", "format of the channel output files: native, tva (TV-Anytime) or eit (DVB EIT tables as JSON)")
+	tvaAuthority     = flag.String("tvaAuthority", "epgtool.local", "authority of the CRIDs generated for tva output events without one")
+	smtpAddr         = flag.String("smtpAddr", "", "SMTP server host:port for email notifications, disabled when empty")
+	smtpFrom         = flag.String("smtpFrom", "epgtool@localhost", "sender address of email notifications")
+	smtpTo           = flag.String("smtpTo", "", "comma separated recipients of email notifications")
+	smtpUser         = flag.String("smtpUser", "", "SMTP user, the password is read from EPGTOOL_SMTP_PASSWORD")
+	smtpTemplate     = flag.String("smtpTemplate", "", "text/template file for the email body, a built-in template is used when empty")
+	notifyAnomaly    = anomalyWarning
+	slackWebhook     = flag.String("slackWebhook", "", "Slack incoming webhook URL notified about every run, disabled when empty")
+	teamsWebhook     = flag.String("teamsWebhook", "", "Microsoft Teams incoming webhook URL notified about every run, disabled when empty")
+	notifyCollisions = flag.Int("notifyCollisions", 0, "collision count above which webhook notifications are marked as alerts, disabled when 0")
+	notifyGaps       = flag.Int("notifyGaps", 0, "repaired gap count above which webhook notifications are marked as alerts, disabled when 0")
+	logSample        = flag.Int("logSample", 0, "echo only the first N report entries of a kind per channel and summarize the rest at the end of the run, disabled when 0")
+	reportFile       = flag.String("reportFile", "", "file receiving the full detail of every report entry, appended to on every run")
+	reportDetail     io.Writer
+	maxOpenFiles     = flag.Int("maxOpenFiles", 0, "the maximum number of source and output files open at once, 0 for half of the process limit")
+	writeWorkers     = flag.Int("writeWorkers", 4, "number of output files written concurrently")
+	spillEvents      = flag.Int("spillEvents", 500000, "number of source programmes grouped in memory before they are spilled to a temporary file, never spilled when 0")
+	sortTieBreak     = flag.String("sortTieBreak", "title", "order of events starting at the same time: title or id")
+	dateLocale       = flag.String("dateLocale", "", "add a local_date field to the events with the start date in the locale: bg, de, en, ro or ru; disabled when empty")
+	dateFormat       = flag.String("dateFormat", "Monday, 2 January", "Go time layout of the local_date field, weekday and month names are translated to the -dateLocale")
+	timezone         = flag.String("tz", "", "IANA timezone used as local time, e.g Europe/Sofia, for source times without offset and active periods; the system zone when empty")
+	indentMode       = flag.String("indent", "legacy", "indentation of the XML output: legacy, compact, tab or the number of spaces per level")
+	cdataOutput      = flag.Bool("cdata", false, "wrap the text elements of the output events in CDATA sections instead of escaping them")
+	presenceSpec     = flag.String("fieldPresence", "", "how empty optional event elements are written, e.g description=empty,perex=default:TBA; omitted by default")
+	idPatternSpec    = flag.String("channelIDPattern", "", "regular expression the channel IDs of the channels file must match, e.g ^[0-9]+$")
+	idPattern        *regexp.Regexp
+	generateIDs      = flag.Bool("generateChannelIDs", false, "generate a stable numeric ID from the name for channels without an ID in the channels file")
+	parts            = flag.String("parts", "off", "programmes split into parts: off, group marks the parts with the ID of the first one, merge joins them into one event")
+	partGap          = flag.Duration("partGap", time.Hour, "longest break between two parts of a programme")
+	sportsMetadata   = flag.Bool("sports", false, "extract the sport, teams, competition and round from the titles of sports events")
+	titleCase        = flag.String("titleCase", "off", "case of the event names: off, title or sentence")
+	acronyms         = flag.String("acronyms", "", "comma separated words keeping their case with -titleCase, e.g HBO,NBA,BBC")
+	translit         = flag.String("translit", "off", "transliterate the event texts: off, latin or cyrillic")
+	translitFields   = flag.String("translitFields", "name,perex,description", "comma separated event elements transliterated with -translit")
+	normalizeText    = flag.Bool("normalizeText", true, "normalize the event texts to NFC and strip zero-width and control characters")
+	normalizePeople  = flag.Bool("normalizePeople", true, "strip parenthesized roles and drop empty and repeated names of the actors and directors")
+	countryCodes     = flag.Bool("countryCodes", false, "write the production countries as ISO 3166-1 alpha-2 codes, unknown names are kept")
+	countryCodesFile = flag.String("countryCodesFile", "", "CSV file of name,code rows added to or overriding the internal country table")
+	peopleFormat     = flag.String("peopleFormat", "joined", "how the actors and directors are written: joined into the comma separated actors and directors elements or list as repeated actor and director elements")
+	maxPeople        = flag.Int("maxPeople", 0, "maximum number of actors and of directors of an event, disabled when 0")
+	perexMode        = flag.String("perex", "full", "perex of the events: full copies the description, sentence takes its first sentence, a number its leading words up to that many characters")
+	baseline         = flag.Bool("baseline", false, "keep the upcoming events of the previous output of a channel where the sources have no events")
+	overlapTolerance = flag.Duration("overlapTolerance", 0, "overlaps of an event with its neighbours up to this duration are clipped silently instead of being collisions, e.g 60s")
+	pluginFile       = flag.String("plugin", "", "Go plugin (.so) transforming the parsed programmes and the output events")
+	auditFile        = flag.String("auditLog", "", "append a JSON line for every change of an event by a transform rule or the plugin to the file")
+	rulesFile        = flag.String("rules", "", "file with a transform rule per line applied to the output events")
+	eventHashes      = flag.Bool("eventHashes", false, "add a hash attribute with the content hash of every output event")
+	headerComment    = flag.Bool("headerComment", false, "start every XML output file with a comment naming the tool version, generation time and source files")
+	statsOutput      = flag.Bool("stats", false, "add a stats element with the event count, covered period, generation time and tool version to every output channel")
+	provenance       = flag.Bool("provenance", false, "annotate each output event with the source file and provider it came from")
 )
 
 type source struct {
-	ChannelList []channel   `xml:"channel"`
-	ProgramList []programme `xml:"programme"`
+	Generator   string      `xml:"generator-info-name,attr" json:"generator"`
+	ChannelList []channel   `xml:"channel" json:"channels"`
+	ProgramList []programme `xml:"programme" json:"programmes"`
+
+	Unknown      unknownElements `xml:",any" json:"-"`
+	UnknownAttrs unknownAttrs    `xml:",any,attr" json:"-"`
 }
 
 type title struct {
-	Lang string `xml:"lang,attr"`
-	Name string `xml:",chardata"`
+	Lang string `xml:"lang,attr" json:"lang,omitempty"`
+	Name string `xml:",chardata" json:"value"`
 }
 
 func (t *title) String() string {
@@ -43,30 +155,74 @@ func (t *title) String() string {
 }
 
 type channel struct {
-	ID   string `xml:"id,attr"`
-	Name title  `xml:"display-name"`
-	URL  string `xml:"url"`
+	ID   string `xml:"id,attr" json:"id"`
+	Name title  `xml:"display-name" json:"name"`
+	URL  string `xml:"url" json:"url"`
+
+	Unknown      unknownElements `xml:",any" json:"-"`
+	UnknownAttrs unknownAttrs    `xml:",any,attr" json:"-"`
 }
 
 // <programme start="20170701080000 +0300" stop="20170701100000 +0300" channel="Alfa">
 //     <title lang="bg">~Tоб~@о ~C~B~@о, б~Jлга~@и</title>
 //   </programme>
 type programme struct {
-	Start         string   `xml:"start,attr"`
-	Stop          string   `xml:"stop,attr"`
-	ChannelName   string   `xml:"channel,attr"`
-	Description   title    `xml:"desc"`
-	Title         []title  `xml:"title"`
-	Credits       credits  `xml:"credits"`
-	Date          string   `xml:"date"`
-	Category      title    `xml:"category"`
-	Country       []string `xml:"country"`
-	EpisodeNumber string   `xml:"episode-num"`
+	Start         string       `xml:"start,attr" json:"start"`
+	Stop          string       `xml:"stop,attr" json:"stop"`
+	ChannelName   string       `xml:"channel,attr" json:"channel"`
+	Description   title        `xml:"desc" json:"desc"`
+	Title         []title      `xml:"title" json:"title"`
+	Credits       credits      `xml:"credits" json:"credits"`
+	Date          string       `xml:"date" json:"date,omitempty"`
+	Category      []title      `xml:"category" json:"category,omitempty"`
+	Keywords      []title      `xml:"keyword" json:"keyword,omitempty"`
+	Country       []string     `xml:"country" json:"country,omitempty"`
+	EpisodeNumber []episodeNum `xml:"episode-num" json:"episodeNum,omitempty"`
+	URL           []string     `xml:"url" json:"url"`
+	Video         video        `xml:"video" json:"video"`
+	Audio         audio        `xml:"audio" json:"audio"`
+
+	Unknown      unknownElements `xml:",any" json:"-"`
+	UnknownAttrs unknownAttrs    `xml:",any,attr" json:"-"`
+
+	// SourceFile and Provider are not part of the XMLTV programme, they are
+	// filled after decoding to keep track of where the programme came from.
+	SourceFile string `xml:"-" json:"-"`
+	Provider   string `xml:"-" json:"-"`
 }
 
 type credits struct {
-	Producers []string `xml:"producer"`
-	Actors    []string `xml:"actor"`
+	Producers []string `xml:"producer" json:"producers,omitempty"`
+	Actors    []string `xml:"actor" json:"actors,omitempty"`
+
+	Unknown unknownElements `xml:",any" json:"-"`
+}
+
+// <episode-num system="crid">crid://example.com/123</episode-num>
+type episodeNum struct {
+	System string `xml:"system,attr" json:"system"`
+	Value  string `xml:",chardata" json:"value"`
+}
+
+// episodeNumber returns the value of the episode-num with the given system.
+func (p *programme) episodeNumber(system string) string {
+	for _, e := range p.EpisodeNumber {
+		if e.System == system {
+			return strings.TrimSpace(e.Value)
+		}
+	}
+	return ""
+}
+
+// <video><aspect>16:9</aspect><quality>HDTV</quality></video>
+type video struct {
+	Aspect  string `xml:"aspect" json:"aspect,omitempty"`
+	Quality string `xml:"quality" json:"quality,omitempty"`
+}
+
+// <audio><stereo>dolby digital</stereo></audio>
+type audio struct {
+	Stereo string `xml:"stereo" json:"stereo,omitempty"`
 }
 
 type name struct {
@@ -77,43 +233,135 @@ func (c *channel) String() string {
 	return fmt.Sprintf("ID: %s, Name: %s, URL: %s", c.ID, c.Name.String(), c.URL)
 }
 
+// ignoredChannelID marks the source channels of the channels file which are
+// intentionally not converted, e.g `-,"Shop TV"`.
+const ignoredChannelID = "-"
+
 type requestedChannel struct {
-	ID   string
-	Name string
+	ID      string
+	Name    string
+	Options channelOptions
+	// OptionSpec is the raw options column the Options were parsed from.
+	OptionSpec string
+	// Line is the line of the channel in the channels file.
+	Line int
+	// generatedID tells the ID was generated by -generateChannelIDs.
+	generatedID bool
+}
+
+func (c requestedChannel) ignored() bool {
+	return c.ID == ignoredChannelID
 }
 
 type outputChannel struct {
-	Name   string       `xml:"name,attr"`
-	ID     string       `xml:"id,attr"`
-	Events outputEvents `xml:"events"`
+	Name     string        `xml:"name,attr"`
+	ID       string        `xml:"id,attr"`
+	Packages string        `xml:"packages,attr,omitempty"`
+	Stats    *channelStats `xml:"stats,omitempty"`
+	Events   outputEvents  `xml:"events"`
+
+	// eitIDs are the EIT event_ids of the events by event ID, see
+	// assignEITEventIDs.
+	eitIDs map[string]uint16
+}
+
+// channelStats lets consumers sanity-check a channel file on its own.
+type channelStats struct {
+	Events    int    `xml:"events"`
+	From      string `xml:"from,omitempty"`
+	To        string `xml:"to,omitempty"`
+	Generated string `xml:"generated"`
+	Generator string `xml:"generator"`
+}
+
+func newChannelStats(c *outputChannel, now time.Time) *channelStats {
+	stats := &channelStats{
+		Events:    len(c.Events.Values),
+		Generated: now.UTC().Format(outDateLayout),
+		Generator: "epgtool " + version,
+	}
+	var from, to time.Time
+	for _, e := range c.Events.Values {
+		s, ok := eventSpan(e)
+		if !ok {
+			continue
+		}
+		if from.IsZero() || s.start.Before(from) {
+			from = s.start
+		}
+		if s.end.After(to) {
+			to = s.end
+		}
+	}
+	if !from.IsZero() {
+		stats.From, stats.To = from.UTC().Format(outDateLayout), to.UTC().Format(outDateLayout)
+	}
+	return stats
 }
 
 type outputEvents struct {
 	Values []outputEvent `xml:"event"`
 }
 type outputEvent struct {
-	ID                  string `xml:"id"`
-	Name                string `xml:"name"`
-	StartTime           string `xml:"time_from"`
-	EndTime             string `xml:"time_till"`
-	Perex               string `xml:"perex,omitempty"`
-	Description         string `xml:"description,omitempty"`
-	Actors              string `xml:"actors,omitempty"`
-	Directors           string `xml:"directors,omitempty"`
-	ProductionYear      string `xml:"production_year,omitempty"`
-	ProductionCountries string `xml:"production_countries,omitempty"`
+	SourceFile          string      `xml:"source,attr,omitempty" json:"source,omitempty"`
+	Provider            string      `xml:"provider,attr,omitempty" json:"provider,omitempty"`
+	Hash                string      `xml:"hash,attr,omitempty" json:"hash,omitempty"`
+	ID                  string      `xml:"id" json:"id"`
+	Name                string      `xml:"name" json:"name"`
+	StartTime           string      `xml:"time_from" json:"time_from"`
+	EndTime             string      `xml:"time_till,omitempty" json:"time_till,omitempty"`
+	Duration            *int64      `xml:"duration_seconds,omitempty" json:"duration_seconds,omitempty"`
+	LocalDate           string      `xml:"local_date,omitempty" json:"local_date,omitempty"`
+	Perex               string      `xml:"perex,omitempty" json:"perex,omitempty"`
+	Description         string      `xml:"description,omitempty" json:"description,omitempty"`
+	Actors              string      `xml:"actors,omitempty" json:"actors,omitempty"`
+	Directors           string      `xml:"directors,omitempty" json:"directors,omitempty"`
+	ActorList           []string    `xml:"actor,omitempty" json:"actor,omitempty"`
+	DirectorList        []string    `xml:"director,omitempty" json:"director,omitempty"`
+	ProductionYear      string      `xml:"production_year,omitempty" json:"production_year,omitempty"`
+	OriginalAirDate     string      `xml:"original_air_date,omitempty" json:"original_air_date,omitempty"`
+	ProductionCountries string      `xml:"production_countries,omitempty" json:"production_countries,omitempty"`
+	VideoQuality        string      `xml:"video_quality,omitempty" json:"video_quality,omitempty"`
+	VideoAspect         string      `xml:"video_aspect,omitempty" json:"video_aspect,omitempty"`
+	Audio               string      `xml:"audio,omitempty" json:"audio,omitempty"`
+	URL                 string      `xml:"url,omitempty" json:"url,omitempty"`
+	CRID                string      `xml:"crid,omitempty" json:"crid,omitempty"`
+	ProgramID           string      `xml:"dd_progid,omitempty" json:"dd_progid,omitempty"`
+	Tags                *outputTags `xml:"tags,omitempty" json:"tags,omitempty"`
+	GroupID             string      `xml:"group_id,omitempty" json:"group_id,omitempty"`
+	Sports              *sportsInfo `xml:"sports,omitempty" json:"sports,omitempty"`
+}
+
+type outputTags struct {
+	Values []string `xml:"tag"`
+}
+
+func (t *outputTags) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.Values)
+}
+
+func (t *outputTags) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &t.Values)
 }
 
 func listSourceFiles(dataDir string, filePrefix string, lastN int) ([]string, error) {
+	all, err := sourceFiles.List(dataDir)
+	if err != nil {
+		return nil, err
+	}
 	var files []string
-	err := filepath.Walk(dataDir, func(path string, info os.FileInfo, err error) error {
-		if filePrefix == "" || strings.HasPrefix(info.Name(), filePrefix) {
+	for _, path := range all {
+		rel, err := filepath.Rel(dataDir, path)
+		if err != nil {
+			continue
+		}
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		if skippedSourcePath(parts) {
+			continue
+		}
+		if filePrefix == "" || strings.HasPrefix(parts[len(parts)-1], filePrefix) {
 			files = append(files, path)
 		}
-		return nil
-	})
-	if err != nil {
-		return nil, err
 	}
 
 	sort.Strings(files)
@@ -124,203 +372,1296 @@ func listSourceFiles(dataDir string, filePrefix string, lastN int) ([]string, er
 	return files, nil
 }
 
-func readSources(files []string) []source {
-	var result []source
-	for _, fname := range files {
-		f, err := os.Open(fname)
+// skippedSourcePath reports whether a path below the data directory, split
+// into its elements, is hidden or in the quarantine directory.
+func skippedSourcePath(parts []string) bool {
+	for i, p := range parts {
+		if strings.HasPrefix(p, ".") || (i < len(parts)-1 && p == quarantineDirName) {
+			return true
+		}
+	}
+	return false
+}
+
+func readSource(fname string, w sourceWindow) (source, error) {
+	var s source
+	var err error
+	if *parseCacheDir != "" {
+		s, err = readCachedSource(*parseCacheDir, fname, w)
+	} else {
+		s, err = decodeSource(fname, w)
+	}
+	if err != nil {
+		return s, err
+	}
+
+	if *validateDTD != "off" {
+		problems, err := checkDTD(fname)
 		if err != nil {
-			panic(err)
+			return s, err
+		}
+		for _, p := range problems {
+			log.Print(p)
 		}
+		if len(problems) > 0 && *validateDTD == "fail" {
+			return s, fmt.Errorf("source file '%s' does not conform to the XMLTV DTD", fname)
+		}
+	}
 
-		var s source
-		err = xml.NewDecoder(f).Decode(&s)
+	if *strictParse != "off" {
+		unknown := unknownFields(&s)
+		for _, u := range unknown {
+			log.Printf("%s: unknown %s", fname, u)
+		}
+		if len(unknown) > 0 && *strictParse == "fail" {
+			return s, fmt.Errorf("source file '%s' contains unknown elements or attributes", fname)
+		}
+	}
+
+	for i := range s.ProgramList {
+		s.ProgramList[i].SourceFile = filepath.Base(fname)
+		s.ProgramList[i].Provider = s.Generator
+	}
+
+	return s, nil
+}
+
+// decodeSource parses the source file keeping only the programmes within
+// the window.
+func decodeSource(fname string, w sourceWindow) (source, error) {
+	var s source
+	r, closeSource, err := openSource(fname)
+	if err != nil {
+		return s, err
+	}
+	defer closeSource()
+
+	switch format := sourceFormat(r); {
+	case format == "json":
+		if err = json.NewDecoder(r).Decode(&s); err == nil && !w.empty() {
+			kept := s.ProgramList[:0]
+			for _, p := range s.ProgramList {
+				if w.keeps(p) {
+					kept = append(kept, p)
+				}
+			}
+			s.ProgramList = kept
+		}
+	case !w.empty():
+		s, err = decodeXMLWindow(r, w)
+	default:
+		err = xml.NewDecoder(r).Decode(&s)
+	}
+	if err != nil {
+		return s, fmt.Errorf("unable to parse source file '%s' due: %v", fname, err)
+	}
+	return s, nil
+}
+
+// openSource opens the source file, transparently decompressing it when
+// it is gzip compressed.
+func openSource(fname string) (*bufio.Reader, func(), error) {
+	acquireFile()
+	f, err := sourceFiles.Open(fname)
+	if err != nil {
+		releaseFile()
+		return nil, nil, fmt.Errorf("unable to open source file due: %v", err)
+	}
+
+	r := bufio.NewReader(f)
+	if magic, _ := r.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(r)
 		if err != nil {
-			panic(err)
+			f.Close()
+			releaseFile()
+			return nil, nil, fmt.Errorf("unable to read gzip source file '%s' due: %v", fname, err)
 		}
+		return bufio.NewReader(gz), func() { gz.Close(); f.Close(); releaseFile() }, nil
+	}
+	return r, func() { f.Close(); releaseFile() }, nil
+}
 
-		result = append(result, s)
+// sourceFormat detects the format of the source by its first significant
+// character, skipping a byte order mark and leading whitespace.
+func sourceFormat(r *bufio.Reader) string {
+	for {
+		c, _, err := r.ReadRune()
+		if err != nil {
+			return "xml"
+		}
+		if c == '\uFEFF' || unicode.IsSpace(c) {
+			continue
+		}
+		r.UnreadRune()
+		if c == '{' {
+			return "json"
+		}
+		return "xml"
 	}
+}
 
-	return result
+func init() {
+	flag.Var(&deliverURLs, "deliver", "destination the output files are uploaded to after a successful run: http(s)://host/dir, s3://bucket/prefix or sftp://user@host/dir, can be repeated")
+	flag.Var(&sourceURLs, "sourceURL", "HTTP(S) or s3:// URL of a source file downloaded into the data directory before reading it, can be repeated")
+	flag.Var(&maxAnomaly, "maxAnomalyLevel", "highest anomaly level compared to the previous output which is still published: none, warning or critical")
+	flag.Var(&notifyAnomaly, "notifyAnomalyLevel", "lowest anomaly level for which notifications are sent, failed runs are always notified")
+	flag.Var(&tenants, "tenant", "tenant name, optionally with its own channels file as name=file, can be repeated")
 }
 
 func main() {
 	flag.Parse()
-	channels := readRequestedChannels("channels.csv")
+	if *timezone != "" {
+		loc, err := time.LoadLocation(*timezone)
+		if err != nil {
+			log.Fatalf("unable to load timezone '%s' due: %v", *timezone, err)
+		}
+		time.Local = loc
+	}
+	switch *timeFields {
+	case "end", "duration", "both":
+	default:
+		log.Fatalf("unsupported timeFields value '%s'", *timeFields)
+	}
+	switch *strictParse {
+	case "off", "warn", "fail":
+	default:
+		log.Fatalf("unsupported strictParse value '%s'", *strictParse)
+	}
+	switch *validateDTD {
+	case "off", "warn", "fail":
+	default:
+		log.Fatalf("unsupported validateDTD value '%s'", *validateDTD)
+	}
+	switch *quarantineMode {
+	case "off", "move", "copy":
+	default:
+		log.Fatalf("unsupported quarantine value '%s'", *quarantineMode)
+	}
+	switch *guardrailMode {
+	case "warn", "skip", "fail":
+	default:
+		log.Fatalf("unsupported guardrails value '%s'", *guardrailMode)
+	}
+	switch *timeShiftMode {
+	case "off", "warn", "correct":
+	default:
+		log.Fatalf("unsupported timeShift value '%s'", *timeShiftMode)
+	}
+	switch *peopleFormat {
+	case "joined", "list":
+	default:
+		log.Fatalf("unsupported peopleFormat value '%s'", *peopleFormat)
+	}
+	switch *partialMode {
+	case "allow", "deny":
+	default:
+		log.Fatalf("unsupported partial value '%s'", *partialMode)
+	}
+	switch *indentMode {
+	case "legacy", "compact", "tab":
+	default:
+		if n, err := strconv.Atoi(*indentMode); err != nil || n < 0 {
+			log.Fatalf("unsupported indent value '%s'", *indentMode)
+		}
+	}
+	var err error
+	if fieldPresence, err = parseFieldPresence(*presenceSpec); err != nil {
+		log.Fatal(err)
+	}
+	limitOpenFiles(*maxOpenFiles)
+	if *signOutput || *encryptOutput {
+		var err error
+		if protection, err = loadOutputProtection(*signOutput, *encryptOutput); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *pluginFile != "" {
+		if loadedPlugin, err = loadPlugin(*pluginFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *rulesFile != "" {
+		if transformRules, err = readRulesFile(*rulesFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *primeTimeSpec != "" {
+		if primeTimeHours, err = parsePrimeTime(*primeTimeSpec); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *minCoverageSpec != "" {
+		if minCoverage, err = parseCoverage(*minCoverageSpec); err != nil {
+			log.Fatalf("invalid minCoverage due: %v", err)
+		}
+	}
+	if *countryCodesFile != "" {
+		if err := readCountryOverrides(*countryCodesFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *remindersFile != "" {
+		if subscriptions, err = readSubscriptions(*remindersFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *idPatternSpec != "" {
+		if idPattern, err = regexp.Compile(*idPatternSpec); err != nil {
+			log.Fatalf("invalid channelIDPattern due: %v", err)
+		}
+	}
+	if err := validateDateLocale(*dateLocale); err != nil {
+		log.Fatal(err)
+	}
+	switch *progressMode {
+	case "auto", "on", "off":
+	default:
+		log.Fatalf("unsupported progress value '%s'", *progressMode)
+	}
+	switch *sortTieBreak {
+	case "title", "id":
+	default:
+		log.Fatalf("unsupported sortTieBreak value '%s'", *sortTieBreak)
+	}
+	switch *outputFormat {
+	case "native":
+	case "tva", "eit":
+		if *deltaOutput {
+			log.Fatal("delta output is supported only with the native output format")
+		}
+	default:
+		log.Fatalf("unsupported outputFormat value '%s'", *outputFormat)
+	}
+	if *deltaOutput {
+		switch {
+		case *baseline:
+			log.Fatal("-baseline reads the previous output files, which are not written with -delta")
+		case *timeShiftMode != "off":
+			log.Fatal("-timeShift reads the previous output files, which are not written with -delta")
+		case maxAnomaly < anomalyCritical:
+			log.Fatal("-maxAnomalyLevel compares with the previous output files, which are not written with -delta")
+		}
+	}
+	if err := globalChannelOptions().validate(); err != nil {
+		log.Fatal(err)
+	}
+	if *reportFile != "" {
+		f, err := os.OpenFile(*reportFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			log.Fatalf("unable to open report file due: %v", err)
+		}
+		defer f.Close()
+		reportDetail = f
+	}
+	if *auditFile != "" {
+		f, err := os.OpenFile(*auditFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			log.Fatalf("unable to open audit log due: %v", err)
+		}
+		defer f.Close()
+		auditLog = newAuditWriter(f)
+	}
 
-	files, err := listSourceFiles(*dataDir, *sourceFilePrefix, *sourceFileLimit)
-	if err != nil {
+	if flag.NArg() > 0 {
+		switch flag.Arg(0) {
+		case "convert":
+			convertCommand(flag.Args()[1:])
+		case "run":
+			runCommand(flag.Args()[1:], newSourceCache())
+		case "serve":
+			serveCommand(flag.Args()[1:])
+		case "channels":
+			channelsCommand(flag.Args()[1:])
+		case "init":
+			initCommand(flag.Args()[1:])
+		case "archive":
+			archiveCommand(flag.Args()[1:], nil)
+		case "reconcile":
+			reconcileCommand(flag.Args()[1:])
+		case "inspect":
+			inspectCommand(flag.Args()[1:])
+		case "grep":
+			grepCommand(flag.Args()[1:])
+		case "parse":
+			parseCommand(flag.Args()[1:], nil)
+		case "transform":
+			transformCommand(flag.Args()[1:])
+		case "publish":
+			publishCommand(flag.Args()[1:])
+		case "schema":
+			schemaCommand(flag.Args()[1:])
+		case "completion":
+			completionCommand(flag.Args()[1:])
+		case "help":
+			printUsage(os.Stdout)
+		default:
+			log.Fatalf("unknown command '%s'", flag.Arg(0))
+		}
+		return
+	}
+
+	if _, err := defaultJob().run(nil, newRunReport(os.Stdout)); err != nil {
 		log.Fatal(err)
 	}
+}
 
-	sources := readSources(files)
+// convertCommand converts the sources once like running without a command.
+// With -preview it only prints what the run would change.
+func convertCommand(args []string) {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	preview := fs.Bool("preview", false, "convert in memory and print what would change compared to the files in the output directory without writing anything")
+	fs.Parse(args)
 
-	channelEvents := make(map[string][]programme)
-	for _, s := range sources {
-		for _, e := range s.ProgramList {
-			v, ok := channelEvents[e.ChannelName]
-			if !ok {
-				channelEvents[e.ChannelName] = []programme{e}
-			} else {
-				channelEvents[e.ChannelName] = append(v, e)
-			}
-		}
+	j := defaultJob()
+	j.options = flagRunOptions()
+	j.options.preview = *preview
+	if _, err := j.run(nil, newRunReport(os.Stdout)); err != nil {
+		log.Fatal(err)
 	}
-	fmt.Println("Source file count: ", len(files))
-	fmt.Println("Channels: ", len(channels))
-	fmt.Println("Events: ", len(channelEvents))
-	writtenFiles := 0
+}
+
+// convertedChannel is a converted channel waiting to be written.
+type convertedChannel struct {
+	channel requestedChannel
+	output  *outputChannel
+}
+
+// convertTenant converts the channels requested by the tenant, keeping them
+// in the summary for stageTenant.
+func convertTenant(ctx context.Context, t tenant, outputDir string, sources *sourceChannels,
+	report *runReport, opts *runOptions) (*tenantSummary, error) {
+	channels, err := loadRequestedChannels(t.ChannelsFile)
+	if err != nil {
+		return nil, err
+	}
+	if !opts.preview {
+		persistGeneratedIDs(t.ChannelsFile, channels)
+	}
+	fmt.Fprintln(opts.out, "Channels: ", len(channels))
+
+	summary := &tenantSummary{Name: t.Name, Channels: len(channels), mapped: make(map[string]bool),
+		inactive: make(map[string]requestedChannel)}
+	dir := t.outputDir(outputDir)
 	ids := make(map[string]programme)
+	var converted []convertedChannel
+	now := time.Now()
+	var active []requestedChannel
 	for _, channel := range channels {
-		events, ok := channelEvents[channel.Name]
-		if !ok {
+		if !channel.ignored() {
+			summary.mapped[channel.ID] = true
+		}
+		if channel.ignored() {
 			continue
 		}
-		outputChannel := &outputChannel{Events: outputEvents{Values: make([]outputEvent, 0)}}
-		outputChannel.ID = channel.ID
-		outputChannel.Name = channel.Name
-		spans := timespan.Spans{}
-		eventByStartTime := make(map[string]outputEvent)
-		for _, event := range events {
-			startTime, err := time.Parse(inDateLayout, event.Start)
-			if err != nil {
-				log.Fatalf("could not parse start time due: %v", err)
+		if channel.Options.activeAt(now) {
+			active = append(active, channel)
+		} else {
+			summary.inactive[channel.ID] = channel
+		}
+	}
+	active, sourceNames := mergeChannels(active, sources)
+	unmapped := unmappedChannels(channels, sources)
+	for _, channel := range active {
+		if _, ok := sourceNames[channel.ID]; !ok {
+			suggestions := suggestChannelNames(channel.Name, unmapped, 3)
+			report.emptyChannel(channel, suggestions)
+			summary.EmptyChannels = append(summary.EmptyChannels, emptyChannel{ID: channel.ID, Name: channel.Name, Suggestions: suggestions})
+		}
+	}
+	// checkGuardrails reports the guardrail violations of a channel with
+	// the given number of events, whether the channel is skipped and the
+	// error failing the run.
+	checkGuardrails := func(channel requestedChannel, events int) (bool, error) {
+		violations := channelGuardrailViolations(events)
+		if len(violations) == 0 {
+			return false, nil
+		}
+		for _, v := range violations {
+			report.guardrail(channel, v)
+		}
+		summary.GuardrailViolations++
+		if opts.guardrailMode == "fail" {
+			return true, fmt.Errorf("channel %s violates guardrails: %s", channel.ID, strings.Join(violations, ", "))
+		}
+		return opts.guardrailMode == "skip", nil
+	}
+	bar := newProgress("converting channels", len(active))
+	for _, channel := range active {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		bar.add(1)
+		names, ok := sourceNames[channel.ID]
+		if !ok && !opts.baseline {
+			if _, err := checkGuardrails(channel, 0); err != nil {
+				return nil, err
 			}
-			endTime, err := time.Parse(inDateLayout, event.Stop)
-			if err != nil {
-				log.Fatalf("could not parse start time due: %v", err)
+			continue
+		}
+		events, err := mergedEvents(sources, names)
+		if err != nil {
+			return nil, err
+		}
+		if opts.timeShiftMode != "off" || channel.Options.CorrectShift {
+			if prev, err := readPreviousOutput(t, dir, channel); err == nil {
+				events = checkTimeShift(&channel, prev, events, report)
 			}
+		}
+		outputChannel, err := convertChannel(channel, events, ids, opts.rules, report)
+		if err != nil && opts.partialMode == "allow" {
+			report.failedChannel(channel, err)
+			summary.FailedChannels = append(summary.FailedChannels, failedChannel{ID: channel.ID, Name: channel.Name,
+				Error: err.Error()})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
 
-			id := fmt.Sprintf("%d", startTime.UTC().Unix())
-			idc := fmt.Sprintf("%s-%s", id, event.ChannelName)
-
-			v, ok := ids[idc]
-			if !ok {
-				ids[idc] = event
-			} else {
-				if v.ChannelName == event.ChannelName {
-					continue
+		sortEvents(outputChannel.Events.Values)
+		t.prefixIDs(outputChannel)
+		if opts.baseline && len(outputChannel.Events.Values) == 0 {
+			if prev, err := readPreviousOutput(t, dir, channel); err == nil {
+				if n := fillFromBaseline(prev, outputChannel, now); n > 0 {
+					report.baseline(channel, n)
+				}
+			}
+			if len(outputChannel.Events.Values) == 0 {
+				if _, err := checkGuardrails(channel, 0); err != nil {
+					return nil, err
 				}
+				continue
+			}
+		}
+		if opts.eventHashes {
+			for i := range outputChannel.Events.Values {
+				outputChannel.Events.Values[i].Hash = eventHash(outputChannel.Events.Values[i])
 			}
+		}
+		if opts.statsOutput {
+			outputChannel.Stats = newChannelStats(outputChannel, now)
+		}
 
-			actors := strings.Join(event.Credits.Actors, ", ")
-			directors := strings.Join(event.Credits.Producers, ", ")
-			countries := strings.Join(event.Country, ", ")
+		skip, err := checkGuardrails(channel, len(outputChannel.Events.Values))
+		if err != nil {
+			return nil, err
+		}
+		if skip {
+			continue
+		}
+		converted = append(converted, convertedChannel{channel: channel, output: outputChannel})
+	}
+	bar.finish()
+	summary.converted = converted
+	return summary, nil
+}
 
-			var t = event.Title[0]
+// stagedTenant is a converted tenant which passed the checks of its output,
+// with the output files encoded and ready to be written.
+type stagedTenant struct {
+	t            tenant
+	outputDir    string
+	dir          string
+	summary      *tenantSummary
+	prevManifest *manifest
+	manifest     *manifest
+	comment      string
+	tasks        []writeTask
+	opts         *runOptions
+}
 
-			for i, title := range event.Title {
-				if title.Lang == "bg" {
-					t = event.Title[i]
-				}
+// stageTenant checks the converted channels of the tenant for anomalies and
+// the output size guardrail and encodes its output files, without touching
+// the output directory. A preview prints the changes instead.
+func stageTenant(t tenant, outputDir string, files []string, summary *tenantSummary, report *runReport,
+	opts *runOptions) (*stagedTenant, error) {
+	dir := t.outputDir(outputDir)
+	now := time.Now()
+	keepCoveredOutput(t, dir, summary, report, now)
+	converted := summary.converted
+	level := detectAnomalies(t, dir, summary, report)
+	summary.AnomalyLevel = level.String()
+	if opts.preview {
+		previewChanges(opts.out, t, dir, converted)
+		return &stagedTenant{t: t, summary: summary, opts: opts}, nil
+	}
+	if level > maxAnomaly {
+		return nil, fmt.Errorf("anomaly level %s exceeds the maximum %s, output is not published", level, maxAnomaly)
+	}
+
+	prevManifest, err := readManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	manifest := newManifest(t, converted)
+	markFailedChannels(manifest, prevManifest, summary.FailedChannels)
+	if err := assignEITEventIDs(manifest, prevManifest, converted); err != nil {
+		return nil, err
+	}
+	summary.LineupChanges = lineupChanges(prevManifest, manifest, summary)
+	for _, c := range summary.LineupChanges {
+		report.lineup(c)
+	}
+	var comment string
+	if opts.headerComment {
+		comment = generatedComment(files, now)
+	}
+
+	var tasks []writeTask
+	for _, c := range converted {
+		c := c
+		if len(c.channel.Options.Packages) > 0 {
+			c.output.Packages = strings.Join(c.channel.Options.Packages, ",")
+		}
+		summary.Events += len(c.output.Events.Values)
+
+		for _, channelDir := range packageDirs(dir, c.channel) {
+			outputFileName, encode := channelFile(t, opts.outputFormat, channelDir, comment, c)
+			if opts.deltaOutput {
+				outputFileName = filepath.Join(channelDir, t.deltaFileName(c.channel.ID))
+				encode = func(w io.Writer) error { return encodeXML(w, comment, channelDelta(c.output, prevManifest)) }
 			}
+			tasks = append(tasks, writeTask{channel: c.channel, fileName: outputFileName, encode: encode})
+		}
+	}
 
-			overlaps := spans.IntersectionBetween(timespan.Spans{
-				timespan.New(startTime, endTime),
-			})
+	encodeFiles(tasks, opts.writeWorkers)
+	var outputBytes int64
+	for _, task := range tasks {
+		if task.err != nil {
+			return nil, fmt.Errorf("could not encode output file '%s' due: %v", task.fileName, task.err)
+		}
+		outputBytes += int64(len(task.data))
+		if opts.maxOutputSize > 0 && outputBytes > opts.maxOutputSize {
+			v := fmt.Sprintf("total output size %d bytes exceeds %d bytes", outputBytes, opts.maxOutputSize)
+			report.guardrail(task.channel, v)
+			if opts.guardrailMode == "fail" {
+				return nil, fmt.Errorf("%s", v)
+			}
+			break
+		}
+	}
+	return &stagedTenant{t: t, outputDir: outputDir, dir: dir, summary: summary, prevManifest: prevManifest,
+		manifest: manifest, comment: comment, tasks: tasks, opts: opts}, nil
+}
 
-			if len(overlaps) > 0 {
-				fmt.Println("collision detected")
-				fmt.Printf("   %s channel=\"%s\" start=\"%s\" stop=\"%s\"\n", channel.ID, channel.Name, event.Start, event.Stop)
-				existing, ok := eventByStartTime[endTime.UTC().Format(outDateLayout)]
+// writeTenants writes the staged tenants in order. They are written once
+// every tenant of the run was staged, so a tenant failing its checks keeps
+// the output of all tenants from being published.
+func writeTenants(staged []*stagedTenant, deadline *runDeadline, opts *runOptions) ([]*tenantSummary, error) {
+	if !opts.preview {
+		if err := deadline.startWriting(); err != nil {
+			return nil, err
+		}
+	}
+	var result []*tenantSummary
+	for _, s := range staged {
+		ts, err := s.write()
+		if err != nil {
+			return nil, err
+		}
+		logCreatedFiles(ts)
+		result = append(result, ts)
+	}
+	return result, nil
+}
 
-				if ok {
-					fmt.Println("   event desc: ", existing.Description)
-				}
-				fmt.Println("   skip desc: ", event.Description.Name)
-				fmt.Println("   startTime: ", event.Start)
-				fmt.Println("   endTime  : ", event.Stop)
-				fmt.Println("event skipped")
-				continue
-			} else {
-				spans = append(spans, timespan.New(startTime, endTime))
+// write writes the output files of the staged tenant with its manifest and
+// the further outputs of the run, nothing for a preview.
+func (s *stagedTenant) write() (*tenantSummary, error) {
+	if s.opts.preview {
+		return s.summary, nil
+	}
+	t, dir, summary, tasks := s.t, s.dir, s.summary, s.tasks
+	prevManifest, manifest, comment, converted := s.prevManifest, s.manifest, s.comment, summary.converted
+	now := time.Now()
+	if err := mkdirAll(dir); err != nil {
+		return nil, fmt.Errorf("unable to create output directory due: %v", err)
+	}
+	created := map[string]bool{dir: true}
+	for _, task := range tasks {
+		if channelDir := filepath.Dir(task.fileName); !created[channelDir] {
+			if err := mkdirAll(channelDir); err != nil {
+				return nil, fmt.Errorf("unable to create output directory due: %v", err)
 			}
+			created[channelDir] = true
+		}
+	}
 
-			outputEvent := outputEvent{
-				ID:                  id,
-				Name:                t.Name,
-				StartTime:           startTime.UTC().Format(outDateLayout),
-				EndTime:             endTime.UTC().Format(outDateLayout),
-				Perex:               event.Description.Name,
-				Description:         event.Description.Name,
-				Actors:              actors,
-				Directors:           directors,
-				ProductionYear:      event.Date,
-				ProductionCountries: countries,
+	writeFiles(tasks, s.opts.writeWorkers)
+	var failed []string
+	for _, task := range tasks {
+		if task.err != nil {
+			failed = append(failed, fmt.Sprintf("could not write to output file '%s' due: %v", task.fileName, task.err))
+			continue
+		}
+		summary.WrittenFiles++
+		summary.OutputBytes += int64(len(task.data))
+		if rel, err := filepath.Rel(s.outputDir, task.fileName); err == nil {
+			summary.files = append(summary.files, rel)
+			if *signOutput {
+				summary.files = append(summary.files, rel+".sig")
 			}
+		}
+	}
+	if len(failed) > 0 {
+		return nil, fmt.Errorf("%d of %d output files failed: %s", len(failed), len(tasks), strings.Join(failed, "; "))
+	}
 
-			eventByStartTime[endTime.UTC().Format(outDateLayout)] = outputEvent
+	if s.opts.deltaOutput && prevManifest != nil {
+		for id, prev := range prevManifest.Channels {
+			if _, ok := manifest.Channels[id]; ok {
+				continue
+			}
+			outputFileName := filepath.Join(dir, t.deltaFileName(id))
+			if err := marshalDelta(outputFileName, comment, removedChannelDelta(id, prev)); err != nil {
+				return nil, fmt.Errorf("could not write to output file '%s' due: %v", outputFileName, err)
+			}
+		}
+	}
+	if err := writeManifest(dir, manifest); err != nil {
+		return nil, err
+	}
+	if !s.opts.deltaOutput && prevManifest != nil {
+		if err := removeInactiveOutput(t, dir, summary, prevManifest); err != nil {
+			return nil, err
+		}
+	}
+	if *nowNextOutput {
+		if err := writeNowNext(dir, converted, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	if *primeTimeSpec != "" {
+		if err := writePrimeTime(dir, converted, primeTimeHours); err != nil {
+			return nil, err
+		}
+	}
+	if *remindersFile != "" {
+		if err := writeReminders(dir, converted, subscriptions, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	if *redisAddr != "" {
+		channels := make([]*outputChannel, 0, len(converted))
+		for _, c := range converted {
+			channels = append(channels, c.output)
+		}
+		if err := updateRedis(*redisAddr, *redisPrefix, channels, time.Now()); err != nil {
+			log.Printf("unable to update redis due: %v", err)
+		}
+	}
+	if *parquetDir != "" {
+		if err := writeParquetPartitions(t.outputDir(*parquetDir), "events.parquet", time.Time{}, converted); err != nil {
+			return nil, err
+		}
+	}
+	if archiveDir != "" {
+		name := fmt.Sprintf("run-%s.parquet", now.UTC().Format("20060102T150405Z"))
+		if err := writeParquetPartitions(t.outputDir(archiveDir), name, now, converted); err != nil {
+			return nil, err
+		}
+	}
+	return summary, nil
+}
 
-			outputChannel.Events.Values = append(outputChannel.Events.Values, outputEvent)
+// channelFile returns the name of the output file of the channel in the
+// format and the function encoding it.
+func channelFile(t tenant, format, dir, comment string, c convertedChannel) (string, func(w io.Writer) error) {
+	switch format {
+	case "tva":
+		return filepath.Join(dir, t.tvaFileName(c.channel.ID)), func(w io.Writer) error {
+			return encodeTVA(w, comment, c.output, c.channel.Options.Lang)
 		}
+	case "eit":
+		return filepath.Join(dir, t.eitFileName(c.channel.ID)), func(w io.Writer) error {
+			return encodeEIT(w, c.output, c.channel.Options.Lang)
+		}
+	}
+	return filepath.Join(dir, t.fileName(c.channel.ID)), func(w io.Writer) error { return encodeChannel(w, comment, c.output) }
+}
 
-		sort.Sort(byStartTime(outputChannel.Events.Values))
+// writeTask is an output file waiting to be written.
+type writeTask struct {
+	channel  requestedChannel
+	fileName string
+	encode   func(w io.Writer) error
 
-		if _, err := os.Stat(*outputDir); os.IsNotExist(err) {
-			if err := os.MkdirAll(*outputDir, os.ModePerm); err != nil {
-				log.Fatalf("unable to create output directory due: %v", err)
+	// data and sig are the encoded content, encrypted and signed as
+	// configured, written by writeFiles.
+	data, sig []byte
+	err       error
+}
+
+// encodeFiles encodes the content of the tasks with at most workers files
+// encoded at once, recording the error of a failed one.
+func encodeFiles(tasks []writeTask, workers int) {
+	runWriteTasks("encoding files", tasks, workers, func(task *writeTask) error {
+		var buf bytes.Buffer
+		if err := task.encode(&buf); err != nil {
+			return err
+		}
+		task.data = buf.Bytes()
+		if protection != nil {
+			var err error
+			task.data, task.sig, err = protection.seal(task.data)
+			return err
+		}
+		return nil
+	})
+}
+
+// writeFiles writes the encoded tasks with at most workers files written at
+// once, recording the error of a failed one.
+func writeFiles(tasks []writeTask, workers int) {
+	runWriteTasks("writing files", tasks, workers, func(task *writeTask) error {
+		return writeSealedFile(task.fileName, task.data, task.sig)
+	})
+}
+
+func runWriteTasks(label string, tasks []writeTask, workers int, run func(task *writeTask) error) {
+	if workers < 1 {
+		workers = 1
+	}
+	bar := newProgress(label, len(tasks))
+	defer bar.finish()
+	next := make(chan *writeTask)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for task := range next {
+				bar.add(1)
+				if task.err == nil {
+					task.err = run(task)
+				}
 			}
+		}()
+	}
+	for i := range tasks {
+		next <- &tasks[i]
+	}
+	close(next)
+	wg.Wait()
+}
+
+// removeInactiveOutput removes the output files of the channels published
+// by the previous run which are now outside of their active period, so they
+// disappear from the output directory together with the manifest. With
+// -deltaOutput the removal delta written instead expires them.
+func removeInactiveOutput(t tenant, dir string, summary *tenantSummary, prev *manifest) error {
+	for id, channel := range summary.inactive {
+		if _, ok := prev.Channels[id]; !ok {
+			continue
 		}
+		for _, channelDir := range packageDirs(dir, channel) {
+			fileName, _ := channelFile(t, *outputFormat, channelDir, "", convertedChannel{channel: channel})
+			for _, name := range []string{fileName, fileName + ".sig"} {
+				if err := outputFiles.Remove(name); err != nil && !os.IsNotExist(err) {
+					return fmt.Errorf("unable to remove output of inactive channel %s due: %v", id, err)
+				}
+			}
+		}
+		log.Printf("Removed the output of inactive channel %s\n", id)
+	}
+	return nil
+}
 
-		outputFileName := filepath.Join(*outputDir, fmt.Sprintf("n_events_%s.xml", channel.ID))
-		if err := marshalChannel(outputFileName, outputChannel); err != nil {
-			log.Fatalf("could not write to output file '%s' due: %v", outputFileName, err)
+// packageDirs returns the directories the channel is written to, which with
+// -packageTrees are the subdirectories of its packages.
+func packageDirs(dir string, channel requestedChannel) []string {
+	if !*packageTrees || len(channel.Options.Packages) == 0 {
+		return []string{dir}
+	}
+	var dirs []string
+	for _, p := range channel.Options.Packages {
+		dirs = append(dirs, filepath.Join(dir, p))
+	}
+	return dirs
+}
+
+// channelGuardrailViolations checks the number of events of a channel against
+// the configured limits.
+func channelGuardrailViolations(events int) []string {
+	var violations []string
+	if *maxChannelEvents > 0 && events > *maxChannelEvents {
+		violations = append(violations, fmt.Sprintf("%d events exceed the maximum of %d", events, *maxChannelEvents))
+	}
+	if *minChannelEvents > 0 && events < *minChannelEvents {
+		violations = append(violations, fmt.Sprintf("%d events are below the minimum of %d", events, *minChannelEvents))
+	}
+	return violations
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// parseProgrammeDate splits the XMLTV programme date, which may be anything
+// from "2004" to "20040315120000 +0100", into a production year and an original
+// air date. The air date is returned only when the day is known.
+func parseProgrammeDate(date string) (year string, airDate string) {
+	date = strings.TrimSpace(date)
+	if len(date) < 4 {
+		return "", ""
+	}
+	for _, r := range date[:4] {
+		if r < '0' || r > '9' {
+			return "", ""
 		}
-		writtenFiles++
 	}
+	year = date[:4]
+	if len(date) >= 8 {
+		if t, err := time.Parse("20060102", date[:8]); err == nil {
+			airDate = t.Format("2006-01-02")
+		}
+	}
+	return year, airDate
+}
+
+// eventTags returns the distinct categories and keywords of the programme
+// in the order of their appearance.
+func eventTags(p programme) *outputTags {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, list := range [][]title{p.Category, p.Keywords} {
+		for _, t := range list {
+			v := strings.TrimSpace(t.Name)
+			if v == "" || seen[v] {
+				continue
+			}
+			seen[v] = true
+			tags = append(tags, v)
+		}
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	return &outputTags{Values: tags}
+}
 
-	log.Printf("Created files: %d\n", writtenFiles)
+// parseSourceTime parses an XMLTV time, which is in local time when it has
+// no offset.
+func parseSourceTime(v string) (time.Time, error) {
+	t, err := time.Parse(inDateLayout, v)
+	if err != nil && len(v) == len(inLocalDateLayout) {
+		return time.ParseInLocation(inLocalDateLayout, v, time.Local)
+	}
+	return t, err
+}
 
+// sortEvents orders the events by their start time and then by the
+// -sortTieBreak key, keeping the input order of fully equal events so the
+// output is deterministic.
+func sortEvents(events []outputEvent) {
+	starts := make([]time.Time, len(events))
+	for i, e := range events {
+		starts[i], _ = time.Parse(outDateLayout, e.StartTime)
+	}
+	sort.Stable(byStartTime{events: events, starts: starts})
 }
 
-type byStartTime []outputEvent
+type byStartTime struct {
+	events []outputEvent
+	starts []time.Time
+}
 
-func (a byStartTime) Len() int           { return len(a) }
-func (a byStartTime) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a byStartTime) Less(i, j int) bool { return a[i].ID < a[j].ID }
+func (a byStartTime) Len() int { return len(a.events) }
+func (a byStartTime) Swap(i, j int) {
+	a.events[i], a.events[j] = a.events[j], a.events[i]
+	a.starts[i], a.starts[j] = a.starts[j], a.starts[i]
+}
+func (a byStartTime) Less(i, j int) bool {
+	if !a.starts[i].Equal(a.starts[j]) {
+		return a.starts[i].Before(a.starts[j])
+	}
+	if *sortTieBreak == "id" {
+		return a.events[i].ID < a.events[j].ID
+	}
+	return a.events[i].Name < a.events[j].Name
+}
 
-func marshalChannel(fileName string, channel *outputChannel) error {
-	f, err := os.Create(fileName)
+// readOutputChannel reads a previously written output file.
+func readOutputChannel(fileName string) (*outputChannel, error) {
+	data, err := readOutputFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	c, err := decodeChannel(data)
 	if err != nil {
+		return nil, fmt.Errorf("unable to parse output file '%s' due: %v", fileName, err)
+	}
+	return c, nil
+}
 
-		return fmt.Errorf("unable to open output file due: %v", err)
+func decodeChannel(data []byte) (*outputChannel, error) {
+	var tmp struct {
+		outputChannel
+		XMLName struct{} `xml:"channel"`
 	}
-	defer f.Close()
+	if err := xml.NewDecoder(bytes.NewReader(data)).Decode(&tmp); err != nil {
+		return nil, err
+	}
+	return &tmp.outputChannel, nil
+}
 
+func encodeChannel(w io.Writer, comment string, channel *outputChannel) error {
 	tmp := struct {
 		outputChannel
 		XMLName struct{} `xml:"channel"`
 	}{outputChannel: *channel}
 
-	enc := xml.NewEncoder(f)
-	enc.Indent("  ", "    ")
+	return encodeXML(w, comment, tmp)
+}
+
+// xmlIndent returns the prefix and indent of the -indent mode: legacy, the
+// original indentation, compact without any whitespace, tab or the number of
+// spaces per level.
+func xmlIndent(mode string) (string, string, bool) {
+	switch mode {
+	case "legacy":
+		return "  ", "    ", true
+	case "compact":
+		return "", "", false
+	case "tab":
+		return "", "\t", true
+	}
+	n, err := strconv.Atoi(mode)
+	if err != nil || n < 0 {
+		return "", "", false
+	}
+	return "", strings.Repeat(" ", n), true
+}
 
-	f.Write([]byte(xml.Header))
+// outputWriters reuses the buffers of the output file writers, which are
+// written concurrently by the write workers.
+var outputWriters = sync.Pool{New: func() interface{} { return bufio.NewWriterSize(nil, 64*1024) }}
 
-	if err := enc.Encode(tmp); err != nil {
-		return fmt.Errorf("unable to marshall content due: %v", err)
+// generatedComment describes the run writing the output files, e.g
+// "generated by epgtool 1.2.0 at 2024-01-14T03:00:00Z from CMS-20240114.xml",
+// so files found elsewhere can be traced back to it.
+func generatedComment(files []string, now time.Time) string {
+	names := make([]string, len(files))
+	for i, f := range files {
+		names[i] = filepath.Base(f)
 	}
+	return fmt.Sprintf("generated by epgtool %s at %s from %s", version, now.UTC().Format(outDateLayout), strings.Join(names, ", "))
+}
 
+// writeXMLFile writes v as an XML document, preceded by the comment unless it
+// is empty.
+func writeXMLFile(fileName string, comment string, v interface{}) error {
+	return writeOutputFile(fileName, func(f io.Writer) error { return encodeXML(f, comment, v) })
+}
+
+func encodeXML(f io.Writer, comment string, v interface{}) error {
+	w := outputWriters.Get().(*bufio.Writer)
+	w.Reset(f)
+	defer func() {
+		w.Reset(nil)
+		outputWriters.Put(w)
+	}()
+
+	enc := xml.NewEncoder(w)
+	if prefix, indent, ok := xmlIndent(*indentMode); ok {
+		enc.Indent(prefix, indent)
+	}
+
+	if _, err := w.WriteString(xml.Header); err != nil {
+		return fmt.Errorf("unable to write header due: %v", err)
+	}
+	if comment != "" {
+		// "--" is not allowed within a comment
+		comment = strings.Replace(comment, "--", "- -", -1)
+		if _, err := fmt.Fprintf(w, "<!-- %s -->\n", comment); err != nil {
+			return fmt.Errorf("unable to write header due: %v", err)
+		}
+	}
+
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("unable to marshall content due: %v", err)
+	}
+	if err := enc.Flush(); err != nil {
+		return fmt.Errorf("unable to marshall content due: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("unable to write output file due: %v", err)
+	}
 	return nil
 }
 
-func readRequestedChannels(fileName string) []requestedChannel {
-	channelsFile, err := os.Open(fileName)
+// readRequestedChannels reads the channels file. Lines starting with # are
+// comments, a header row such as "id,name" is skipped and rows without an ID
+// or a name are reported and ignored. Rows with the ID "-" are returned, but
+// never converted. IDs generated by -generateChannelIDs are written back into
+// the file, so they stay assigned when channels are added later.
+func readRequestedChannels(fileName string) ([]requestedChannel, error) {
+	channels, err := loadRequestedChannels(fileName)
+	if err == nil {
+		persistGeneratedIDs(fileName, channels)
+	}
+	return channels, err
+}
+
+// loadRequestedChannels reads the channels file like readRequestedChannels
+// without writing the generated IDs back, for readers which must leave the
+// file as it is, e.g a preview.
+func loadRequestedChannels(fileName string) ([]requestedChannel, error) {
+	channels, problems, err := parseChannelsFile(fileName)
+	for _, p := range problems {
+		log.Print(p)
+	}
+	return channels, err
+}
+
+// persistGeneratedIDs writes the generated channel IDs into the channels
+// file. A file which is not writable only loses the persistence.
+func persistGeneratedIDs(fileName string, channels []requestedChannel) {
+	var generated []string
+	for _, c := range channels {
+		if c.generatedID {
+			generated = append(generated, fmt.Sprintf("%s %s", c.ID, c.Name))
+		}
+	}
+	if len(generated) == 0 {
+		return
+	}
+	if err := writeRequestedChannels(fileName, channels); err != nil {
+		log.Printf("unable to write the generated channel IDs into %s due: %v", fileName, err)
+		return
+	}
+	log.Printf("Wrote the generated channel IDs into %s: %s", fileName, strings.Join(generated, ", "))
+}
+
+// parseChannelsFile reads the channels file from the source store returning
+// the valid channels and a description of every skipped row.
+func parseChannelsFile(fileName string) ([]requestedChannel, []string, error) {
+	channelsFile, err := sourceFiles.Open(fileName)
 	if err != nil {
-		log.Fatalf("channels file doesn't exists")
+		return nil, nil, fmt.Errorf("channels file doesn't exists")
 	}
 	defer channelsFile.Close()
-	cr := csv.NewReader(channelsFile)
+	br := bufio.NewReader(channelsFile)
+	if bom, err := br.Peek(3); err == nil && string(bom) == "\ufeff" {
+		br.Discard(3)
+	}
+	cr := csv.NewReader(br)
+	cr.FieldsPerRecord = -1
+	cr.Comment = '#'
+	cr.TrimLeadingSpace = true
+
+	result := make([]requestedChannel, 0)
+	var problems []string
+	var pending []int
+
+	for first := true; ; first = false {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if perr, ok := err.(*csv.ParseError); ok {
+			problems = append(problems, fmt.Sprintf("%s:%d: skipping malformed channel row: %v", fileName, perr.StartLine, perr.Err))
+			continue
+		}
+		if err != nil {
+			return nil, problems, fmt.Errorf("could not read channels file due: %v", err)
+		}
+		line, _ := cr.FieldPos(0)
+
+		for i := range rec {
+			rec[i] = strings.TrimSpace(rec[i])
+		}
+		if first && isChannelsHeader(rec) {
+			continue
+		}
+
+		if len(rec) >= 2 && rec[0] == "" && rec[1] != "" && *generateIDs {
+			pending = append(pending, len(result))
+		} else if len(rec) < 2 || rec[0] == "" || rec[1] == "" {
+			problems = append(problems, fmt.Sprintf("%s:%d: skipping channel without ID or name: %q", fileName, line, rec))
+			continue
+		} else if idPattern != nil && rec[0] != ignoredChannelID && !idPattern.MatchString(rec[0]) {
+			problems = append(problems, fmt.Sprintf("%s:%d: skipping channel '%s' with ID '%s' not matching %s", fileName, line, rec[1], rec[0], idPattern))
+			continue
+		}
+
+		opts := globalChannelOptions()
+		spec := ""
+		if len(rec) > 2 {
+			spec = rec[2]
+			opts, err = parseChannelOptions(opts, spec)
+			if err != nil {
+				return nil, problems, fmt.Errorf("invalid options for channel on line %d due: %v", line, err)
+			}
+		}
+		result = append(result, requestedChannel{ID: rec[0], Name: rec[1], Options: opts, OptionSpec: spec, Line: line})
+	}
 
-	channels, err := cr.ReadAll()
+	used := make(map[string]bool)
+	for _, c := range result {
+		used[c.ID] = true
+	}
+	for _, i := range pending {
+		result[i].ID = generateChannelID(result[i].Name, used)
+		result[i].generatedID = true
+		used[result[i].ID] = true
+	}
+	return result, problems, nil
+}
+
+// generateChannelID derives a stable six digit ID from the channel name,
+// probing the following numbers when it is already used.
+func generateChannelID(name string, used map[string]bool) string {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	n := h.Sum32()%900000 + 100000
+	for used[strconv.Itoa(int(n))] {
+		n = (n-100000+1)%900000 + 100000
+	}
+	return strconv.Itoa(int(n))
+}
+
+func isChannelsHeader(rec []string) bool {
+	if len(rec) < 2 {
+		return false
+	}
+	switch strings.ToLower(rec[0]) {
+	case "id", "channel_id", "channelid", "channel id":
+	default:
+		return false
+	}
+	switch strings.ToLower(rec[1]) {
+	case "name", "channel", "channel_name", "channelname", "channel name":
+		return true
+	}
+	return false
+}
+
+// writeRequestedChannels replaces the channel rows of the channels file with
+// the given channels. Unchanged rows are kept as written, rows of missing
+// channels are removed and channels without a line are appended, while the
+// comments, the header and the skipped rows stay in place. The file is
+// replaced at once in the source store.
+func writeRequestedChannels(fileName string, channels []requestedChannel) error {
+	files, err := dataFiles()
 	if err != nil {
-		log.Fatalf("could not read channels file due: %v", err)
+		return fmt.Errorf("unable to write channels file due: %v", err)
+	}
+	data, err := files.ReadFile(fileName)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("unable to read channels file due: %v", err)
+	}
+	previous := make(map[int]requestedChannel)
+	if len(data) > 0 {
+		rows, _, err := parseChannelsFile(fileName)
+		if err != nil {
+			return err
+		}
+		for _, c := range rows {
+			previous[c.Line] = c
+		}
+	}
+	kept := make(map[int]requestedChannel)
+	var added []requestedChannel
+	for _, c := range channels {
+		if _, ok := previous[c.Line]; ok && c.Line > 0 {
+			kept[c.Line] = c
+		} else {
+			added = append(added, c)
+		}
 	}
 
-	result := make([]requestedChannel, 0)
+	var buf bytes.Buffer
+	body := data
+	if bytes.HasPrefix(body, []byte("\ufeff")) {
+		buf.WriteString("\ufeff")
+		body = body[3:]
+	}
+	cw := csv.NewWriter(&buf)
+	cw.UseCRLF = bytes.Contains(body, []byte("\r\n"))
+	writeRow := func(c requestedChannel) {
+		rec := []string{c.ID, c.Name}
+		if c.OptionSpec != "" {
+			rec = append(rec, c.OptionSpec)
+		}
+		cw.Write(rec)
+		cw.Flush()
+	}
+	pos := 0
+	for _, r := range channelRecords(body) {
+		if _, ok := previous[r.line]; !ok {
+			continue
+		}
+		buf.Write(body[pos:r.start])
+		pos = r.end
+		if c, ok := kept[r.line]; ok {
+			if c.ID == r.field(0) && c.Name == r.field(1) && c.OptionSpec == r.field(2) {
+				buf.Write(body[r.start:r.end])
+			} else {
+				writeRow(c)
+			}
+		}
+	}
+	buf.Write(body[pos:])
+	if len(added) > 0 && len(body) > 0 && body[len(body)-1] != '\n' {
+		buf.WriteString("\n")
+	}
+	for _, c := range added {
+		writeRow(c)
+	}
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("unable to write channels file due: %v", err)
+	}
+	return files.Write(fileName, func(w io.Writer) error { _, err := w.Write(buf.Bytes()); return err })
+}
+
+// channelRecord is the byte range of a CSV record of the channels file, the
+// line it starts on and its trailing line break included.
+type channelRecord struct {
+	line       int
+	start, end int
+	fields     []string
+}
 
-	for _, rec := range channels {
-		result = append(result, requestedChannel{ID: rec[0], Name: rec[1]})
+// field returns the trimmed field i of the record, empty when it is missing.
+func (r channelRecord) field(i int) string {
+	if i >= len(r.fields) {
+		return ""
+	}
+	return strings.TrimSpace(r.fields[i])
+}
+
+// channelRecords returns the ranges of the records of the channels file
+// content, read the way parseChannelsFile reads them.
+func channelRecords(body []byte) []channelRecord {
+	lineStarts := []int{0, 0}
+	for i, b := range body {
+		if b == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	cr := csv.NewReader(bytes.NewReader(body))
+	cr.FieldsPerRecord = -1
+	cr.Comment = '#'
+	cr.TrimLeadingSpace = true
+	var result []channelRecord
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if _, ok := err.(*csv.ParseError); ok {
+				continue
+			}
+			break
+		}
+		line, _ := cr.FieldPos(0)
+		result = append(result, channelRecord{line: line, start: lineStarts[line], end: int(cr.InputOffset()), fields: rec})
 	}
 	return result
 }
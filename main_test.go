@@ -0,0 +1,65 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEventTags(t *testing.T) {
+	tests := []struct {
+		name     string
+		category []title
+		keywords []title
+		want     []string
+	}{
+		{"none", nil, nil, nil},
+		{"categories", []title{{Name: "Sport"}, {Name: "Football", Lang: "en"}}, nil, []string{"Sport", "Football"}},
+		{"keywords after categories", []title{{Name: "Movie"}}, []title{{Name: "classic"}}, []string{"Movie", "classic"}},
+		{"trimmed", []title{{Name: "  News\n"}}, nil, []string{"News"}},
+		{"duplicates", []title{{Name: "Drama"}, {Name: "Drama"}}, []title{{Name: " Drama "}, {Name: "Crime"}}, []string{"Drama", "Crime"}},
+		{"case kept", []title{{Name: "drama"}, {Name: "Drama"}}, nil, []string{"drama", "Drama"}},
+		{"only empty", []title{{Name: ""}}, []title{{Name: " "}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := eventTags(programme{Category: tt.category, Keywords: tt.keywords})
+			if tt.want == nil {
+				if got != nil {
+					t.Errorf("got tags %q, want none", got.Values)
+				}
+				return
+			}
+			if got == nil || !reflect.DeepEqual(got.Values, tt.want) {
+				t.Errorf("got tags %+v, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseProgrammeDate(t *testing.T) {
+	tests := []struct {
+		date     string
+		wantYear string
+		wantAir  string
+	}{
+		{"", "", ""},
+		{"1999", "1999", ""},
+		{" 1999\n", "1999", ""},
+		{"199906", "1999", ""},
+		{"19990612", "1999", "1999-06-12"},
+		{"19990612203000", "1999", "1999-06-12"},
+		{"19990612203000 +0200", "1999", "1999-06-12"},
+		{"19990612 -0500", "1999", "1999-06-12"},
+		{"19990231", "1999", ""},
+		{"1999-06-12", "1999", ""},
+		{"99", "", ""},
+		{"unknown", "", ""},
+		{"19x9", "", ""},
+	}
+	for _, tt := range tests {
+		year, air := parseProgrammeDate(tt.date)
+		if year != tt.wantYear || air != tt.wantAir {
+			t.Errorf("parseProgrammeDate(%q) = %q, %q, want %q, %q", tt.date, year, air, tt.wantYear, tt.wantAir)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+// mergeChannels folds the rows of the channels file sharing an output ID
+// into the first of them, whose name and options the output gets, instead of
+// writing the ID once per row. It returns the merged channels and the source
+// channel names of every ID in row order, read with mergedEvents. IDs without
+// any source events are missing from the returned names.
+func mergeChannels(channels []requestedChannel, sources *sourceChannels) ([]requestedChannel, map[string][]string) {
+	var merged []requestedChannel
+	names := make(map[string][]string)
+	seen := make(map[string]bool)
+	for _, c := range channels {
+		if !seen[c.ID] {
+			merged = append(merged, c)
+			seen[c.ID] = true
+		}
+		if sources.has(c.Name) {
+			names[c.ID] = append(names[c.ID], c.Name)
+		}
+	}
+	return merged, names
+}
+
+// mergedEvents returns the events of the source channels merged into an ID.
+// The events of the later rows are appended to those of the first, so with
+// the default overlap strategy the earlier rows win the overlaps, and events
+// listed by several sources with the same times and title are kept once.
+func mergedEvents(sources *sourceChannels, names []string) ([]programme, error) {
+	var events []programme
+	seen := make(map[string]bool)
+	for _, name := range names {
+		source, err := sources.events(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range source {
+			key := e.Start + "|" + e.Stop
+			if len(e.Title) > 0 {
+				key += "|" + e.Title[0].Name
+			}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
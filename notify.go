@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// runNotice is what the notifications tell about a finished run.
+type runNotice struct {
+	Job          string
+	Failed       bool
+	Error        string
+	AnomalyLevel anomalyLevel
+	Summary      *runSummary
+	Counts       map[string]int
+	TopEntries   []reportEntry
+}
+
+const defaultEmailTemplate = `{{if .Failed}}EPG run {{.Job}} failed: {{.Error}}{{else}}EPG run {{.Job}} finished with {{.AnomalyLevel}} anomalies{{end}}
+{{with .Summary}}
+Source files: {{.SourceFiles}}, source channels: {{.Channels}}
+{{range .Tenants}}{{if .Name}}Tenant {{.Name}}: {{end}}{{.Channels}} channels, {{.WrittenFiles}} files, {{.Events}} events, anomaly level {{.AnomalyLevel}}
+{{range .EmptyChannels}}  no events for channel {{.ID}} {{.Name}}{{if .Suggestions}}, did you mean {{join .Suggestions ", "}}?{{end}}
+{{end}}{{range .FailedChannels}}  channel {{.ID}} {{.Name}} failed: {{.Error}}
+{{end}}{{range .CoverageRegressions}}  channel {{.ID}} {{.Name}} covers {{.Coverage}} below {{.Minimum}}, previous output kept
+{{end}}{{range .LineupChanges}}  channel {{.ID}} {{.Name}} {{.Change}}
+{{end}}{{end}}{{end}}
+{{range $kind, $count := .Counts}}{{$kind}}: {{$count}}
+{{end}}{{if .TopEntries}}
+Top entries:
+{{range .TopEntries}}- {{.Kind}} {{.ChannelID}} {{.Channel}} {{.Start}} {{.Stop}} {{.Detail}}
+{{end}}{{end}}`
+
+// newRunNotice summarizes the outcome of a run of the job.
+func newRunNotice(j job, summary *runSummary, report *runReport, err error) runNotice {
+	n := runNotice{Job: j.Name, Summary: summary, Counts: report.counts(), TopEntries: report.top(10)}
+	if n.Job == "" {
+		n.Job = "default"
+	}
+	if err != nil {
+		n.Failed = true
+		n.Error = err.Error()
+	}
+	if summary != nil {
+		for _, t := range summary.Tenants {
+			var l anomalyLevel
+			if l.Set(t.AnomalyLevel) == nil && l > n.AnomalyLevel {
+				n.AnomalyLevel = l
+			}
+		}
+	}
+	return n
+}
+
+// notifyRun sends the configured notifications about a finished run.
+// Failing to notify is logged, it never fails the run.
+func notifyRun(n runNotice) {
+	if *smtpAddr != "" && (n.Failed || n.AnomalyLevel >= notifyAnomaly) {
+		if err := sendEmail(n); err != nil {
+			log.Printf("unable to send email notification due: %v", err)
+		}
+	}
+	if *slackWebhook != "" {
+		if err := postWebhook(*slackWebhook, slackMessage(n)); err != nil {
+			log.Printf("unable to send Slack notification due: %v", err)
+		}
+	}
+	if *teamsWebhook != "" {
+		if err := postWebhook(*teamsWebhook, teamsMessage(n)); err != nil {
+			log.Printf("unable to send Teams notification due: %v", err)
+		}
+	}
+}
+
+func sendEmail(n runNotice) error {
+	text := defaultEmailTemplate
+	if *smtpTemplate != "" {
+		data, err := ioutil.ReadFile(*smtpTemplate)
+		if err != nil {
+			return fmt.Errorf("unable to read email template due: %v", err)
+		}
+		text = string(data)
+	}
+	tmpl, err := template.New("email").Funcs(template.FuncMap{"join": strings.Join}).Parse(text)
+	if err != nil {
+		return fmt.Errorf("unable to parse email template due: %v", err)
+	}
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, n); err != nil {
+		return fmt.Errorf("unable to render email template due: %v", err)
+	}
+
+	to := splitList(*smtpTo)
+	if len(to) == 0 {
+		return fmt.Errorf("no recipients configured with -smtpTo")
+	}
+	subject := fmt.Sprintf("[epgtool] %s: %s anomalies", n.Job, n.AnomalyLevel)
+	if n.Failed {
+		subject = fmt.Sprintf("[epgtool] %s failed", n.Job)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", *smtpFrom)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(strings.Replace(body.String(), "\n", "\r\n", -1))
+
+	var auth smtp.Auth
+	if *smtpUser != "" {
+		host, _, err := net.SplitHostPort(*smtpAddr)
+		if err != nil {
+			return fmt.Errorf("invalid SMTP address due: %v", err)
+		}
+		auth = smtp.PlainAuth("", *smtpUser, os.Getenv("EPGTOOL_SMTP_PASSWORD"), host)
+	}
+	return smtp.SendMail(*smtpAddr, auth, *smtpFrom, to, msg.Bytes())
+}
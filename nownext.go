@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"time"
+)
+
+const nowNextFileName = "now_next.json"
+
+type nowNextEntry struct {
+	ChannelID string       `json:"channelId"`
+	Channel   string       `json:"channel"`
+	Now       *outputEvent `json:"now"`
+	Next      *outputEvent `json:"next"`
+}
+
+// nowNext returns for every channel the event running at the given time
+// and the one following it. The channel events are expected sorted.
+func nowNext(channels []*outputChannel, at time.Time) []nowNextEntry {
+	result := make([]nowNextEntry, 0, len(channels))
+	for _, c := range channels {
+		entry := nowNextEntry{ChannelID: c.ID, Channel: c.Name}
+		for i := range c.Events.Values {
+			e := &c.Events.Values[i]
+			start, end, err := eventTimes(*e)
+			if err != nil || !end.After(at) {
+				continue
+			}
+			if !start.After(at) && entry.Now == nil {
+				entry.Now = e
+				continue
+			}
+			entry.Next = e
+			break
+		}
+		result = append(result, entry)
+	}
+	return result
+}
+
+func writeNowNext(dir string, converted []convertedChannel, at time.Time) error {
+	channels := make([]*outputChannel, 0, len(converted))
+	for _, c := range converted {
+		channels = append(channels, c.output)
+	}
+	data, err := json.MarshalIndent(nowNext(channels, at), "", "  ")
+	if err != nil {
+		return err
+	}
+	err = writeOutputFile(filepath.Join(dir, nowNextFileName), func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("unable to write now/next file due: %v", err)
+	}
+	return nil
+}
+
+// handleNowNext serves GET /now-next with the current and next event of
+// every published channel, relative to the optional RFC 3339 at parameter.
+func (s *server) handleNowNext(w http.ResponseWriter, req *http.Request) {
+	at := time.Now()
+	if v := req.URL.Query().Get("at"); v != "" {
+		var err error
+		if at, err = time.Parse(time.RFC3339, v); err != nil {
+			http.Error(w, fmt.Sprintf("invalid at due: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	channels, err := loadPublishedChannels(*outputDir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, nowNext(channels, at))
+}
@@ -0,0 +1,39 @@
+package main
+
+import "log"
+
+// openFiles limits the files held open at once by the source readers and
+// the output writers of all jobs, nil leaves them unlimited.
+var openFiles chan struct{}
+
+// limitOpenFiles caps the open files at limit, or at half of the soft
+// RLIMIT_NOFILE when limit is 0, leaving the rest to the network and the
+// runtime. Readers and writers over the cap wait for a free slot instead of
+// failing with "too many open files".
+func limitOpenFiles(limit int) {
+	if limit == 0 {
+		limit = openFileLimit() / 2
+	}
+	if limit <= 0 {
+		return
+	}
+	if limit < 2 {
+		limit = 2
+	}
+	if *writeWorkers > limit {
+		log.Printf("-writeWorkers %d exceeds the open file limit %d, writers will wait for each other", *writeWorkers, limit)
+	}
+	openFiles = make(chan struct{}, limit)
+}
+
+func acquireFile() {
+	if openFiles != nil {
+		openFiles <- struct{}{}
+	}
+}
+
+func releaseFile() {
+	if openFiles != nil {
+		<-openFiles
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// channelOptions holds the conversion settings which can be overridden per
+// channel from the third column of the channels file, e.g
+//
+//	163,"HBO_HD","lang=en;overlap=last;repair=2m;window=168h;exclude=Teleshopping"
+type channelOptions struct {
+	// Lang is the preferred title language.
+	Lang string
+	// OverlapStrategy decides which of two overlapping events is kept: first
+	// keeps the event seen first, last keeps the one seen last.
+	OverlapStrategy string
+	// RepairThreshold is the largest gap or overlap between adjacent events
+	// that is repaired, 0 disables the repair.
+	RepairThreshold time.Duration
+	// Window limits the events to the ones overlapping [now, now+Window],
+	// 0 keeps all events.
+	Window time.Duration
+	// IncludeCategories, when not empty, keeps only the events in one of
+	// the categories, ExcludeCategories drops the events in any of them.
+	IncludeCategories []string
+	ExcludeCategories []string
+	// Packages are the channel bundles the channel belongs to.
+	Packages []string
+	// ActiveFrom and ActiveTo limit the period the channel is published in,
+	// a zero time leaves the period open.
+	ActiveFrom time.Time
+	ActiveTo   time.Time
+	// Parts is how programmes split into parts are handled: off, group or
+	// merge.
+	Parts string
+	// Sports extracts the metadata of sports events from their titles.
+	Sports bool
+	// TitleCase changes the case of the event names: off, title or sentence,
+	// keeping the case of the Acronyms.
+	TitleCase string
+	Acronyms  []string
+	// Translit transliterates the TranslitFields of the events to the latin
+	// or the cyrillic script, off leaves them as they are.
+	Translit       string
+	TranslitFields []string
+	// Perex is how the perex is derived from the description: full,
+	// sentence or a number of characters.
+	Perex string
+	// MinCoverage is the upcoming time the new events have to cover to
+	// replace a current output file covering it, 0 disables the check.
+	MinCoverage time.Duration
+	// Offset moves the source times of the channel, correcting a provider
+	// writing a wrong timezone offset.
+	Offset time.Duration
+	// CorrectShift moves the channel back by a shift detected against its
+	// previous output, see -timeShift.
+	CorrectShift bool
+	// Merge marks a row sharing the ID of an earlier row as merged into it
+	// on purpose, see mergeChannels.
+	Merge bool
+}
+
+func globalChannelOptions() channelOptions {
+	return channelOptions{
+		Lang:              *preferredLang,
+		OverlapStrategy:   *overlapStrategy,
+		RepairThreshold:   *repairThreshold,
+		Window:            *window,
+		IncludeCategories: splitList(*includeCats),
+		ExcludeCategories: splitList(*excludeCats),
+		Parts:             *parts,
+		Sports:            *sportsMetadata,
+		TitleCase:         *titleCase,
+		Acronyms:          splitList(*acronyms),
+		Translit:          *translit,
+		TranslitFields:    splitList(*translitFields),
+		Perex:             *perexMode,
+		MinCoverage:       minCoverage,
+	}
+}
+
+// parseChannelOptions applies the semicolon separated key=value overrides
+// in spec on top of base.
+func parseChannelOptions(base channelOptions, spec string) (channelOptions, error) {
+	opts := base
+	for _, kv := range strings.Split(spec, ";") {
+		kv = strings.TrimSpace(kv)
+		if kv == "" {
+			continue
+		}
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			return opts, fmt.Errorf("option '%s' is not in key=value form", kv)
+		}
+		key, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		var err error
+		switch key {
+		case "lang":
+			opts.Lang = value
+		case "overlap":
+			opts.OverlapStrategy = value
+		case "repair":
+			opts.RepairThreshold, err = time.ParseDuration(value)
+		case "window":
+			opts.Window, err = time.ParseDuration(value)
+		case "include":
+			opts.IncludeCategories = splitList(value)
+		case "exclude":
+			opts.ExcludeCategories = splitList(value)
+		case "packages":
+			opts.Packages = splitList(value)
+		case "parts":
+			opts.Parts = value
+		case "sports":
+			opts.Sports, err = strconv.ParseBool(value)
+		case "titleCase":
+			opts.TitleCase = value
+		case "acronyms":
+			opts.Acronyms = splitList(value)
+		case "translit":
+			opts.Translit = value
+		case "translitFields":
+			opts.TranslitFields = splitList(value)
+		case "perex":
+			opts.Perex = value
+		case "offset":
+			opts.Offset, err = time.ParseDuration(value)
+		case "correctShift":
+			opts.CorrectShift, err = strconv.ParseBool(value)
+		case "merge":
+			opts.Merge, err = strconv.ParseBool(value)
+		case "minCoverage":
+			opts.MinCoverage, err = parseCoverage(value)
+		case "active":
+			opts.ActiveFrom, opts.ActiveTo, err = parseActivePeriod(value)
+		default:
+			return opts, fmt.Errorf("unknown option '%s'", key)
+		}
+		if err != nil {
+			return opts, fmt.Errorf("invalid value for option '%s' due: %v", key, err)
+		}
+	}
+	return opts, opts.validate()
+}
+
+// acceptsCategories reports whether an event in the given categories passes
+// the include and exclude filters. Categories are compared case insensitively.
+func (o channelOptions) acceptsCategories(categories []title) bool {
+	contains := func(list []string, value string) bool {
+		for _, v := range list {
+			if strings.EqualFold(v, strings.TrimSpace(value)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	included := len(o.IncludeCategories) == 0
+	for _, c := range categories {
+		if contains(o.ExcludeCategories, c.Name) {
+			return false
+		}
+		if contains(o.IncludeCategories, c.Name) {
+			included = true
+		}
+	}
+	return included
+}
+
+// activeAt reports whether the channel is published at the given time.
+func (o channelOptions) activeAt(t time.Time) bool {
+	if !o.ActiveFrom.IsZero() && t.Before(o.ActiveFrom) {
+		return false
+	}
+	if !o.ActiveTo.IsZero() && !t.Before(o.ActiveTo) {
+		return false
+	}
+	return true
+}
+
+// parseActivePeriod parses "from/to" where both sides are optional dates
+// (2006-01-02) or RFC 3339 times, e.g "2024-06-01/2024-09-01" or "/2024-09-01".
+func parseActivePeriod(value string) (time.Time, time.Time, error) {
+	var from, to time.Time
+	parts := strings.SplitN(value, "/", 2)
+	if len(parts) != 2 {
+		return from, to, fmt.Errorf("expected from/to")
+	}
+	parse := func(v string) (time.Time, error) {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			return time.Time{}, nil
+		}
+		if t, err := time.ParseInLocation("2006-01-02", v, time.Local); err == nil {
+			return t, nil
+		}
+		return time.Parse(time.RFC3339, v)
+	}
+	from, err := parse(parts[0])
+	if err != nil {
+		return from, to, err
+	}
+	to, err = parse(parts[1])
+	return from, to, err
+}
+
+func (o channelOptions) validate() error {
+	switch o.OverlapStrategy {
+	case "first", "last":
+	default:
+		return fmt.Errorf("unsupported overlap strategy '%s'", o.OverlapStrategy)
+	}
+	switch o.Parts {
+	case "off", "group", "merge":
+	default:
+		return fmt.Errorf("unsupported parts mode '%s'", o.Parts)
+	}
+	switch o.TitleCase {
+	case "off", "title", "sentence":
+	default:
+		return fmt.Errorf("unsupported title case '%s'", o.TitleCase)
+	}
+	switch o.Translit {
+	case "off", "latin", "cyrillic":
+	default:
+		return fmt.Errorf("unsupported transliteration '%s'", o.Translit)
+	}
+	if err := validateTranslitFields(o.TranslitFields); err != nil {
+		return err
+	}
+	for _, p := range o.Packages {
+		if err := validatePathName("package", p); err != nil {
+			return err
+		}
+	}
+	// The previous output of a channel is not written with -delta, so the
+	// options comparing with it would silently do nothing.
+	if *deltaOutput && o.MinCoverage > 0 {
+		return fmt.Errorf("minCoverage reads the previous output file, which is not written with -delta")
+	}
+	if *deltaOutput && o.CorrectShift {
+		return fmt.Errorf("correctShift reads the previous output file, which is not written with -delta")
+	}
+	return validatePerexMode(o.Perex)
+}
+
+// splitList splits a comma separated list dropping the empty values.
+func splitList(s string) []string {
+	var result []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
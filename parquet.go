@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// parquetColumn is a required column of a Parquet file holding either UTF-8
+// strings or UTC timestamps in milliseconds.
+type parquetColumn struct {
+	name    string
+	strings []string
+	millis  []int64
+}
+
+func (c *parquetColumn) isTime() bool {
+	return c.strings == nil
+}
+
+// eventParquetColumns returns the columns of the events of the channel.
+func eventParquetColumns(c *outputChannel, events []outputEvent) []parquetColumn {
+	columns := []parquetColumn{
+		{name: "channel_id"}, {name: "channel_name"}, {name: "event_id"},
+		{name: "start", millis: []int64{}}, {name: "end", millis: []int64{}},
+		{name: "name"}, {name: "perex"}, {name: "description"}, {name: "actors"}, {name: "directors"},
+		{name: "production_year"}, {name: "production_countries"}, {name: "tags"}, {name: "provider"}, {name: "source"},
+	}
+	for _, e := range events {
+		start, end, _ := eventTimes(e)
+		var tags string
+		if e.Tags != nil {
+			tags = strings.Join(e.Tags.Values, ",")
+		}
+		values := []string{c.ID, c.Name, e.ID, "", "", e.Name, e.Perex, e.Description,
+			strings.Join(e.actorNames(), ", "), strings.Join(e.directorNames(), ", "),
+			e.ProductionYear, e.ProductionCountries, tags, e.Provider, e.SourceFile}
+		for i := range columns {
+			switch columns[i].name {
+			case "start":
+				columns[i].millis = append(columns[i].millis, start.UnixNano()/1e6)
+			case "end":
+				columns[i].millis = append(columns[i].millis, end.UnixNano()/1e6)
+			default:
+				columns[i].strings = append(columns[i].strings, values[i])
+			}
+		}
+	}
+	return columns
+}
+
+// writeParquetPartitions writes the events of the channels into the directory
+// partitioned by the UTC date of their start and the channel ID, e.g
+// date=2024-06-01/channel=163/events.parquet, replacing the partition files
+// of the given name. A non-zero runTime is added as the run_time column.
+func writeParquetPartitions(dir, name string, runTime time.Time, converted []convertedChannel) error {
+	for _, c := range converted {
+		byDate := make(map[string][]outputEvent)
+		for _, e := range c.output.Events.Values {
+			start, _, err := eventTimes(e)
+			if err != nil {
+				continue
+			}
+			date := start.UTC().Format("2006-01-02")
+			byDate[date] = append(byDate[date], e)
+		}
+		dates := make([]string, 0, len(byDate))
+		for date := range byDate {
+			dates = append(dates, date)
+		}
+		sort.Strings(dates)
+
+		for _, date := range dates {
+			partition := filepath.Join(dir, "date="+date, "channel="+c.output.ID)
+			if err := mkdirAll(partition); err != nil {
+				return fmt.Errorf("unable to create parquet directory due: %v", err)
+			}
+			events := byDate[date]
+			fileName := filepath.Join(partition, name)
+			err := writeOutputFile(fileName, func(w io.Writer) error {
+				columns := eventParquetColumns(c.output, events)
+				if !runTime.IsZero() {
+					run := parquetColumn{name: "run_time", millis: make([]int64, len(events))}
+					for i := range run.millis {
+						run.millis[i] = runTime.UnixNano() / 1e6
+					}
+					columns = append(columns, run)
+				}
+				return writeParquet(w, columns, len(events))
+			})
+			if err != nil {
+				return fmt.Errorf("could not write to parquet file '%s' due: %v", fileName, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Parquet format constants, see parquet.thrift.
+const (
+	parquetInt64     = 2
+	parquetByteArray = 6
+	parquetRequired  = 0
+	parquetUTF8      = 0
+	parquetTimestamp = 9 // TIMESTAMP_MILLIS
+	parquetPlain     = 0
+	parquetRLE       = 3
+	parquetDataPage  = 0
+)
+
+// writeParquet writes the columns as a Parquet file with a single row group
+// and a single uncompressed, PLAIN encoded data page per column.
+func writeParquet(w io.Writer, columns []parquetColumn, rows int) error {
+	var out bytes.Buffer
+	out.WriteString("PAR1")
+
+	offsets := make([]int64, len(columns))
+	sizes := make([]int64, len(columns))
+	for i, c := range columns {
+		var data bytes.Buffer
+		if c.isTime() {
+			for _, v := range c.millis {
+				binary.Write(&data, binary.LittleEndian, v)
+			}
+		} else {
+			for _, v := range c.strings {
+				binary.Write(&data, binary.LittleEndian, uint32(len(v)))
+				data.WriteString(v)
+			}
+		}
+
+		var header thriftWriter
+		header.begin()
+		header.i32(1, parquetDataPage)
+		header.i32(2, int32(data.Len()))
+		header.i32(3, int32(data.Len()))
+		header.structField(5)
+		header.i32(1, int32(rows))
+		header.i32(2, parquetPlain)
+		header.i32(3, parquetRLE)
+		header.i32(4, parquetRLE)
+		header.end()
+		header.end()
+
+		offsets[i] = int64(out.Len())
+		sizes[i] = int64(header.buf.Len() + data.Len())
+		out.Write(header.buf.Bytes())
+		out.Write(data.Bytes())
+	}
+
+	var meta thriftWriter
+	meta.begin()
+	meta.i32(1, 1)
+	meta.listField(2, thriftStruct, len(columns)+1)
+	meta.begin()
+	meta.binary(4, "schema")
+	meta.i32(5, int32(len(columns)))
+	meta.end()
+	for _, c := range columns {
+		meta.begin()
+		if c.isTime() {
+			meta.i32(1, parquetInt64)
+		} else {
+			meta.i32(1, parquetByteArray)
+		}
+		meta.i32(3, parquetRequired)
+		meta.binary(4, c.name)
+		if c.isTime() {
+			meta.i32(6, parquetTimestamp)
+		} else {
+			meta.i32(6, parquetUTF8)
+		}
+		meta.end()
+	}
+	meta.i64(3, int64(rows))
+
+	var total int64
+	meta.listField(4, thriftStruct, 1)
+	meta.begin()
+	meta.listField(1, thriftStruct, len(columns))
+	for i, c := range columns {
+		meta.begin()
+		meta.i64(2, offsets[i])
+		meta.structField(3)
+		if c.isTime() {
+			meta.i32(1, parquetInt64)
+		} else {
+			meta.i32(1, parquetByteArray)
+		}
+		meta.listField(2, thriftI32, 1)
+		meta.varint(zigzag(parquetPlain))
+		meta.listField(3, thriftBinary, 1)
+		meta.varint(uint64(len(c.name)))
+		meta.buf.WriteString(c.name)
+		meta.i32(4, 0) // UNCOMPRESSED
+		meta.i64(5, int64(rows))
+		meta.i64(6, sizes[i])
+		meta.i64(7, sizes[i])
+		meta.i64(9, offsets[i])
+		meta.end()
+		meta.end()
+		total += sizes[i]
+	}
+	meta.i64(2, total)
+	meta.i64(3, int64(rows))
+	meta.end()
+	meta.binary(6, "epgtool version "+version)
+	meta.end()
+
+	out.Write(meta.buf.Bytes())
+	binary.Write(&out, binary.LittleEndian, uint32(meta.buf.Len()))
+	out.WriteString("PAR1")
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// Thrift compact protocol types.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes structs in the Thrift compact protocol, which the
+// Parquet page headers and file metadata are written in.
+type thriftWriter struct {
+	buf  bytes.Buffer
+	last []int16
+}
+
+func zigzag(v int64) uint64 {
+	return uint64((v << 1) ^ (v >> 63))
+}
+
+func (t *thriftWriter) varint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	t.buf.Write(b[:binary.PutUvarint(b[:], v)])
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	last := &t.last[len(t.last)-1]
+	if d := id - *last; d > 0 && d <= 15 {
+		t.buf.WriteByte(byte(d)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.varint(zigzag(int64(id)))
+	}
+	*last = id
+}
+
+// begin starts a struct, either the top level one or an element of a list.
+func (t *thriftWriter) begin() {
+	t.last = append(t.last, 0)
+}
+
+func (t *thriftWriter) structField(id int16) {
+	t.field(id, thriftStruct)
+	t.begin()
+}
+
+func (t *thriftWriter) end() {
+	t.buf.WriteByte(0)
+	t.last = t.last[:len(t.last)-1]
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.varint(zigzag(int64(v)))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(zigzag(v))
+}
+
+func (t *thriftWriter) binary(id int16, v string) {
+	t.field(id, thriftBinary)
+	t.varint(uint64(len(v)))
+	t.buf.WriteString(v)
+}
+
+// listField starts a list of n elements, which are written directly after.
+func (t *thriftWriter) listField(id int16, elem byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.buf.WriteByte(byte(n)<<4 | elem)
+	} else {
+		t.buf.WriteByte(0xf0 | elem)
+		t.varint(uint64(n))
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestZigzag(t *testing.T) {
+	tests := []struct {
+		v    int64
+		want uint64
+	}{
+		{0, 0}, {-1, 1}, {1, 2}, {-2, 3}, {2, 4}, {1 << 40, 1 << 41},
+	}
+	for _, tt := range tests {
+		if got := zigzag(tt.v); got != tt.want {
+			t.Errorf("zigzag(%d) = %d, want %d", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestThriftWriter(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(w *thriftWriter)
+		want  []byte
+	}{
+		{"i32", func(w *thriftWriter) { w.i32(1, 1) }, []byte{0x15, 0x02, 0}},
+		{"negative i32", func(w *thriftWriter) { w.i32(1, -1) }, []byte{0x15, 0x01, 0}},
+		{"field delta", func(w *thriftWriter) { w.i32(1, 0); w.i64(3, 1) }, []byte{0x15, 0x00, 0x26, 0x02, 0}},
+		{"long field delta", func(w *thriftWriter) { w.i32(20, 0) }, []byte{0x05, 0x28, 0x00, 0}},
+		{"binary", func(w *thriftWriter) { w.binary(4, "ab") }, []byte{0x48, 0x02, 'a', 'b', 0}},
+		{"short list", func(w *thriftWriter) { w.listField(2, thriftI32, 3) }, []byte{0x29, 0x35, 0}},
+		{"long list", func(w *thriftWriter) { w.listField(2, thriftI32, 20) }, []byte{0x29, 0xf5, 0x14, 0}},
+		{"nested struct", func(w *thriftWriter) {
+			w.i32(1, 0)
+			w.structField(5)
+			w.i32(1, 1)
+			w.end()
+			w.i32(6, 0)
+		}, []byte{0x15, 0x00, 0x4c, 0x15, 0x02, 0x00, 0x15, 0x00, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var w thriftWriter
+			w.begin()
+			tt.write(&w)
+			w.end()
+			if got := w.buf.Bytes(); !bytes.Equal(got, tt.want) {
+				t.Errorf("got % x, want % x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventParquetColumns(t *testing.T) {
+	c := &outputChannel{ID: "1", Name: "One"}
+	events := []outputEvent{
+		{ID: "11", Name: "News", StartTime: "2024-01-01T18:00:00Z", EndTime: "2024-01-01T18:30:00Z",
+			Actors: "A, B", Tags: &outputTags{Values: []string{"live", "hd"}}},
+		{ID: "12", Name: "Film", StartTime: "2024-01-01T18:30:00Z", EndTime: "2024-01-01T20:00:00Z", Directors: "C"},
+	}
+	columns := eventParquetColumns(c, events)
+	byName := make(map[string]parquetColumn)
+	for _, col := range columns {
+		byName[col.name] = col
+	}
+	start := time.Date(2024, 1, 1, 18, 0, 0, 0, time.UTC).UnixNano() / 1e6
+	tests := []struct {
+		column  string
+		strings []string
+		millis  []int64
+	}{
+		{"channel_id", []string{"1", "1"}, nil},
+		{"event_id", []string{"11", "12"}, nil},
+		{"start", nil, []int64{start, start + 30*60*1000}},
+		{"end", nil, []int64{start + 30*60*1000, start + 120*60*1000}},
+		{"actors", []string{"A, B", ""}, nil},
+		{"directors", []string{"", "C"}, nil},
+		{"tags", []string{"live,hd", ""}, nil},
+	}
+	for _, tt := range tests {
+		col := byName[tt.column]
+		if col.isTime() != (tt.millis != nil) || !reflect.DeepEqual(col.strings, tt.strings) || !reflect.DeepEqual(col.millis, tt.millis) {
+			t.Errorf("got column %s %+v, want %q %v", tt.column, col, tt.strings, tt.millis)
+		}
+	}
+}
+
+func TestWriteParquet(t *testing.T) {
+	columns := []parquetColumn{
+		{name: "name", strings: []string{"News", "Film"}},
+		{name: "start", millis: []int64{1, 2}},
+	}
+	var buf bytes.Buffer
+	if err := writeParquet(&buf, columns, 2); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if !bytes.HasPrefix(data, []byte("PAR1")) || !bytes.HasSuffix(data, []byte("PAR1")) {
+		t.Fatalf("missing magic in % x", data)
+	}
+	footer := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	if footer <= 0 || footer > len(data)-12 {
+		t.Fatalf("invalid footer length %d of %d bytes", footer, len(data))
+	}
+	meta := data[len(data)-8-footer : len(data)-8]
+	for _, want := range []string{"schema", "name", "start", "epgtool version"} {
+		if !bytes.Contains(meta, []byte(want)) {
+			t.Errorf("footer misses %q", want)
+		}
+	}
+
+	var plain bytes.Buffer
+	for _, v := range columns[0].strings {
+		binary.Write(&plain, binary.LittleEndian, uint32(len(v)))
+		plain.WriteString(v)
+	}
+	for _, v := range columns[1].millis {
+		binary.Write(&plain, binary.LittleEndian, v)
+	}
+	pages := data[4 : len(data)-8-footer]
+	first := bytes.Index(pages, plain.Bytes()[:plain.Len()-16])
+	second := bytes.Index(pages, plain.Bytes()[plain.Len()-16:])
+	if first < 0 || second < first {
+		t.Errorf("pages % x miss the PLAIN encoded values", pages)
+	}
+}
+
+// partitionFiles returns the files below dir relative to it.
+func partitionFiles(t *testing.T, dir string) []string {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		files = append(files, filepath.ToSlash(rel))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestWriteParquetPartitions(t *testing.T) {
+	converted := []convertedChannel{{output: &outputChannel{ID: "1", Name: "One", Events: outputEvents{Values: []outputEvent{
+		{ID: "11", Name: "Late", StartTime: "2024-01-01T23:30:00Z", EndTime: "2024-01-02T00:30:00Z"},
+		{ID: "12", Name: "Early", StartTime: "2024-01-02T06:00:00Z", EndTime: "2024-01-02T07:00:00Z"},
+		{ID: "13", Name: "Broken", StartTime: "tomorrow"},
+	}}}}}
+	tests := []struct {
+		name    string
+		file    string
+		runTime time.Time
+	}{
+		{"export", "events.parquet", time.Time{}},
+		{"archive", "run-20240102T080000Z.parquet", time.Date(2024, 1, 2, 8, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := writeParquetPartitions(dir, tt.file, tt.runTime, converted); err != nil {
+				t.Fatal(err)
+			}
+			want := []string{"date=2024-01-01/channel=1/" + tt.file, "date=2024-01-02/channel=1/" + tt.file}
+			files := partitionFiles(t, dir)
+			if !reflect.DeepEqual(files, want) {
+				t.Fatalf("got files %q, want %q", files, want)
+			}
+			data, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(files[0])))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hasRunTime := bytes.Contains(data, []byte("run_time")); hasRunTime != !tt.runTime.IsZero() {
+				t.Errorf("got run_time column %v", hasRunTime)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// partMarker matches a trailing part marker of a title, e.g "Part 2",
+// "(2/3)", "част 1", "II част" or "Teil 2".
+var partMarker = regexp.MustCompile(`(?i)[\s,:.\-–]*\(?(?:(?:part|pt\.?|част|teil|partie)\s*(?:\d+|[ivx]+)(?:\s*/\s*\d+)?|(?:\d+|[ivx]+)\.?\s*(?:част|part)|\d+\s*/\s*\d+)\)?\s*$`)
+
+// splitPart returns the title without its part marker and whether it had
+// one.
+func splitPart(name string) (string, bool) {
+	loc := partMarker.FindStringIndex(name)
+	if loc == nil || loc[0] == 0 {
+		return name, false
+	}
+	return strings.TrimSpace(name[:loc[0]]), true
+}
+
+// linkParts finds the programmes split into parts among the events: events
+// with a part marker whose titles are equal without it, each part starting
+// no later than gap after the end of the previous one. With "group" every
+// part gets the ID of the first one as group ID, with "merge" the first
+// part is stretched over the following parts and the breaks between them,
+// which are dropped. The events are sorted by start.
+func linkParts(channel requestedChannel, events []scheduledEvent, mode string, gap time.Duration, report *runReport) []scheduledEvent {
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Start.Before(events[j].Start) })
+	dropped := make([]bool, len(events))
+	for i := range events {
+		if dropped[i] || events[i].GroupID != "" {
+			continue
+		}
+		base, ok := splitPart(events[i].Title.Name)
+		if !ok {
+			continue
+		}
+		parts := []int{i}
+		for j := i + 1; j < len(events); j++ {
+			if events[j].Start.Sub(events[parts[len(parts)-1]].End) > gap {
+				break
+			}
+			if b, ok := splitPart(events[j].Title.Name); ok && strings.EqualFold(b, base) {
+				parts = append(parts, j)
+			}
+		}
+		if len(parts) < 2 {
+			continue
+		}
+
+		last := parts[len(parts)-1]
+		if mode == "merge" {
+			end := events[last].End
+			report.adjusted("merged parts", channel, events[i].programme, events[i].Start, end)
+			for j := i + 1; j <= last; j++ {
+				report.adjusted("merged parts", channel, events[j].programme, events[i].Start, end)
+				dropped[j] = true
+			}
+			events[i].End = end
+			events[i].Title.Name = base
+			continue
+		}
+		for _, j := range parts {
+			events[j].GroupID = events[i].ID
+		}
+	}
+
+	result := events[:0]
+	for i, e := range events {
+		if !dropped[i] {
+			result = append(result, e)
+		}
+	}
+	return result
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// parenthesized matches role annotations such as "(гл. роля)" or "(voice)".
+var parenthesized = regexp.MustCompile(`\s*\([^()]*\)`)
+
+// normalizedPeople trims the names of actors or directors, strips their
+// parenthesized roles and drops empty and repeated names, compared case
+// insensitively. With -normalizePeople=false the names are only trimmed.
+// The list is capped at -maxPeople names.
+func normalizedPeople(names []string) []string {
+	var result []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if *normalizePeople {
+			name = strings.Join(strings.Fields(parenthesized.ReplaceAllString(name, "")), " ")
+			key := strings.ToLower(name)
+			if name == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+		} else {
+			name = strings.TrimSpace(name)
+		}
+		result = append(result, name)
+	}
+	if *maxPeople > 0 && len(result) > *maxPeople {
+		result = result[:*maxPeople]
+	}
+	return result
+}
+
+// actorNames returns the actors of the event in either -peopleFormat.
+func (e outputEvent) actorNames() []string {
+	if len(e.ActorList) > 0 {
+		return e.ActorList
+	}
+	return splitList(e.Actors)
+}
+
+// directorNames returns the directors of the event in either -peopleFormat.
+func (e outputEvent) directorNames() []string {
+	if len(e.DirectorList) > 0 {
+		return e.DirectorList
+	}
+	return splitList(e.Directors)
+}
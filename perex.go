@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// shortPerex derives the perex from the description: full keeps the whole
+// description, sentence its first sentence and a number the leading words
+// fitting into that many characters followed by an ellipsis.
+func shortPerex(description string, mode string) string {
+	switch mode {
+	case "full":
+		return description
+	case "sentence":
+		runes := []rune(description)
+		for i, r := range runes {
+			if (r == '.' || r == '!' || r == '?') && i+1 < len(runes) && unicode.IsSpace(runes[i+1]) {
+				return string(runes[:i+1])
+			}
+		}
+		return description
+	}
+
+	limit, _ := strconv.Atoi(mode)
+	runes := []rune(description)
+	if limit <= 0 || len(runes) <= limit {
+		return description
+	}
+	cut := string(runes[:limit])
+	if !unicode.IsSpace(runes[limit]) {
+		if i := strings.LastIndexFunc(cut, unicode.IsSpace); i > 0 {
+			cut = cut[:i]
+		}
+	}
+	return strings.TrimRightFunc(cut, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsPunct(r) }) + "…"
+}
+
+func validatePerexMode(mode string) error {
+	if mode == "full" || mode == "sentence" {
+		return nil
+	}
+	if n, err := strconv.Atoi(mode); err != nil || n <= 0 {
+		return fmt.Errorf("unsupported perex '%s', expected full, sentence or a number of characters", mode)
+	}
+	return nil
+}
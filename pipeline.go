@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+)
+
+// normalizedSources is the output of the parse command: the programmes of
+// the source files grouped by source channel, in the order they were read.
+// Its JSON form is described by schema/programmes.v1.json.
+type normalizedSources struct {
+	Kind        string                `json:"kind"`
+	Version     int                   `json:"version"`
+	SourceFiles []string              `json:"sourceFiles"`
+	Programmes  []normalizedProgramme `json:"programmes"`
+}
+
+type normalizedProgramme struct {
+	programme
+	SourceFile string `json:"sourceFile,omitempty"`
+	Provider   string `json:"provider,omitempty"`
+}
+
+// convertedTenants is the output of the transform command: the converted
+// channels of every tenant. Its JSON form is described by
+// schema/channels.v1.json.
+type convertedTenants struct {
+	Kind           string                    `json:"kind"`
+	Version        int                       `json:"version"`
+	SourceFiles    []string                  `json:"sourceFiles"`
+	SourceChannels int                       `json:"sourceChannels"`
+	Tenants        []convertedTenantChannels `json:"tenants"`
+}
+
+type convertedTenantChannels struct {
+	Name           string              `json:"name,omitempty"`
+	Channels       int                 `json:"channels"`
+	EmptyChannels  []emptyChannel      `json:"emptyChannels,omitempty"`
+	FailedChannels []failedChannel     `json:"failedChannels,omitempty"`
+	Converted      []normalizedChannel `json:"converted"`
+}
+
+type normalizedChannel struct {
+	ID      string        `json:"id"`
+	Name    string        `json:"name"`
+	Options string        `json:"options,omitempty"`
+	Events  []outputEvent `json:"events"`
+}
+
+// pipelineVersion is the version of the pipeline JSON files written. It is
+// raised only by incompatible changes, fields added within a version are
+// optional and unknown fields are ignored by the readers.
+const pipelineVersion = 1
+
+const (
+	programmesKind = "programmes"
+	channelsKind   = "channels"
+)
+
+// parseCommand reads the source files of the job and writes their
+// programmes as JSON, the first step of the parse, transform and publish
+// pipeline:
+//
+//	epgtool -dataDir data parse > norm.json
+//	epgtool -channelsFile channels.csv transform -input norm.json > converted.json
+//	epgtool -outputDir out publish -input converted.json
+func parseCommand(args []string, cache *sourceCache) {
+	fs := flag.NewFlagSet("parse", flag.ExitOnError)
+	output := fs.String("output", "-", "file the programmes are written to, - for stdout")
+	fs.Parse(args)
+	out := pipelineStdout()
+
+	files, sources, err := defaultJob().channelEvents(context.Background(), cache)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer sources.close()
+	result := normalizedSources{Kind: programmesKind, Version: pipelineVersion, SourceFiles: files, Programmes: []normalizedProgramme{}}
+	for _, name := range sources.names() {
+		events, err := sources.events(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, e := range events {
+			result.Programmes = append(result.Programmes, normalizedProgramme{programme: e, SourceFile: e.SourceFile,
+				Provider: e.Provider})
+		}
+	}
+	writePipelineFile(out, *output, result)
+}
+
+// transformCommand converts the programmes written by the parse command for
+// every tenant and writes the converted channels as JSON.
+func transformCommand(args []string) {
+	fs := flag.NewFlagSet("transform", flag.ExitOnError)
+	input := fs.String("input", "-", "file written by the parse command, - for stdin")
+	output := fs.String("output", "-", "file the converted channels are written to, - for stdout")
+	fs.Parse(args)
+	out := pipelineStdout()
+
+	var sources normalizedSources
+	readPipelineFile(*input, programmesKind, &sources)
+	channelEvents := newSourceChannels(*spillEvents)
+	defer channelEvents.close()
+	for _, p := range sources.Programmes {
+		e := p.programme
+		e.SourceFile, e.Provider = p.SourceFile, p.Provider
+		if err := channelEvents.add(e); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	j := defaultJob()
+	opts := j.runOptions()
+	opts.out = os.Stderr
+	report := newRunReport(os.Stderr)
+	result := convertedTenants{Kind: channelsKind, Version: pipelineVersion, SourceFiles: sources.SourceFiles,
+		SourceChannels: channelEvents.len()}
+	for _, t := range j.tenantList() {
+		ts, err := convertTenant(context.Background(), t, j.OutputDir, channelEvents, report, opts)
+		if err != nil {
+			log.Fatal(err)
+		}
+		tc := convertedTenantChannels{Name: ts.Name, Channels: ts.Channels, EmptyChannels: ts.EmptyChannels,
+			FailedChannels: ts.FailedChannels, Converted: []normalizedChannel{}}
+		for _, c := range ts.converted {
+			tc.Converted = append(tc.Converted, normalizedChannel{ID: c.channel.ID, Name: c.channel.Name,
+				Options: c.channel.OptionSpec, Events: c.output.Events.Values})
+		}
+		result.Tenants = append(result.Tenants, tc)
+	}
+	report.flush()
+	writePipelineFile(out, *output, result)
+}
+
+// publishCommand writes the channels converted by the transform command into
+// the output directory like a run of the job.
+func publishCommand(args []string) {
+	fs := flag.NewFlagSet("publish", flag.ExitOnError)
+	input := fs.String("input", "-", "file written by the transform command, - for stdin")
+	fs.Parse(args)
+
+	var in convertedTenants
+	readPipelineFile(*input, channelsKind, &in)
+	j := defaultJob()
+	if err := j.validate(); err != nil {
+		log.Fatal(err)
+	}
+	opts := j.runOptions()
+	j.options = opts
+	report := newRunReport(opts.out)
+	summary := &runSummary{SourceFiles: len(in.SourceFiles), Channels: in.SourceChannels}
+	summary, err := func() (*runSummary, error) {
+		var staged []*stagedTenant
+		for _, tc := range in.Tenants {
+			t := tenant{Name: tc.Name}
+			ts := &tenantSummary{Name: tc.Name, Channels: tc.Channels, EmptyChannels: tc.EmptyChannels,
+				FailedChannels: tc.FailedChannels}
+			for _, c := range tc.Converted {
+				options, err := parseChannelOptions(globalChannelOptions(), c.Options)
+				if err != nil {
+					return nil, fmt.Errorf("channel %s due: %v", c.ID, err)
+				}
+				output := &outputChannel{ID: c.ID, Name: c.Name, Events: outputEvents{Values: c.Events}}
+				if opts.statsOutput {
+					output.Stats = newChannelStats(output, time.Now())
+				}
+				ts.converted = append(ts.converted, convertedChannel{
+					channel: requestedChannel{ID: c.ID, Name: c.Name, Options: options, OptionSpec: c.Options},
+					output:  output,
+				})
+			}
+			s, err := stageTenant(t, j.OutputDir, in.SourceFiles, ts, report, opts)
+			if err != nil {
+				return nil, err
+			}
+			staged = append(staged, s)
+		}
+		var err error
+		if summary.Tenants, err = writeTenants(staged, nil, opts); err != nil {
+			return nil, err
+		}
+		return j.complete(summary, in.SourceFiles)
+	}()
+	report.flush()
+	notifyRun(newRunNotice(j, summary, report, err))
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// pipelineStdout returns the standard output for the JSON written by the
+// pipeline commands and sends the progress printed while converting to
+// stderr instead.
+func pipelineStdout() io.Writer {
+	out := os.Stdout
+	os.Stdout = os.Stderr
+	return out
+}
+
+func readPipelineFile(fileName, kind string, v interface{}) {
+	var data []byte
+	var err error
+	if fileName == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(fileName)
+	}
+	if err != nil {
+		log.Fatalf("unable to read input file due: %v", err)
+	}
+	if err := checkPipelineHeader(data, kind); err != nil {
+		log.Fatal(err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		log.Fatalf("unable to parse input file due: %v", err)
+	}
+}
+
+// checkPipelineHeader checks the kind and version of a pipeline JSON file.
+// Files without a kind predate it and are accepted by their version.
+func checkPipelineHeader(data []byte, kind string) error {
+	var header struct {
+		Kind    string `json:"kind"`
+		Version int    `json:"version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return fmt.Errorf("unable to parse input file due: %v", err)
+	}
+	if header.Kind != "" && header.Kind != kind {
+		return fmt.Errorf("input file holds %s, expected %s", header.Kind, kind)
+	}
+	switch {
+	case header.Version == 0:
+		return fmt.Errorf("input file has no version, expected a %s file of version %d", kind, pipelineVersion)
+	case header.Version > pipelineVersion:
+		return fmt.Errorf("input file has version %d, this epgtool reads up to version %d", header.Version,
+			pipelineVersion)
+	}
+	return nil
+}
+
+func writePipelineFile(stdout io.Writer, fileName string, v interface{}) {
+	write := func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	var err error
+	if fileName == "-" {
+		err = write(stdout)
+	} else {
+		err = writeFileAtomic(fileName, write)
+	}
+	if err != nil {
+		log.Fatalf("could not write to output file '%s' due: %v", fileName, err)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var tvgIDAttr = regexp.MustCompile(`tvg-id="([^"]*)"`)
+
+type playlistEntry struct {
+	TvgID string
+	Name  string
+	Line  int
+}
+
+// readPlaylist reads the #EXTINF entries of an M3U playlist.
+func readPlaylist(fileName string) ([]playlistEntry, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open playlist due: %v", err)
+	}
+	defer f.Close()
+
+	var entries []playlistEntry
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(text, "#EXTINF") {
+			continue
+		}
+		e := playlistEntry{Line: line}
+		if m := tvgIDAttr.FindStringSubmatch(text); m != nil {
+			e.TvgID = strings.TrimSpace(m[1])
+		}
+		if i := strings.LastIndex(text, ","); i >= 0 {
+			e.Name = strings.TrimSpace(text[i+1:])
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read playlist due: %v", err)
+	}
+	return entries, nil
+}
+
+// playlistCommand cross-checks an M3U playlist against the published
+// channels, exiting with status 1 when they drifted apart:
+//
+//	epgtool -outputDir out channels playlist -playlist channels.m3u
+func playlistCommand(args []string) {
+	fs := flag.NewFlagSet("channels playlist", flag.ExitOnError)
+	playlistFile := fs.String("playlist", "playlist.m3u", "M3U playlist whose tvg-id attributes are matched against the output channel IDs and names")
+	fs.Parse(args)
+
+	entries, err := readPlaylist(*playlistFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	channels, err := loadPublishedChannels(*outputDir)
+	if err != nil {
+		log.Fatalf("unable to read output channels due: %v", err)
+	}
+
+	problems := checkPlaylist(*playlistFile, entries, channels)
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	if len(problems) > 0 {
+		fmt.Printf("%d problems found in %s\n", len(problems), *playlistFile)
+		os.Exit(1)
+	}
+	fmt.Printf("%s: %d channels OK\n", *playlistFile, len(entries))
+}
+
+// checkPlaylist reports the playlist entries without EPG and the channels
+// with no playlist entry. A tvg-id matches either the channel ID or name.
+func checkPlaylist(fileName string, entries []playlistEntry, channels []*outputChannel) []string {
+	var problems []string
+	published := make(map[string]*outputChannel)
+	for _, c := range channels {
+		published[c.ID] = c
+		published[c.Name] = c
+	}
+
+	listed := make(map[*outputChannel]bool)
+	for _, e := range entries {
+		if e.TvgID == "" {
+			problems = append(problems, fmt.Sprintf("%s:%d: playlist channel '%s' has no tvg-id", fileName, e.Line, e.Name))
+			continue
+		}
+		c, ok := published[e.TvgID]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s:%d: playlist channel '%s' (tvg-id '%s') has no EPG in the output", fileName, e.Line, e.Name, e.TvgID))
+			continue
+		}
+		listed[c] = true
+	}
+	for _, c := range channels {
+		if !listed[c] {
+			problems = append(problems, fmt.Sprintf("EPG channel '%s' (%s) has no playlist entry", c.ID, c.Name))
+		}
+	}
+	return problems
+}
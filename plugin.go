@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"plugin"
+)
+
+// pipelinePlugin holds the transforms of a Go plugin loaded with -plugin,
+// built with go build -buildmode=plugin and exporting either or both of
+//
+//	func TransformProgramme(p map[string]interface{}) (map[string]interface{}, bool)
+//	func TransformEvent(channelID string, e map[string]interface{}) (map[string]interface{}, bool)
+//
+// They receive the JSON form of every parsed programme and of every output
+// event and return it changed, or false to drop it. The JSON form keeps the
+// plugin independent of the types of this package.
+type pipelinePlugin struct {
+	programme func(map[string]interface{}) (map[string]interface{}, bool)
+	event     func(string, map[string]interface{}) (map[string]interface{}, bool)
+}
+
+// loadedPlugin is the plugin loaded with -plugin, nil without one.
+var loadedPlugin *pipelinePlugin
+
+func loadPlugin(path string) (*pipelinePlugin, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load plugin due: %v", err)
+	}
+	result := &pipelinePlugin{}
+	if sym, err := p.Lookup("TransformProgramme"); err == nil {
+		f, ok := sym.(func(map[string]interface{}) (map[string]interface{}, bool))
+		if !ok {
+			return nil, fmt.Errorf("plugin '%s': TransformProgramme has type %T", path, sym)
+		}
+		result.programme = f
+	}
+	if sym, err := p.Lookup("TransformEvent"); err == nil {
+		f, ok := sym.(func(string, map[string]interface{}) (map[string]interface{}, bool))
+		if !ok {
+			return nil, fmt.Errorf("plugin '%s': TransformEvent has type %T", path, sym)
+		}
+		result.event = f
+	}
+	if result.programme == nil && result.event == nil {
+		return nil, fmt.Errorf("plugin '%s' exports neither TransformProgramme nor TransformEvent", path)
+	}
+	return result, nil
+}
+
+// transformProgramme passes the programme through the plugin, reporting
+// false when it is dropped. The fields without a JSON form are kept.
+func (p *pipelinePlugin) transformProgramme(e programme) (programme, bool, error) {
+	if p == nil || p.programme == nil {
+		return e, true, nil
+	}
+	out := programme{Unknown: e.Unknown, UnknownAttrs: e.UnknownAttrs, SourceFile: e.SourceFile, Provider: e.Provider}
+	ok, err := transformJSON(e, &out, func(m map[string]interface{}) (map[string]interface{}, bool) {
+		if auditLog == nil {
+			return p.programme(m)
+		}
+		before := copyFields(m)
+		m, keep := p.programme(m)
+		if !keep {
+			m = nil
+		}
+		auditLog.change("plugin "+*pluginFile, "TransformProgramme", e.ChannelName, before, m)
+		return m, keep
+	})
+	return out, ok, err
+}
+
+// transformEvent passes the output event through the plugin, reporting
+// false when it is dropped.
+func (p *pipelinePlugin) transformEvent(channelID string, e outputEvent) (outputEvent, bool, error) {
+	if p == nil || p.event == nil {
+		return e, true, nil
+	}
+	var out outputEvent
+	ok, err := transformJSON(e, &out, func(m map[string]interface{}) (map[string]interface{}, bool) {
+		if auditLog == nil {
+			return p.event(channelID, m)
+		}
+		before := copyFields(m)
+		m, keep := p.event(channelID, m)
+		if !keep {
+			m = nil
+		}
+		auditLog.change("plugin "+*pluginFile, "TransformEvent", channelID, before, m)
+		return m, keep
+	})
+	return out, ok, err
+}
+
+func transformJSON(in interface{}, out interface{}, transform func(map[string]interface{}) (map[string]interface{}, bool)) (bool, error) {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return false, err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return false, err
+	}
+	m, keep := transform(m)
+	if !keep {
+		return false, nil
+	}
+	if data, err = json.Marshal(m); err != nil {
+		return false, fmt.Errorf("plugin returned an invalid value due: %v", err)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return false, fmt.Errorf("plugin returned an invalid value due: %v", err)
+	}
+	return true, nil
+}
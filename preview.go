@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// previewChanges prints what publishing the converted channels would change
+// compared to the files currently in dir: added channels and the channels
+// of the previous manifest removed, and the added, changed and removed events
+// of every other channel.
+func previewChanges(w io.Writer, t tenant, dir string, converted []convertedChannel) {
+	if t.Name != "" {
+		fmt.Fprintf(w, "Tenant %s:\n", t.Name)
+	}
+
+	written := make(map[string]bool)
+	unchanged := 0
+	for _, c := range converted {
+		written[c.channel.ID] = true
+		prev, err := readPreviousOutput(t, dir, c.channel)
+		if os.IsNotExist(err) {
+			fmt.Fprintf(w, "channel added: %s %s (%d events)\n", c.channel.ID, c.channel.Name, len(c.output.Events.Values))
+			continue
+		}
+		if err != nil {
+			fmt.Fprintf(w, "channel %s %s: %v\n", c.channel.ID, c.channel.Name, err)
+			continue
+		}
+
+		output, err := publishedForm(t, dir, c)
+		if err != nil {
+			fmt.Fprintf(w, "channel %s %s: %v\n", c.channel.ID, c.channel.Name, err)
+			continue
+		}
+		prevHashes := make(map[string]string, len(prev.Events.Values))
+		for _, e := range prev.Events.Values {
+			prevHashes[e.ID] = eventHash(e)
+		}
+		added, changed := 0, 0
+		for _, e := range output.Events.Values {
+			hash, ok := prevHashes[e.ID]
+			switch {
+			case !ok:
+				added++
+			case hash != eventHash(e):
+				changed++
+			}
+			delete(prevHashes, e.ID)
+		}
+		removed := len(prevHashes)
+		if added+changed+removed == 0 {
+			unchanged++
+			continue
+		}
+		fmt.Fprintf(w, "channel changed: %s %s: %d added, %d changed, %d removed events\n",
+			c.channel.ID, c.channel.Name, added, changed, removed)
+	}
+
+	if prev, err := readManifest(dir); err == nil && prev != nil {
+		var removed []string
+		for id := range prev.Channels {
+			if !written[id] {
+				removed = append(removed, id)
+			}
+		}
+		sort.Strings(removed)
+		for _, id := range removed {
+			fmt.Fprintf(w, "channel removed: %s\n", id)
+		}
+	}
+	fmt.Fprintf(w, "%d channels unchanged\n", unchanged)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const primeTimeFileName = "prime_time.json"
+
+// primeTimeWindow is the daily local time window of prime time in minutes
+// after midnight. An end before the start wraps past midnight.
+type primeTimeWindow struct {
+	start, end int
+}
+
+// parsePrimeTime parses a window such as 19:00-23:00.
+func parsePrimeTime(v string) (primeTimeWindow, error) {
+	parts := strings.Split(v, "-")
+	if len(parts) != 2 {
+		return primeTimeWindow{}, fmt.Errorf("invalid primeTime '%s', expected e.g 19:00-23:00", v)
+	}
+	var minutes [2]int
+	for i, p := range parts {
+		t, err := time.Parse("15:04", strings.TrimSpace(p))
+		if err != nil {
+			return primeTimeWindow{}, fmt.Errorf("invalid primeTime '%s', expected e.g 19:00-23:00", v)
+		}
+		minutes[i] = t.Hour()*60 + t.Minute()
+	}
+	if minutes[0] == minutes[1] {
+		return primeTimeWindow{}, fmt.Errorf("invalid primeTime '%s', the window is empty", v)
+	}
+	return primeTimeWindow{start: minutes[0], end: minutes[1]}, nil
+}
+
+// evening returns the date of the prime time the local time falls in, which
+// past midnight is the previous day.
+func (w primeTimeWindow) evening(t time.Time) (string, bool) {
+	t = t.In(time.Local)
+	m := t.Hour()*60 + t.Minute()
+	if w.start < w.end {
+		return t.Format("2006-01-02"), m >= w.start && m < w.end
+	}
+	if m >= w.start {
+		return t.Format("2006-01-02"), true
+	}
+	return t.AddDate(0, 0, -1).Format("2006-01-02"), m < w.end
+}
+
+type primeTimeEvent struct {
+	Date      string `json:"date"`
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	StartTime string `json:"time_from"`
+	EndTime   string `json:"time_till"`
+	Perex     string `json:"perex,omitempty"`
+}
+
+type primeTimeChannel struct {
+	ChannelID string           `json:"channelId"`
+	Channel   string           `json:"channel"`
+	Events    []primeTimeEvent `json:"events"`
+}
+
+// primeTime returns the events of every channel starting in the window.
+func primeTime(channels []*outputChannel, w primeTimeWindow) []primeTimeChannel {
+	result := make([]primeTimeChannel, 0, len(channels))
+	for _, c := range channels {
+		entry := primeTimeChannel{ChannelID: c.ID, Channel: c.Name, Events: []primeTimeEvent{}}
+		for _, e := range c.Events.Values {
+			start, end, err := eventTimes(e)
+			if err != nil {
+				continue
+			}
+			if date, ok := w.evening(start); ok {
+				entry.Events = append(entry.Events, primeTimeEvent{Date: date, ID: e.ID, Name: e.Name,
+					StartTime: start.UTC().Format(outDateLayout), EndTime: end.UTC().Format(outDateLayout), Perex: e.Perex})
+			}
+		}
+		result = append(result, entry)
+	}
+	return result
+}
+
+func writePrimeTime(dir string, converted []convertedChannel, w primeTimeWindow) error {
+	channels := make([]*outputChannel, 0, len(converted))
+	for _, c := range converted {
+		channels = append(channels, c.output)
+	}
+	data, err := json.MarshalIndent(primeTime(channels, w), "", "  ")
+	if err != nil {
+		return err
+	}
+	err = writeOutputFile(filepath.Join(dir, primeTimeFileName), func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("unable to write prime time file due: %v", err)
+	}
+	return nil
+}
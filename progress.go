@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// progress draws a progress bar with an ETA of a run stage on the terminal.
+// A nil progress, returned when the output is not a terminal, does nothing.
+type progress struct {
+	mu    sync.Mutex
+	out   io.Writer
+	stage string
+	total int
+	done  int
+	start time.Time
+	drawn time.Time
+}
+
+// newProgress starts the progress of a stage of total steps.
+func newProgress(stage string, total int) *progress {
+	if total == 0 || !progressEnabled() {
+		return nil
+	}
+	p := &progress{out: os.Stderr, stage: stage, total: total, start: time.Now()}
+	p.draw()
+	return p
+}
+
+func progressEnabled() bool {
+	switch *progressMode {
+	case "on":
+		return true
+	case "off":
+		return false
+	}
+	info, err := os.Stderr.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// add records finished steps, redrawing at most ten times a second.
+func (p *progress) add(n int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done += n
+	if p.done >= p.total || time.Since(p.drawn) >= 100*time.Millisecond {
+		p.draw()
+	}
+}
+
+// finish ends the line of the progress bar.
+func (p *progress) finish() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.draw()
+	fmt.Fprintln(p.out)
+}
+
+func (p *progress) draw() {
+	const width = 30
+	filled := width * p.done / p.total
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", width-filled)
+	eta := "ETA -"
+	if elapsed := time.Since(p.start); p.done >= p.total {
+		eta = "done in " + elapsed.Round(time.Second).String()
+	} else if p.done > 0 {
+		remaining := elapsed * time.Duration(p.total-p.done) / time.Duration(p.done)
+		eta = "ETA " + remaining.Round(time.Second).String()
+	}
+	fmt.Fprintf(p.out, "\r\033[K%s [%s] %d/%d %s", p.stage, bar, p.done, p.total, eta)
+	p.drawn = time.Now()
+}
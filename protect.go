@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// encryptedMagic starts the output files encrypted with -encrypt, followed
+// by the 12 byte nonce and the AES-256-GCM sealed content.
+const encryptedMagic = "EPGAES1\n"
+
+// outputProtection signs and encrypts the output files, see -sign and
+// -encrypt.
+type outputProtection struct {
+	signingKey ed25519.PrivateKey
+	aead       cipher.AEAD
+}
+
+var protection *outputProtection
+
+// loadOutputProtection reads the keys of the enabled protections from the
+// environment: EPGTOOL_SIGNING_KEY is a PEM encoded PKCS #8 Ed25519 private
+// key or the base64 encoded 32 byte seed, EPGTOOL_ENCRYPTION_KEY the base64
+// encoded 32 byte AES key.
+func loadOutputProtection(sign, encrypt bool) (*outputProtection, error) {
+	p := &outputProtection{}
+	if sign {
+		key, err := parseSigningKey(os.Getenv("EPGTOOL_SIGNING_KEY"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid EPGTOOL_SIGNING_KEY due: %v", err)
+		}
+		p.signingKey = key
+	}
+	if encrypt {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(os.Getenv("EPGTOOL_ENCRYPTION_KEY")))
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("invalid EPGTOOL_ENCRYPTION_KEY, expected 32 base64 encoded bytes")
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		if p.aead, err = cipher.NewGCM(block); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+func parseSigningKey(v string) (ed25519.PrivateKey, error) {
+	v = strings.TrimSpace(v)
+	if block, _ := pem.Decode([]byte(v)); block != nil {
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		if k, ok := key.(ed25519.PrivateKey); ok {
+			return k, nil
+		}
+		return nil, fmt.Errorf("not an Ed25519 key")
+	}
+	seed, err := base64.StdEncoding.DecodeString(v)
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("expected a PEM key or %d base64 encoded bytes", ed25519.SeedSize)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// seal returns the content of an output file encrypted with -encrypt and
+// the base64 encoded Ed25519 signature of it with -sign, nil without.
+func (p *outputProtection) seal(data []byte) ([]byte, []byte, error) {
+	if p.aead != nil {
+		nonce := make([]byte, p.aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, nil, err
+		}
+		sealed := append([]byte(encryptedMagic), nonce...)
+		data = p.aead.Seal(sealed, nonce, data, nil)
+	}
+	var sig []byte
+	if p.signingKey != nil {
+		sig = []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(p.signingKey, data)) + "\n")
+	}
+	return data, sig, nil
+}
+
+// writeOutputFile writes an output file. With -encrypt the content is
+// encrypted before it replaces the file, so the plain content is never
+// visible, and with -sign the signature is written into <file>.sig.
+func writeOutputFile(fileName string, write func(w io.Writer) error) error {
+	if protection == nil {
+		return writeFileAtomic(fileName, write)
+	}
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return err
+	}
+	data, sig, err := protection.seal(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	return writeSealedFile(fileName, data, sig)
+}
+
+// writeSealedFile writes the sealed content of an output file and its
+// signature, both prepared beforehand. The signature is replaced first, so
+// a signed file is never published next to the signature of its previous
+// content, and a signature left by an earlier run with -sign is removed
+// before the file is replaced unsigned.
+func writeSealedFile(fileName string, data, sig []byte) error {
+	if sig != nil {
+		if err := writeFileAtomic(fileName+".sig", func(w io.Writer) error { _, err := w.Write(sig); return err }); err != nil {
+			return err
+		}
+	} else if err := outputFiles.Remove(fileName + ".sig"); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("unable to remove stale signature of '%s' due: %v", fileName, err)
+	}
+	return writeFileAtomic(fileName, func(w io.Writer) error { _, err := w.Write(data); return err })
+}
+
+// readOutputFile returns the content of an output file, decrypting it when
+// it was written with -encrypt.
+func readOutputFile(fileName string) ([]byte, error) {
+	data, err := outputFiles.ReadFile(fileName)
+	if err != nil || !bytes.HasPrefix(data, []byte(encryptedMagic)) {
+		return data, err
+	}
+	if protection == nil || protection.aead == nil {
+		return nil, fmt.Errorf("output file '%s' is encrypted, set -encrypt and EPGTOOL_ENCRYPTION_KEY to read it", fileName)
+	}
+	data = data[len(encryptedMagic):]
+	n := protection.aead.NonceSize()
+	if len(data) < n {
+		return nil, fmt.Errorf("output file '%s' is truncated", fileName)
+	}
+	plain, err := protection.aead.Open(nil, data[:n], data[n:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt output file '%s' due: %v", fileName, err)
+	}
+	return plain, nil
+}
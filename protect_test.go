@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func pemKey(t *testing.T, key interface{}) string {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+func TestParseSigningKey(t *testing.T) {
+	seed := bytes.Repeat([]byte{7}, ed25519.SeedSize)
+	want := ed25519.NewKeyFromSeed(seed)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		value   string
+		wantErr string
+	}{
+		{"seed", base64.StdEncoding.EncodeToString(seed), ""},
+		{"seed with newline", base64.StdEncoding.EncodeToString(seed) + "\n", ""},
+		{"pem", pemKey(t, want), ""},
+		{"empty", "", "expected a PEM key"},
+		{"short seed", base64.StdEncoding.EncodeToString(seed[:16]), "expected a PEM key"},
+		{"not base64", "not a key", "expected a PEM key"},
+		{"other key type", pemKey(t, ecKey), "not an Ed25519 key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := parseSigningKey(tt.value)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !key.Equal(want) {
+				t.Error("got a different key")
+			}
+		})
+	}
+}
+
+func TestLoadOutputProtection(t *testing.T) {
+	tests := []struct {
+		name          string
+		sign, encrypt bool
+		signingKey    string
+		encryptionKey string
+		wantErr       string
+	}{
+		{"disabled", false, false, "", "", ""},
+		{"sign", true, false, base64.StdEncoding.EncodeToString(make([]byte, 32)), "", ""},
+		{"encrypt", false, true, "", base64.StdEncoding.EncodeToString(make([]byte, 32)), ""},
+		{"missing signing key", true, false, "", "", "invalid EPGTOOL_SIGNING_KEY"},
+		{"short encryption key", false, true, "", base64.StdEncoding.EncodeToString(make([]byte, 16)), "invalid EPGTOOL_ENCRYPTION_KEY"},
+		{"encryption key not base64", false, true, "", "secret", "invalid EPGTOOL_ENCRYPTION_KEY"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("EPGTOOL_SIGNING_KEY", tt.signingKey)
+			t.Setenv("EPGTOOL_ENCRYPTION_KEY", tt.encryptionKey)
+			p, err := loadOutputProtection(tt.sign, tt.encrypt)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (p.signingKey != nil) != tt.sign || (p.aead != nil) != tt.encrypt {
+				t.Errorf("got protection %+v", p)
+			}
+		})
+	}
+}
+
+// useProtection sets the protection of the output files for the test.
+func useProtection(t *testing.T, sign, encrypt bool) *outputProtection {
+	t.Setenv("EPGTOOL_SIGNING_KEY", base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32)))
+	t.Setenv("EPGTOOL_ENCRYPTION_KEY", base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32)))
+	p, err := loadOutputProtection(sign, encrypt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := protection
+	protection = p
+	t.Cleanup(func() { protection = prev })
+	return p
+}
+
+func TestProtectedOutputFile(t *testing.T) {
+	const content = "<channel/>"
+	tests := []struct {
+		name          string
+		sign, encrypt bool
+	}{
+		{"plain", false, false},
+		{"signed", true, false},
+		{"encrypted", false, true},
+		{"signed and encrypted", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), "a.xml")
+			if err := ioutil.WriteFile(fileName+".sig", []byte("stale"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			p := useProtection(t, tt.sign, tt.encrypt)
+			err := writeOutputFile(fileName, func(w io.Writer) error { _, err := io.WriteString(w, content); return err })
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			written, err := ioutil.ReadFile(fileName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if encrypted := bytes.HasPrefix(written, []byte(encryptedMagic)); encrypted != tt.encrypt {
+				t.Errorf("got encrypted %v, content %q", encrypted, written)
+			}
+			if got, err := readOutputFile(fileName); err != nil || string(got) != content {
+				t.Errorf("read %q, %v", got, err)
+			}
+
+			sig, err := ioutil.ReadFile(fileName + ".sig")
+			if !tt.sign {
+				if err == nil {
+					t.Errorf("wrote signature %q without -sign", sig)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ed25519.Verify(p.signingKey.Public().(ed25519.PublicKey), written, decoded) {
+				t.Error("the signature does not verify the written file")
+			}
+		})
+	}
+}
+
+func TestReadEncryptedOutputFile(t *testing.T) {
+	dir := t.TempDir()
+	useProtection(t, false, true)
+	fileName := filepath.Join(dir, "a.xml")
+	if err := writeOutputFile(fileName, func(w io.Writer) error { _, err := io.WriteString(w, "<channel/>"); return err }); err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := append([]byte(nil), sealed...)
+	tampered[len(tampered)-1] ^= 1
+	for name, data := range map[string]string{"tampered.xml": string(tampered), "truncated.xml": encryptedMagic + "short"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		file       string
+		protection bool
+		wantErr    string
+	}{
+		{"without key", "a.xml", false, "is encrypted"},
+		{"tampered", "tampered.xml", true, "unable to decrypt"},
+		{"truncated", "truncated.xml", true, "is truncated"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !tt.protection {
+				prev := protection
+				protection = nil
+				defer func() { protection = prev }()
+			}
+			if _, err := readOutputFile(filepath.Join(dir, tt.file)); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+// sourceWindow is the period of the programmes kept while parsing the source
+// files, the zero value keeps all of them.
+type sourceWindow struct {
+	from, till time.Time
+}
+
+// sourceWindow returns the period covering the windows of all channels of
+// the job, which is empty when any of them keeps all events. It is widened
+// to whole days around now, so parse cache entries stay valid for a day and
+// the conversion still sees every event overlapping a channel's window.
+func (j job) sourceWindow(now time.Time) sourceWindow {
+	if *window <= 0 {
+		return sourceWindow{}
+	}
+	files := []string{j.ChannelsFile}
+	for _, t := range j.Tenants {
+		if t.ChannelsFile != "" {
+			files = append(files, t.ChannelsFile)
+		}
+	}
+	var widest time.Duration
+	for _, f := range files {
+		channels, _, err := parseChannelsFile(f)
+		if err != nil {
+			return sourceWindow{}
+		}
+		for _, c := range channels {
+			if c.Options.Window <= 0 {
+				return sourceWindow{}
+			}
+			if c.Options.Window > widest {
+				widest = c.Options.Window
+			}
+		}
+	}
+	if widest == 0 {
+		return sourceWindow{}
+	}
+	day := now.UTC().Truncate(24 * time.Hour)
+	return sourceWindow{from: day.Add(-24 * time.Hour), till: day.Add(48*time.Hour + widest)}
+}
+
+func (w sourceWindow) empty() bool {
+	return w.from.IsZero() && w.till.IsZero()
+}
+
+// key identifies the window in the cache keys of the parsed source files.
+func (w sourceWindow) key() string {
+	if w.empty() {
+		return ""
+	}
+	return fmt.Sprintf("-%d-%d", w.from.Unix(), w.till.Unix())
+}
+
+// keeps reports whether the programme overlaps the window. Programmes with
+// unparsable times are kept for the conversion to report them.
+func (w sourceWindow) keeps(p programme) bool {
+	if w.empty() {
+		return true
+	}
+	start, err := parseSourceTime(p.Start)
+	if err != nil {
+		return true
+	}
+	end, err := parseSourceTime(p.Stop)
+	if err != nil {
+		return true
+	}
+	return end.After(w.from) && start.Before(w.till)
+}
+
+// decodeXMLWindow decodes an XMLTV document like xml.Decoder.Decode does, but
+// decodes the programmes one by one dropping the ones outside the window, so
+// they are never held in memory together.
+func decodeXMLWindow(r io.Reader, w sourceWindow) (source, error) {
+	var s source
+	d := xml.NewDecoder(r)
+	root := false
+	for {
+		tok, err := d.Token()
+		if err == io.EOF && root {
+			return s, nil
+		}
+		if err != nil {
+			return s, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if !root {
+			root = true
+			for _, a := range start.Attr {
+				if a.Name.Local == "generator-info-name" {
+					s.Generator = a.Value
+				} else if err := s.UnknownAttrs.UnmarshalXMLAttr(a); err != nil {
+					return s, err
+				}
+			}
+			continue
+		}
+
+		switch start.Name.Local {
+		case "programme":
+			var p programme
+			if err := d.DecodeElement(&p, &start); err != nil {
+				return s, err
+			}
+			if w.keeps(p) {
+				s.ProgramList = append(s.ProgramList, p)
+			}
+		case "channel":
+			var c channel
+			if err := d.DecodeElement(&c, &start); err != nil {
+				return s, err
+			}
+			s.ChannelList = append(s.ChannelList, c)
+		default:
+			if err := s.Unknown.UnmarshalXML(d, start); err != nil {
+				return s, err
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// outputFileName returns the file the channel is written to in dir, in the
+// -outputFormat or as a delta with -deltaOutput. With -packageTrees it is
+// the one in the directory of the channel's first package.
+func outputFileName(t tenant, dir string, channel requestedChannel) string {
+	channelDir := packageDirs(dir, channel)[0]
+	if *deltaOutput {
+		return filepath.Join(channelDir, t.deltaFileName(channel.ID))
+	}
+	fileName, _ := channelFile(t, *outputFormat, channelDir, "", convertedChannel{channel: channel})
+	return fileName
+}
+
+// readPublishedChannel reads an output file written in any of the output
+// formats, telling the format by the file name.
+func readPublishedChannel(fileName string) (*outputChannel, error) {
+	if strings.HasSuffix(fileName, ".delta.xml") {
+		return nil, fmt.Errorf("output file '%s' is a delta", fileName)
+	}
+	data, err := readOutputFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	c, err := channelDecoder(fileName)(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse output file '%s' due: %v", fileName, err)
+	}
+	return c, nil
+}
+
+// channelDecoder returns the decoder of the output file format.
+func channelDecoder(fileName string) func(data []byte) (*outputChannel, error) {
+	switch {
+	case strings.HasSuffix(fileName, ".tva.xml"):
+		return decodeTVA
+	case strings.HasSuffix(fileName, ".eit.json"):
+		return decodeEIT
+	}
+	return decodeChannel
+}
+
+// publishedForm returns the converted channel as it reads back from its
+// output file, so it compares to the previous output in formats which do
+// not keep every field or the event IDs.
+func publishedForm(t tenant, dir string, c convertedChannel) (*outputChannel, error) {
+	fileName := outputFileName(t, dir, c.channel)
+	if strings.HasSuffix(fileName, ".delta.xml") {
+		return c.output, nil
+	}
+	_, encode := channelFile(t, *outputFormat, dir, "", c)
+	var buf bytes.Buffer
+	if err := encode(&buf); err != nil {
+		return nil, err
+	}
+	return channelDecoder(fileName)(buf.Bytes())
+}
+
+// readPreviousOutput reads the output of the channel written into dir by the
+// previous run, from the file of the current format and package directory.
+// With -deltaOutput there is none.
+func readPreviousOutput(t tenant, dir string, channel requestedChannel) (*outputChannel, error) {
+	if *deltaOutput {
+		return nil, os.ErrNotExist
+	}
+	return readPublishedChannel(outputFileName(t, dir, channel))
+}
+
+// loadPublishedChannels reads the channel files listed in the manifest of
+// dir by the last run, ordered by channel ID. Delta files are skipped.
+func loadPublishedChannels(dir string) ([]*outputChannel, error) {
+	m, err := readManifest(dir)
+	if err != nil || m == nil {
+		return nil, err
+	}
+	var channels []*outputChannel
+	for _, mc := range m.Channels {
+		if mc.File == "" || strings.HasSuffix(mc.File, ".delta.xml") {
+			continue
+		}
+		c, err := readPublishedChannel(filepath.Join(dir, filepath.FromSlash(mc.File)))
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, c)
+	}
+	sort.Slice(channels, func(i, j int) bool { return channels[i].ID < channels[j].ID })
+	return channels, nil
+}
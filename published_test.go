@@ -0,0 +1,47 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestReadPublishedChannel(t *testing.T) {
+	c := &outputChannel{ID: "7", Name: "Seven", Events: outputEvents{Values: []outputEvent{
+		{ID: "1", Name: "News", StartTime: "2024-01-01T18:00:00Z", EndTime: "2024-01-01T18:30:00Z", Description: "Daily news"},
+		{ID: "2", Name: "Film", StartTime: "2024-01-01T18:30:00Z", EndTime: "2024-01-01T20:15:00Z"},
+	}}}
+	tests := []struct {
+		format   string
+		fileName string
+		wantIDs  []string
+	}{
+		{"native", "n_events_7.xml", []string{"1", "2"}},
+		{"tva", "n_events_7.tva.xml", []string{"1", "2"}},
+		{"eit", "n_events_7.eit.json", []string{"1", "2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			fileName, encode := channelFile(tenant{}, tt.format, t.TempDir(), "", convertedChannel{output: c, channel: requestedChannel{ID: "7"}})
+			if filepath.Base(fileName) != tt.fileName {
+				t.Fatalf("got file %s, want %s", filepath.Base(fileName), tt.fileName)
+			}
+			if err := writeOutputFile(fileName, encode); err != nil {
+				t.Fatal(err)
+			}
+			got, err := readPublishedChannel(fileName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.ID != c.ID || got.Name != c.Name || len(got.Events.Values) != len(c.Events.Values) {
+				t.Fatalf("got channel %s %s with %d events", got.ID, got.Name, len(got.Events.Values))
+			}
+			for i, e := range got.Events.Values {
+				want := c.Events.Values[i]
+				if e.ID != tt.wantIDs[i] || e.Name != want.Name || e.StartTime != want.StartTime || e.EndTime != want.EndTime ||
+					e.Description != want.Description {
+					t.Errorf("event %d: got %+v, want %+v", i, e, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/mgenov/epgtool/store"
+)
+
+const quarantineDirName = "quarantine"
+
+// quarantineSource moves or copies a rejected source file into the
+// quarantine directory next to an error report, so it can be forwarded to
+// the provider. The files are written into the source store.
+func quarantineSource(dir string, fname string, cause error) error {
+	files, err := dataFiles()
+	if err != nil {
+		return fmt.Errorf("unable to quarantine source file '%s' due: %v", fname, err)
+	}
+	if err := files.MkdirAll(dir); err != nil {
+		return fmt.Errorf("unable to create quarantine directory due: %v", err)
+	}
+	target := filepath.Join(dir, filepath.Base(fname))
+
+	report := fmt.Sprintf("file: %s\nrejected: %s\nerror: %v\n", fname, time.Now().UTC().Format(time.RFC3339), cause)
+	if err := files.Write(target+".error.txt", func(w io.Writer) error { _, err := io.WriteString(w, report); return err }); err != nil {
+		return fmt.Errorf("unable to write quarantine report due: %v", err)
+	}
+
+	switch *quarantineMode {
+	case "copy":
+		err = copyFile(files, fname, target)
+	case "move":
+		if err = copyFile(files, fname, target); err == nil {
+			err = files.Remove(fname)
+		}
+	default:
+		return fmt.Errorf("unsupported quarantine value '%s'", *quarantineMode)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to quarantine source file '%s' due: %v", fname, err)
+	}
+	log.Printf("source file '%s' quarantined into '%s'", fname, dir)
+	return nil
+}
+
+// copyFile copies the source file from into the file to of the store.
+func copyFile(files store.OutputStore, from string, to string) error {
+	in, err := sourceFiles.Open(from)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return files.Write(to, func(w io.Writer) error { _, err := io.Copy(w, in); return err })
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestQuarantineSource(t *testing.T) {
+	for _, mode := range []string{"copy", "move"} {
+		t.Run(mode, func(t *testing.T) {
+			defer func(v string) { *quarantineMode = v }(*quarantineMode)
+			*quarantineMode = mode
+			store := useMemoryStore(t)
+			fname := filepath.Join("data", "CMS-bad.xml")
+			writeMemoryFile(t, store, fname, "<tv>")
+
+			dir := filepath.Join("data", quarantineDirName)
+			if err := quarantineSource(dir, fname, errors.New("unexpected EOF")); err != nil {
+				t.Fatal(err)
+			}
+			if data, err := store.ReadFile(filepath.Join(dir, "CMS-bad.xml")); err != nil || string(data) != "<tv>" {
+				t.Errorf("quarantined %q, %v", data, err)
+			}
+			if report, _ := store.ReadFile(filepath.Join(dir, "CMS-bad.xml.error.txt")); !strings.Contains(string(report), "unexpected EOF") {
+				t.Errorf("got report %q", report)
+			}
+			if _, err := store.ReadFile(fname); os.IsNotExist(err) != (mode == "move") {
+				t.Errorf("the source file after %s: %v", mode, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// asRunEntry is a programme which actually aired according to the as-run log.
+type asRunEntry struct {
+	ChannelID string
+	Title     string
+	Start     time.Time
+	End       time.Time
+}
+
+// discrepancy is a difference between the as-run log and the published EPG.
+type discrepancy struct {
+	ChannelID string
+	Kind      string
+	Title     string
+	Published time.Time
+	Aired     time.Time
+	Detail    string
+}
+
+// reconcileCommand compares an as-run log with the published output files
+// and reports the discrepancies per channel:
+//
+//	epgtool -outputDir out reconcile -asRun asrun-2024-06.csv -output discrepancies.csv
+func reconcileCommand(args []string) {
+	fs := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	asRunFile := fs.String("asRun", "", "as-run log, a CSV file of channel_id,start,end,title rows")
+	tolerance := fs.Duration("tolerance", 2*time.Minute, "largest start or end difference of a programme which is not reported")
+	maxShift := fs.Duration("maxShift", 2*time.Hour, "largest start difference of an aired programme matched to a published one")
+	tenantName := fs.String("tenant", "", "tenant whose output files are compared")
+	output := fs.String("output", "", "also write the discrepancies into the CSV file")
+	fs.Parse(args)
+	if *asRunFile == "" {
+		log.Fatalf("missing -asRun file")
+	}
+
+	entries, err := readAsRunLog(*asRunFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	t := tenant{Name: *tenantName}
+	dir := t.outputDir(*outputDir)
+
+	byChannel := make(map[string][]asRunEntry)
+	for _, e := range entries {
+		byChannel[e.ChannelID] = append(byChannel[e.ChannelID], e)
+	}
+	ids := make([]string, 0, len(byChannel))
+	for id := range byChannel {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var all []discrepancy
+	for _, id := range ids {
+		var published []outputEvent
+		if c, err := readOutputChannel(filepath.Join(dir, t.fileName(id))); err == nil {
+			published = c.Events.Values
+		} else if !os.IsNotExist(err) {
+			log.Fatal(err)
+		}
+		aired := byChannel[id]
+		found := reconcileChannel(id, aired, published, *tolerance, *maxShift)
+		fmt.Printf("channel %s: %d aired, %d published, %d discrepancies\n", id, len(aired), len(published), len(found))
+		for _, d := range found {
+			fmt.Printf("  %s %q %s\n", d.Kind, d.Title, d.Detail)
+		}
+		all = append(all, found...)
+	}
+
+	if *output != "" {
+		err := writeFileAtomic(*output, func(w io.Writer) error { return writeDiscrepancies(w, all) })
+		if err != nil {
+			log.Fatalf("could not write to output file '%s' due: %v", *output, err)
+		}
+	}
+}
+
+// reconcileChannel matches every aired programme to the published event of
+// the same title starting closest to it, within maxShift. Matches differing
+// by more than tolerance are reported as shifted, aired programmes without a
+// match as not in the EPG and the published events within the period of the
+// log left unmatched as not aired.
+func reconcileChannel(id string, aired []asRunEntry, published []outputEvent, tolerance, maxShift time.Duration) []discrepancy {
+	sort.Slice(aired, func(i, j int) bool { return aired[i].Start.Before(aired[j].Start) })
+	spans := make([]span, len(published))
+	valid := make([]bool, len(published))
+	for i, e := range published {
+		if start, end, err := eventTimes(e); err == nil {
+			spans[i], valid[i] = span{start: start, end: end}, true
+		}
+	}
+
+	var result []discrepancy
+	matched := make([]bool, len(published))
+	for _, a := range aired {
+		best := -1
+		for i, e := range published {
+			if !valid[i] || matched[i] || !sameTitle(e.Name, a.Title) {
+				continue
+			}
+			d := absDuration(spans[i].start.Sub(a.Start))
+			if d <= maxShift && (best < 0 || d < absDuration(spans[best].start.Sub(a.Start))) {
+				best = i
+			}
+		}
+		if best < 0 {
+			result = append(result, discrepancy{ChannelID: id, Kind: "not in EPG", Title: a.Title, Aired: a.Start,
+				Detail: fmt.Sprintf("aired %s - %s", a.Start.UTC().Format(outDateLayout), a.End.UTC().Format(outDateLayout))})
+			continue
+		}
+		matched[best] = true
+		startShift, endShift := a.Start.Sub(spans[best].start), a.End.Sub(spans[best].end)
+		if absDuration(startShift) > tolerance || absDuration(endShift) > tolerance {
+			result = append(result, discrepancy{ChannelID: id, Kind: "shifted", Title: a.Title,
+				Published: spans[best].start, Aired: a.Start, Detail: fmt.Sprintf("start %s, end %s", signedDuration(startShift), signedDuration(endShift))})
+		}
+	}
+
+	if len(aired) > 0 {
+		from, till := aired[0].Start, aired[0].End
+		for _, a := range aired {
+			if a.End.After(till) {
+				till = a.End
+			}
+		}
+		for i, e := range published {
+			if valid[i] && !matched[i] && !spans[i].start.Before(from) && spans[i].start.Before(till) {
+				result = append(result, discrepancy{ChannelID: id, Kind: "not aired", Title: e.Name, Published: spans[i].start,
+					Detail: fmt.Sprintf("published %s - %s", spans[i].start.Format(outDateLayout), spans[i].end.Format(outDateLayout))})
+			}
+		}
+	}
+	return result
+}
+
+func signedDuration(d time.Duration) string {
+	if d < 0 {
+		return d.String()
+	}
+	return "+" + d.String()
+}
+
+func sameTitle(a, b string) bool {
+	return strings.EqualFold(strings.Join(strings.Fields(a), " "), strings.Join(strings.Fields(b), " "))
+}
+
+// asRunTimeLayouts are the accepted time formats of the as-run log, times
+// without an offset are read in the -tz timezone.
+var asRunTimeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05-0700", "2006-01-02 15:04:05", "2006-01-02T15:04:05",
+	"2006-01-02 15:04", "2006-01-02T15:04", inLocalDateLayout}
+
+func parseAsRunTime(v string) (time.Time, error) {
+	for _, layout := range asRunTimeLayouts {
+		if t, err := time.ParseInLocation(layout, v, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time '%s'", v)
+}
+
+// readAsRunLog reads the as-run log, skipping a header row and comments.
+func readAsRunLog(fileName string) ([]asRunEntry, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open as-run log due: %v", err)
+	}
+	defer f.Close()
+	cr := csv.NewReader(bufio.NewReader(f))
+	cr.FieldsPerRecord = -1
+	cr.Comment = '#'
+	cr.TrimLeadingSpace = true
+
+	var result []asRunEntry
+	for first := true; ; first = false {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return result, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read as-run log due: %v", err)
+		}
+		line, _ := cr.FieldPos(0)
+		if first && len(rec) > 0 && strings.EqualFold(strings.TrimPrefix(rec[0], "\ufeff"), "channel_id") {
+			continue
+		}
+		if len(rec) < 4 {
+			return nil, fmt.Errorf("%s:%d: expected channel_id,start,end,title", fileName, line)
+		}
+		start, err := parseAsRunTime(strings.TrimSpace(rec[1]))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", fileName, line, err)
+		}
+		end, err := parseAsRunTime(strings.TrimSpace(rec[2]))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", fileName, line, err)
+		}
+		result = append(result, asRunEntry{ChannelID: strings.TrimSpace(rec[0]), Title: strings.TrimSpace(rec[3]),
+			Start: start, End: end})
+	}
+}
+
+func writeDiscrepancies(w io.Writer, discrepancies []discrepancy) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"channel_id", "kind", "title", "published", "aired", "detail"})
+	for _, d := range discrepancies {
+		var published, aired string
+		if !d.Published.IsZero() {
+			published = d.Published.UTC().Format(outDateLayout)
+		}
+		if !d.Aired.IsZero() {
+			aired = d.Aired.UTC().Format(outDateLayout)
+		}
+		cw.Write([]string{d.ChannelID, d.Kind, d.Title, published, aired, d.Detail})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// redisNowNext is the value of the Redis key of a channel, its running event
+// and the two following ones.
+type redisNowNext struct {
+	ChannelID string       `json:"channelId"`
+	Channel   string       `json:"channel"`
+	Now       *outputEvent `json:"now"`
+	Next      *outputEvent `json:"next"`
+	Later     *outputEvent `json:"later"`
+}
+
+// upcomingEvents returns the event running at the given time, or nil when
+// there is none, followed by up to n-1 events starting after it. The channel
+// events are expected sorted.
+func upcomingEvents(c *outputChannel, at time.Time, n int) []*outputEvent {
+	var result []*outputEvent
+	for i := range c.Events.Values {
+		e := &c.Events.Values[i]
+		start, end, err := eventTimes(*e)
+		if err != nil || !end.After(at) {
+			continue
+		}
+		if len(result) == 0 && start.After(at) {
+			result = append(result, nil)
+		}
+		result = append(result, e)
+		if len(result) == n {
+			break
+		}
+	}
+	return result
+}
+
+// updateRedis sets the key <prefix>now_next:<channel ID> of every channel to
+// its now, next and later events as JSON. The keys expire when the running
+// event ends, so a stale schedule disappears when the refresh stops.
+func updateRedis(addr, prefix string, channels []*outputChannel, at time.Time) error {
+	c, err := dialRedis(addr, os.Getenv("EPGTOOL_REDIS_PASSWORD"))
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	for _, channel := range channels {
+		entry := redisNowNext{ChannelID: channel.ID, Channel: channel.Name}
+		events := upcomingEvents(channel, at, 3)
+		for i, target := range []**outputEvent{&entry.Now, &entry.Next, &entry.Later} {
+			if i < len(events) {
+				*target = events[i]
+			}
+		}
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		expire := time.Minute
+		if entry.Now != nil {
+			if _, end, err := eventTimes(*entry.Now); err == nil && end.Sub(at) > expire {
+				expire = end.Sub(at)
+			}
+		}
+		c.send("SET", prefix+"now_next:"+channel.ID, string(data), "PX", strconv.FormatInt(int64(expire/time.Millisecond), 10))
+	}
+	if err := c.w.Flush(); err != nil {
+		return fmt.Errorf("unable to write to redis due: %v", err)
+	}
+	for range channels {
+		if err := c.reply(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// redisConn is a minimal client of the Redis serialization protocol, enough
+// to pipeline commands and check their replies.
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+func dialRedis(addr, password string) (*redisConn, error) {
+	conn, err := net.DialTimeout("tcp", addr, 10*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to redis due: %v", err)
+	}
+	conn.SetDeadline(time.Now().Add(time.Minute))
+	c := &redisConn{Conn: conn, r: bufio.NewReader(conn), w: bufio.NewWriter(conn)}
+	if password != "" {
+		c.send("AUTH", password)
+		if err := c.w.Flush(); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("unable to write to redis due: %v", err)
+		}
+		if err := c.reply(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *redisConn) send(args ...string) {
+	fmt.Fprintf(c.w, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(c.w, "$%d\r\n%s\r\n", len(a), a)
+	}
+}
+
+// reply reads a reply, returning the error replied by the server.
+func (c *redisConn) reply() error {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("unable to read from redis due: %v", err)
+	}
+	if len(line) < 3 {
+		return fmt.Errorf("invalid redis reply %q", line)
+	}
+	value := line[1 : len(line)-2]
+	switch line[0] {
+	case '+', ':':
+		return nil
+	case '-':
+		return fmt.Errorf("redis replied: %s", value)
+	case '$':
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid redis reply %q", line)
+		}
+		if n >= 0 {
+			_, err = io.CopyN(ioutil.Discard, c.r, int64(n)+2)
+		}
+		return err
+	case '*':
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid redis reply %q", line)
+		}
+		for i := 0; i < n; i++ {
+			if err := c.reply(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid redis reply %q", line)
+}
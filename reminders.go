@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const remindersFileName = "reminders.json"
+
+// subscription selects the events push notifications are sent for: events
+// whose name or description contains one of the keywords or which have one of
+// the categories, optionally limited to some channel IDs.
+type subscription struct {
+	Name       string   `json:"name"`
+	Keywords   []string `json:"keywords"`
+	Categories []string `json:"categories"`
+	Channels   []string `json:"channels"`
+}
+
+// subscriptions are read from the -reminders file.
+var subscriptions []subscription
+
+func readSubscriptions(fileName string) ([]subscription, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read reminders file due: %v", err)
+	}
+	var result []subscription
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("unable to parse reminders file due: %v", err)
+	}
+	for i, s := range result {
+		if s.Name == "" {
+			return nil, fmt.Errorf("subscription %d of the reminders file has no name", i+1)
+		}
+		if len(s.Keywords) == 0 && len(s.Categories) == 0 {
+			return nil, fmt.Errorf("subscription '%s' has neither keywords nor categories", s.Name)
+		}
+	}
+	return result, nil
+}
+
+func (s subscription) matches(channelID string, e outputEvent) bool {
+	if len(s.Channels) > 0 && !containsString(s.Channels, channelID) {
+		return false
+	}
+	text := strings.ToLower(e.Name + "\n" + e.Description)
+	for _, k := range s.Keywords {
+		if strings.Contains(text, strings.ToLower(k)) {
+			return true
+		}
+	}
+	if e.Tags != nil {
+		for _, c := range s.Categories {
+			for _, tag := range e.Tags.Values {
+				if strings.EqualFold(tag, c) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// reminder is an upcoming event matched by a subscription.
+type reminder struct {
+	Subscription string `json:"subscription"`
+	ChannelID    string `json:"channelId"`
+	EventID      string `json:"eventId"`
+	Name         string `json:"name"`
+	Start        string `json:"start"`
+}
+
+// reminders returns the events starting after the given time matched by the
+// subscriptions, ordered by their start.
+func reminders(converted []convertedChannel, subs []subscription, now time.Time) []reminder {
+	result := make([]reminder, 0)
+	for _, c := range converted {
+		for _, e := range c.output.Events.Values {
+			start, _, err := eventTimes(e)
+			if err != nil || !start.After(now) {
+				continue
+			}
+			for _, s := range subs {
+				if s.matches(c.output.ID, e) {
+					result = append(result, reminder{Subscription: s.Name, ChannelID: c.output.ID, EventID: e.ID,
+						Name: e.Name, Start: start.UTC().Format(time.RFC3339)})
+				}
+			}
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Start < result[j].Start })
+	return result
+}
+
+func writeReminders(dir string, converted []convertedChannel, subs []subscription, now time.Time) error {
+	data, err := json.Marshal(reminders(converted, subs, now))
+	if err != nil {
+		return err
+	}
+	err = writeOutputFile(filepath.Join(dir, remindersFileName), func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("unable to write reminders file due: %v", err)
+	}
+	return nil
+}
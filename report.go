@@ -0,0 +1,334 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// runReport collects the collisions and adjustments made during a run,
+// echoing every entry to out as it happens. With sampling enabled only the
+// first entries of a kind and channel are echoed, the rest are counted and
+// summarized by flush, while detail still receives every entry.
+type runReport struct {
+	mu      sync.Mutex
+	out     io.Writer
+	detail  io.Writer
+	sample  int
+	seen    map[sampleKey]int
+	Entries []reportEntry `json:"entries"`
+}
+
+type sampleKey struct {
+	kind    string
+	channel string
+}
+
+type reportEntry struct {
+	Kind      string `json:"kind"`
+	ChannelID string `json:"channelId"`
+	Channel   string `json:"channel"`
+	Start     string `json:"start"`
+	Stop      string `json:"stop"`
+	Detail    string `json:"detail,omitempty"`
+}
+
+func newRunReport(out io.Writer) *runReport {
+	return &runReport{
+		out:     out,
+		detail:  reportDetail,
+		sample:  *logSample,
+		seen:    make(map[sampleKey]int),
+		Entries: make([]reportEntry, 0),
+	}
+}
+
+func (r *runReport) add(e reportEntry, text string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Entries = append(r.Entries, e)
+	if r.detail != nil {
+		io.WriteString(r.detail, text)
+	}
+	if r.out == nil {
+		return
+	}
+	if r.sample > 0 {
+		key := sampleKey{e.Kind, e.Channel}
+		r.seen[key]++
+		if r.seen[key] > r.sample {
+			return
+		}
+	}
+	io.WriteString(r.out, text)
+}
+
+// flush echoes the number of entries of every kind and channel which were
+// not echoed due to sampling, e.g "collision x1243 on channel Alfa".
+func (r *runReport) flush() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.out == nil {
+		return
+	}
+	keys := make([]sampleKey, 0, len(r.seen))
+	for k, n := range r.seen {
+		if n > r.sample {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].kind != keys[j].kind {
+			return keys[i].kind < keys[j].kind
+		}
+		return keys[i].channel < keys[j].channel
+	})
+	for _, k := range keys {
+		fmt.Fprintf(r.out, "%s x%d on channel %s (%d not shown)\n", k.kind, r.seen[k], k.channel, r.seen[k]-r.sample)
+	}
+	r.seen = make(map[sampleKey]int)
+}
+
+// collision records an event skipped because it overlaps an already
+// accepted one, existing is the accepted event ending at the same time if any.
+func (r *runReport) collision(channel requestedChannel, event programme, existing *programme) {
+	text := "collision detected\n"
+	text += fmt.Sprintf("   %s channel=\"%s\" start=\"%s\" stop=\"%s\"\n", channel.ID, channel.Name, event.Start, event.Stop)
+	if existing != nil {
+		text += fmt.Sprintln("   event desc: ", existing.Description.Name)
+	}
+	text += fmt.Sprintln("   skip desc: ", event.Description.Name)
+	text += fmt.Sprintln("   startTime: ", event.Start)
+	text += fmt.Sprintln("   endTime  : ", event.Stop)
+	text += "event skipped\n"
+
+	r.add(reportEntry{
+		Kind:      "collision",
+		ChannelID: channel.ID,
+		Channel:   channel.Name,
+		Start:     event.Start,
+		Stop:      event.Stop,
+		Detail:    event.Description.Name,
+	}, text)
+}
+
+// adjusted records an event whose times were changed to start and end.
+func (r *runReport) adjusted(kind string, channel requestedChannel, event programme, start, end time.Time) {
+	r.add(reportEntry{
+		Kind:      kind,
+		ChannelID: channel.ID,
+		Channel:   channel.Name,
+		Start:     event.Start,
+		Stop:      event.Stop,
+		Detail:    fmt.Sprintf("start=\"%s\" stop=\"%s\"", start.Format(inDateLayout), end.Format(inDateLayout)),
+	}, fmt.Sprintf("%s %s channel=\"%s\" start=\"%s\" stop=\"%s\" to start=\"%s\" stop=\"%s\"\n",
+		kind, channel.ID, channel.Name, event.Start, event.Stop, start.Format(inDateLayout), end.Format(inDateLayout)))
+}
+
+// gap records a gap closed by stretching the end of an event to the start of
+// the following one.
+func (r *runReport) gap(channel requestedChannel, stop, newStop time.Time) {
+	r.add(reportEntry{
+		Kind:      "repaired gap",
+		ChannelID: channel.ID,
+		Channel:   channel.Name,
+		Stop:      stop.Format(inDateLayout),
+		Detail:    fmt.Sprintf("stop=\"%s\"", newStop.Format(inDateLayout)),
+	}, fmt.Sprintf("repaired gap %s channel=\"%s\" stop=\"%s\" to stop=\"%s\"\n",
+		channel.ID, channel.Name, stop.Format(inDateLayout), newStop.Format(inDateLayout)))
+}
+
+// cleaned records the characters of the channel texts which were composed,
+// replaced or stripped by the text normalization.
+func (r *runReport) cleaned(channel requestedChannel, c textCleanup) {
+	detail := fmt.Sprintf("composed=%d replaced=%d stripped=%d", c.composed, c.replaced, c.stripped)
+	r.add(reportEntry{
+		Kind:      "normalized text",
+		ChannelID: channel.ID,
+		Channel:   channel.Name,
+		Detail:    detail,
+	}, fmt.Sprintf("normalized text %s channel=\"%s\": %s\n", channel.ID, channel.Name, detail))
+}
+
+// baseline records the events of a channel kept from the previous output.
+func (r *runReport) baseline(channel requestedChannel, events int) {
+	detail := fmt.Sprintf("%d events kept from the previous output", events)
+	r.add(reportEntry{
+		Kind:      "baseline",
+		ChannelID: channel.ID,
+		Channel:   channel.Name,
+		Detail:    detail,
+	}, fmt.Sprintf("baseline %s channel=\"%s\": %s\n", channel.ID, channel.Name, detail))
+}
+
+// emptyChannel records a requested channel matching no source events,
+// suggesting the closest names of the unmapped source channels.
+func (r *runReport) emptyChannel(channel requestedChannel, suggestions []string) {
+	detail := "no events in the source files"
+	if len(suggestions) > 0 {
+		detail += fmt.Sprintf(", did you mean '%s'?", strings.Join(suggestions, "', '"))
+	}
+	r.add(reportEntry{
+		Kind:      "empty channel",
+		ChannelID: channel.ID,
+		Channel:   channel.Name,
+		Detail:    detail,
+	}, fmt.Sprintf("empty channel %s channel=\"%s\": %s\n", channel.ID, channel.Name, detail))
+}
+
+// failedChannel records a channel left out of a partial publish because its
+// conversion failed.
+func (r *runReport) failedChannel(channel requestedChannel, err error) {
+	r.add(reportEntry{
+		Kind:      "failed channel",
+		ChannelID: channel.ID,
+		Channel:   channel.Name,
+		Detail:    err.Error(),
+	}, fmt.Sprintf("failed channel %s channel=\"%s\": %v\n", channel.ID, channel.Name, err))
+}
+
+// coverage records a channel whose new events cover less than its minimum
+// coverage.
+func (r *runReport) coverage(channel requestedChannel, detail string) {
+	r.add(reportEntry{
+		Kind:      "coverage",
+		ChannelID: channel.ID,
+		Channel:   channel.Name,
+		Detail:    detail,
+	}, fmt.Sprintf("coverage regression %s channel=\"%s\": %s\n", channel.ID, channel.Name, detail))
+}
+
+// guardrail records a channel violating one of the output limits.
+func (r *runReport) guardrail(channel requestedChannel, violation string) {
+	r.add(reportEntry{
+		Kind:      "guardrail",
+		ChannelID: channel.ID,
+		Channel:   channel.Name,
+		Detail:    violation,
+	}, fmt.Sprintf("guardrail violated %s channel=\"%s\": %s\n", channel.ID, channel.Name, violation))
+}
+
+// anomaly records a suspicious change compared to the previous output.
+func (r *runReport) anomaly(level anomalyLevel, channel requestedChannel, detail string) {
+	text := fmt.Sprintf("anomaly (%s) %s channel=\"%s\": %s\n", level, channel.ID, channel.Name, detail)
+	if channel.ID == "" {
+		text = fmt.Sprintf("anomaly (%s): %s\n", level, detail)
+	}
+	r.add(reportEntry{
+		Kind:      "anomaly " + level.String(),
+		ChannelID: channel.ID,
+		Channel:   channel.Name,
+		Detail:    detail,
+	}, text)
+}
+
+// runSummary describes the outcome of a job.
+type runSummary struct {
+	SourceFiles int              `json:"sourceFiles"`
+	Channels    int              `json:"sourceChannels"`
+	Tenants     []*tenantSummary `json:"tenants"`
+	Deliveries  []deliveryResult `json:"deliveries,omitempty"`
+}
+
+type tenantSummary struct {
+	Name         string `json:"name,omitempty"`
+	Channels     int    `json:"channels"`
+	WrittenFiles int    `json:"writtenFiles"`
+	Events       int    `json:"events"`
+	OutputBytes  int64  `json:"outputBytes"`
+
+	GuardrailViolations int            `json:"guardrailViolations"`
+	AnomalyLevel        string         `json:"anomalyLevel"`
+	EmptyChannels       []emptyChannel `json:"emptyChannels,omitempty"`
+	// FailedChannels failed to convert and kept their previous output, with
+	// -partial allow.
+	FailedChannels []failedChannel `json:"failedChannels,omitempty"`
+	// CoverageRegressions kept their previous output due to -minCoverage.
+	CoverageRegressions []coverageRegression `json:"coverageRegressions,omitempty"`
+	// LineupChanges are the channel IDs added or removed since the previous
+	// run.
+	LineupChanges []lineupChange `json:"lineupChanges,omitempty"`
+
+	// files are the written output files relative to the output directory.
+	files     []string
+	converted []convertedChannel
+	// mapped are the IDs of the channels file, nil when it is unknown.
+	mapped map[string]bool
+	// inactive are the mapped channels outside of their active period by ID.
+	inactive map[string]requestedChannel
+}
+
+// emptyChannel is a requested channel which matched no source events.
+type emptyChannel struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Suggestions []string `json:"suggestions,omitempty"`
+}
+
+// failedChannel is a requested channel whose conversion failed.
+type failedChannel struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
+// merge appends the entries of other, e.g of a job run in parallel, without
+// echoing them again.
+func (r *runReport) merge(other *runReport) {
+	other.mu.Lock()
+	entries := append([]reportEntry(nil), other.Entries...)
+	other.mu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Entries = append(r.Entries, entries...)
+}
+
+// summary describes the number of entries of every kind, e.g
+// "collision x12, gap x3".
+func (r *runReport) summary() string {
+	counts := r.counts()
+	kinds := make([]string, 0, len(counts))
+	for kind := range counts {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	parts := make([]string, len(kinds))
+	for i, kind := range kinds {
+		parts[i] = fmt.Sprintf("%s x%d", kind, counts[kind])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// counts returns the number of entries of every kind.
+func (r *runReport) counts() map[string]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	counts := make(map[string]int)
+	for _, e := range r.Entries {
+		counts[e.Kind]++
+	}
+	return counts
+}
+
+// top returns up to n entries describing problems, anomalies and guardrail
+// violations first followed by collisions. Repairs are left out.
+func (r *runReport) top(n int) []reportEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var result []reportEntry
+	for _, collisions := range []bool{false, true} {
+		for _, e := range r.Entries {
+			if len(result) == n {
+				return result
+			}
+			isProblem := strings.HasPrefix(e.Kind, "anomaly") || e.Kind == "guardrail" || e.Kind == "empty channel"
+			if (collisions && e.Kind == "collision") || (!collisions && isProblem) {
+				result = append(result, e)
+			}
+		}
+	}
+	return result
+}
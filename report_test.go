@@ -0,0 +1,29 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRunReportMerge(t *testing.T) {
+	var outA, outB bytes.Buffer
+	a, b := newRunReport(&outA), newRunReport(&outB)
+	a.guardrail(requestedChannel{ID: "1", Name: "One"}, "too few events")
+	b.guardrail(requestedChannel{ID: "2", Name: "Two"}, "too few events")
+	b.baseline(requestedChannel{ID: "2", Name: "Two"}, 3)
+
+	if !strings.Contains(outA.String(), "One") || strings.Contains(outA.String(), "Two") {
+		t.Errorf("report of job a echoed %q", outA.String())
+	}
+	if got := a.counts()["guardrail"]; got != 1 {
+		t.Errorf("report of job a has %d guardrail entries, want 1", got)
+	}
+
+	total := newRunReport(nil)
+	total.merge(a)
+	total.merge(b)
+	if got, want := total.summary(), "baseline x1, guardrail x2"; got != want {
+		t.Errorf("got summary %q, want %q", got, want)
+	}
+}
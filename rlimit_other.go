@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd
+
+package main
+
+// openFileLimit is unknown, the open files are limited only by -maxOpenFiles.
+func openFileLimit() int {
+	return 0
+}
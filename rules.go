@@ -0,0 +1,611 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// rule is an expression evaluated for every output event, returning the
+// event, possibly changed with set, or drop() to drop it, e.g
+//
+//	"Новини" in event.tags ? drop() : event
+//	channel == "163" && hour(event.time_from) >= 23 ? set("perex", "") : event
+//
+// Expressions have string and number literals, true and false, the event
+// fields by their JSON name (event.name), the channel ID as channel, the
+// operators ! && || == != < <= > >= + in, the conditional ?: and the
+// functions of ruleFunctions.
+type rule struct {
+	source string
+	root   ruleNode
+	// origin tells where the rule was defined, for the audit log.
+	origin string
+}
+
+// transformRules are the rules applied to the output events, loaded from the
+// batch config or with -rules.
+var transformRules []*rule
+
+type ruleEnv struct {
+	event   map[string]interface{}
+	channel string
+}
+
+// droppedEvent is the value of drop().
+type droppedEvent struct{}
+
+type ruleNode interface {
+	eval(env *ruleEnv) (interface{}, error)
+}
+
+type ruleFunction struct {
+	args int
+	call func(env *ruleEnv, args []interface{}) (interface{}, error)
+}
+
+var ruleFunctions = map[string]ruleFunction{
+	"drop": {0, func(env *ruleEnv, args []interface{}) (interface{}, error) {
+		return droppedEvent{}, nil
+	}},
+	"set": {2, func(env *ruleEnv, args []interface{}) (interface{}, error) {
+		env.event[ruleString(args[0])] = args[1]
+		return env.event, nil
+	}},
+	"upper": {1, func(env *ruleEnv, args []interface{}) (interface{}, error) {
+		return strings.ToUpper(ruleString(args[0])), nil
+	}},
+	"lower": {1, func(env *ruleEnv, args []interface{}) (interface{}, error) {
+		return strings.ToLower(ruleString(args[0])), nil
+	}},
+	"trim": {1, func(env *ruleEnv, args []interface{}) (interface{}, error) {
+		return strings.TrimSpace(ruleString(args[0])), nil
+	}},
+	"replace": {3, func(env *ruleEnv, args []interface{}) (interface{}, error) {
+		return strings.Replace(ruleString(args[0]), ruleString(args[1]), ruleString(args[2]), -1), nil
+	}},
+	"startsWith": {2, func(env *ruleEnv, args []interface{}) (interface{}, error) {
+		return strings.HasPrefix(ruleString(args[0]), ruleString(args[1])), nil
+	}},
+	"endsWith": {2, func(env *ruleEnv, args []interface{}) (interface{}, error) {
+		return strings.HasSuffix(ruleString(args[0]), ruleString(args[1])), nil
+	}},
+	"matches": {2, func(env *ruleEnv, args []interface{}) (interface{}, error) {
+		re, err := ruleRegexp(ruleString(args[1]))
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString(ruleString(args[0])), nil
+	}},
+	"len": {1, func(env *ruleEnv, args []interface{}) (interface{}, error) {
+		if list, ok := args[0].([]interface{}); ok {
+			return float64(len(list)), nil
+		}
+		return float64(len([]rune(ruleString(args[0])))), nil
+	}},
+	"hour": {1, func(env *ruleEnv, args []interface{}) (interface{}, error) {
+		t, err := time.Parse(outDateLayout, ruleString(args[0]))
+		if err != nil {
+			return nil, err
+		}
+		return float64(t.Local().Hour()), nil
+	}},
+	"weekday": {1, func(env *ruleEnv, args []interface{}) (interface{}, error) {
+		t, err := time.Parse(outDateLayout, ruleString(args[0]))
+		if err != nil {
+			return nil, err
+		}
+		return float64(t.Local().Weekday()), nil
+	}},
+}
+
+// maxRuleRegexps bounds ruleRegexps, as the patterns of matches may be built
+// from the event fields and so differ for every event.
+const maxRuleRegexps = 256
+
+// ruleRegexps caches the compiled expressions of matches, rules of parallel
+// jobs share it. It is emptied once it holds maxRuleRegexps expressions.
+var ruleRegexps = struct {
+	sync.Mutex
+	m map[string]*regexp.Regexp
+}{m: make(map[string]*regexp.Regexp)}
+
+func ruleRegexp(expr string) (*regexp.Regexp, error) {
+	ruleRegexps.Lock()
+	defer ruleRegexps.Unlock()
+	if re, ok := ruleRegexps.m[expr]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(ruleRegexps.m) >= maxRuleRegexps {
+		ruleRegexps.m = make(map[string]*regexp.Regexp)
+	}
+	ruleRegexps.m[expr] = re
+	return re, nil
+}
+
+// parseRules parses the rule expressions defined in the origin, e.g the
+// batch config file.
+func parseRules(sources []string, origin string) ([]*rule, error) {
+	var result []*rule
+	for i, src := range sources {
+		root, err := parseRule(src)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule %d '%s' due: %v", i+1, src, err)
+		}
+		result = append(result, &rule{source: src, root: root, origin: fmt.Sprintf("%s rule %d", origin, i+1)})
+	}
+	return result, nil
+}
+
+// readRulesFile reads a file with a rule per line, in which lines starting
+// with // are comments.
+func readRulesFile(fileName string) ([]*rule, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read rules file due: %v", err)
+	}
+	var sources []string
+	var lines []int
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "//") {
+			sources = append(sources, line)
+			lines = append(lines, i+1)
+		}
+	}
+	rules, err := parseRules(sources, fileName)
+	for i, r := range rules {
+		r.origin = fmt.Sprintf("%s:%d", fileName, lines[i])
+	}
+	return rules, err
+}
+
+// applyRules evaluates the rules in order on the JSON form of the event,
+// reporting false when one of them drops it.
+func applyRules(rules []*rule, channelID string, e outputEvent) (outputEvent, bool, error) {
+	if len(rules) == 0 {
+		return e, true, nil
+	}
+	var out outputEvent
+	var ruleErr error
+	keep, err := transformJSON(e, &out, func(m map[string]interface{}) (map[string]interface{}, bool) {
+		for _, r := range rules {
+			var before map[string]interface{}
+			if auditLog != nil {
+				before = copyFields(m)
+			}
+			v, err := r.root.eval(&ruleEnv{event: m, channel: channelID})
+			if err != nil {
+				ruleErr = fmt.Errorf("rule '%s' failed due: %v", r.source, err)
+				return nil, false
+			}
+			switch v := v.(type) {
+			case droppedEvent:
+				auditLog.change(r.origin, r.source, channelID, before, nil)
+				return nil, false
+			case map[string]interface{}:
+				auditLog.change(r.origin, r.source, channelID, before, v)
+				m = v
+			default:
+				ruleErr = fmt.Errorf("rule '%s' returned %v instead of the event or drop()", r.source, v)
+				return nil, false
+			}
+		}
+		return m, true
+	})
+	if ruleErr != nil {
+		return e, false, ruleErr
+	}
+	return out, keep, err
+}
+
+type ruleToken struct {
+	kind byte // 'i'dent, 's'tring, 'n'umber, 'o'perator or 0 at the end
+	text string
+	pos  int
+}
+
+func lexRule(src string) ([]ruleToken, error) {
+	var tokens []ruleToken
+	runes := []rune(src)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, ruleToken{'i', string(runes[start:i]), start})
+		case unicode.IsDigit(r):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, ruleToken{'n', string(runes[start:i]), start})
+		case r == '"' || r == '\'':
+			start := i
+			var b strings.Builder
+			for i++; i < len(runes) && runes[i] != r; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				b.WriteRune(runes[i])
+			}
+			if i == len(runes) {
+				return nil, fmt.Errorf("unterminated string at %d", start)
+			}
+			i++
+			tokens = append(tokens, ruleToken{'s', b.String(), start})
+		default:
+			op := ""
+			for _, candidate := range []string{"==", "!=", "<=", ">=", "&&", "||", "!", "<", ">", "?", ":", "(", ")", ",", ".", "+"} {
+				if strings.HasPrefix(string(runes[i:]), candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected '%c' at %d", r, i)
+			}
+			tokens = append(tokens, ruleToken{'o', op, i})
+			i += len(op)
+		}
+	}
+	return append(tokens, ruleToken{pos: len(runes)}), nil
+}
+
+type ruleParser struct {
+	tokens []ruleToken
+	pos    int
+}
+
+func parseRule(src string) (ruleNode, error) {
+	tokens, err := lexRule(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &ruleParser{tokens: tokens}
+	n, err := p.conditional()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != 0 {
+		return nil, fmt.Errorf("unexpected '%s' at %d", t.text, t.pos)
+	}
+	return n, nil
+}
+
+func (p *ruleParser) peek() ruleToken {
+	return p.tokens[p.pos]
+}
+
+// accept consumes the next token when it is the operator or keyword op.
+func (p *ruleParser) accept(op string) bool {
+	if t := p.peek(); (t.kind == 'o' || t.kind == 'i') && t.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *ruleParser) expect(op string) error {
+	if !p.accept(op) {
+		t := p.peek()
+		return fmt.Errorf("expected '%s' at %d", op, t.pos)
+	}
+	return nil
+}
+
+func (p *ruleParser) conditional() (ruleNode, error) {
+	c, err := p.binary(0)
+	if err != nil || !p.accept("?") {
+		return c, err
+	}
+	a, err := p.conditional()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	b, err := p.conditional()
+	if err != nil {
+		return nil, err
+	}
+	return condNode{c, a, b}, nil
+}
+
+// ruleOperators lists the binary operators by increasing precedence.
+var ruleOperators = [][]string{{"||"}, {"&&"}, {"==", "!=", "<=", ">=", "<", ">", "in"}, {"+"}}
+
+func (p *ruleParser) binary(level int) (ruleNode, error) {
+	if level == len(ruleOperators) {
+		return p.unary()
+	}
+	left, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := ""
+		for _, candidate := range ruleOperators[level] {
+			if p.accept(candidate) {
+				op = candidate
+				break
+			}
+		}
+		if op == "" {
+			return left, nil
+		}
+		right, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op, left, right}
+	}
+}
+
+func (p *ruleParser) unary() (ruleNode, error) {
+	if p.accept("!") {
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{x}, nil
+	}
+	x, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(".") {
+		t := p.peek()
+		if t.kind != 'i' {
+			return nil, fmt.Errorf("expected a field name at %d", t.pos)
+		}
+		p.pos++
+		x = memberNode{x, t.text}
+	}
+	return x, nil
+}
+
+func (p *ruleParser) primary() (ruleNode, error) {
+	t := p.peek()
+	switch {
+	case t.kind == 's':
+		p.pos++
+		return literalNode{t.text}, nil
+	case t.kind == 'n':
+		p.pos++
+		v, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number '%s' at %d", t.text, t.pos)
+		}
+		return literalNode{v}, nil
+	case p.accept("("):
+		x, err := p.conditional()
+		if err != nil {
+			return nil, err
+		}
+		return x, p.expect(")")
+	case t.kind != 'i':
+		return nil, fmt.Errorf("unexpected '%s' at %d", t.text, t.pos)
+	}
+
+	p.pos++
+	switch t.text {
+	case "true", "false":
+		return literalNode{t.text == "true"}, nil
+	case "event", "channel":
+		return identNode{t.text}, nil
+	}
+	f, ok := ruleFunctions[t.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown name '%s' at %d", t.text, t.pos)
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var args []ruleNode
+	for !p.accept(")") {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.conditional()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	if len(args) != f.args {
+		return nil, fmt.Errorf("%s takes %d arguments, got %d", t.text, f.args, len(args))
+	}
+	if t.text == "matches" {
+		if pattern, ok := args[1].(literalNode); ok {
+			if _, err := regexp.Compile(ruleString(pattern.v)); err != nil {
+				return nil, fmt.Errorf("invalid pattern at %d due: %v", t.pos, err)
+			}
+		}
+	}
+	return callNode{f, args}, nil
+}
+
+type literalNode struct{ v interface{} }
+
+func (n literalNode) eval(env *ruleEnv) (interface{}, error) { return n.v, nil }
+
+type identNode struct{ name string }
+
+func (n identNode) eval(env *ruleEnv) (interface{}, error) {
+	if n.name == "event" {
+		return env.event, nil
+	}
+	return env.channel, nil
+}
+
+type memberNode struct {
+	x    ruleNode
+	name string
+}
+
+func (n memberNode) eval(env *ruleEnv) (interface{}, error) {
+	v, err := n.x.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%v has no field '%s'", v, n.name)
+	}
+	return m[n.name], nil
+}
+
+type notNode struct{ x ruleNode }
+
+func (n notNode) eval(env *ruleEnv) (interface{}, error) {
+	v, err := n.x.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	return !ruleTruth(v), nil
+}
+
+type condNode struct{ c, a, b ruleNode }
+
+func (n condNode) eval(env *ruleEnv) (interface{}, error) {
+	c, err := n.c.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if ruleTruth(c) {
+		return n.a.eval(env)
+	}
+	return n.b.eval(env)
+}
+
+type callNode struct {
+	f    ruleFunction
+	args []ruleNode
+}
+
+func (n callNode) eval(env *ruleEnv) (interface{}, error) {
+	args := make([]interface{}, len(n.args))
+	for i, a := range n.args {
+		v, err := a.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	return n.f.call(env, args)
+}
+
+type binaryNode struct {
+	op          string
+	left, right ruleNode
+}
+
+func (n binaryNode) eval(env *ruleEnv) (interface{}, error) {
+	l, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case n.op == "&&" && !ruleTruth(l):
+		return false, nil
+	case n.op == "||" && ruleTruth(l):
+		return true, nil
+	}
+	r, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "&&", "||":
+		return ruleTruth(r), nil
+	case "+":
+		if a, ok := l.(float64); ok {
+			if b, ok := r.(float64); ok {
+				return a + b, nil
+			}
+		}
+		return ruleString(l) + ruleString(r), nil
+	case "in":
+		if list, ok := r.([]interface{}); ok {
+			for _, v := range list {
+				if ruleString(v) == ruleString(l) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+		return strings.Contains(ruleString(r), ruleString(l)), nil
+	}
+
+	c := strings.Compare(ruleString(l), ruleString(r))
+	if a, ok := l.(float64); ok {
+		if b, ok := r.(float64); ok {
+			c = 0
+			if a < b {
+				c = -1
+			} else if a > b {
+				c = 1
+			}
+		}
+	}
+	switch n.op {
+	case "==":
+		return c == 0, nil
+	case "!=":
+		return c != 0, nil
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	default:
+		return c >= 0, nil
+	}
+}
+
+// ruleTruth is false for false, nil, 0 and the empty string.
+func ruleTruth(v interface{}) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case nil:
+		return false
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	}
+	return true
+}
+
+func ruleString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRuleErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`"open`, "unterminated string at 0"},
+		{`event.name # 1`, "unexpected '#' at 11"},
+		{`event.name ==`, "unexpected '' at 13"},
+		{`event.`, "expected a field name at 6"},
+		{`event.1`, "expected a field name at 6"},
+		{`(true`, "expected ')' at 5"},
+		{`true ? event`, "expected ':' at 12"},
+		{`true event`, "unexpected 'event' at 5"},
+		{`nothing(1)`, "unknown name 'nothing' at 0"},
+		{`upper`, "expected '(' at 5"},
+		{`upper("a" "b")`, "expected ',' at 10"},
+		{`upper("a", "b")`, "upper takes 1 arguments, got 2"},
+		{`drop(1)`, "drop takes 0 arguments, got 1"},
+		{`1.2.3 == 1`, "invalid number '1.2.3' at 0"},
+		{`matches(event.name, "(")`, "invalid pattern at 0"},
+		{``, "unexpected '' at 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			_, err := parseRule(tt.src)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvalRule(t *testing.T) {
+	defer func(loc *time.Location) { time.Local = loc }(time.Local)
+	time.Local = time.UTC
+
+	tests := []struct {
+		src  string
+		want interface{}
+	}{
+		// literals and names
+		{`"a"`, "a"},
+		{`'it\'s'`, "it's"},
+		{`1.5`, 1.5},
+		{`true`, true},
+		{`false`, false},
+		{`channel`, "163"},
+		{`event.name`, "Новини"},
+		{`event.missing`, nil},
+		// precedence and grouping
+		{`1 + 2 == 3`, true},
+		{`true || false && false`, true},
+		{`(true || false) && false`, false},
+		{`!true || true`, true},
+		{`!(true || true)`, false},
+		{`!!event.name`, true},
+		{`false ? 1 : true ? 2 : 3`, 2.0},
+		{`true ? false ? 1 : 2 : 3`, 2.0},
+		// operators
+		{`1 + 2`, 3.0},
+		{`"a" + 1`, "a1"},
+		{`event.name + "!"`, "Новини!"},
+		{`2 < 10`, true},
+		{`"2" < "10"`, false},
+		{`2 <= 2`, true},
+		{`3 > 2`, true},
+		{`2 >= 3`, false},
+		{`"a" != "b"`, true},
+		{`event.production_year == 2024`, true},
+		{`event.production_year == "2024"`, true},
+		{`"News" in event.tags`, true},
+		{`"Sport" in event.tags`, false},
+		{`"ови" in event.name`, true},
+		{`event.missing == ""`, true},
+		// truth
+		{`0 ? 1 : 2`, 2.0},
+		{`"" ? 1 : 2`, 2.0},
+		{`event.missing ? 1 : 2`, 2.0},
+		{`event.tags ? 1 : 2`, 1.0},
+		{`false || 0`, false},
+		{`true && "x"`, true},
+		// functions
+		{`upper(event.name)`, "НОВИНИ"},
+		{`lower("ABC")`, "abc"},
+		{`trim("  a ")`, "a"},
+		{`replace("a-b-c", "-", "+")`, "a+b+c"},
+		{`startsWith(event.name, "Нов")`, true},
+		{`endsWith(event.name, "Нов")`, false},
+		{`matches(event.name, "^Н.+и$")`, true},
+		{`matches(event.name, "^" + "Sport")`, false},
+		{`len(event.name)`, 6.0},
+		{`len(event.tags)`, 2.0},
+		{`hour(event.time_from)`, 23.0},
+		{`weekday(event.time_from)`, 1.0},
+		{`drop()`, droppedEvent{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			root, err := parseRule(tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			env := &ruleEnv{channel: "163", event: map[string]interface{}{
+				"name":            "Новини",
+				"time_from":       "2024-01-01T23:30:00Z",
+				"production_year": 2024.0,
+				"tags":            []interface{}{"News", "Live"},
+			}}
+			got, err := root.eval(env)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprintf("%#v", got) != fmt.Sprintf("%#v", tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvalRuleErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`channel.name`, "163 has no field 'name'"},
+		{`hour(event.name)`, "cannot parse"},
+		{`weekday("")`, "cannot parse"},
+		{`matches(event.name, event.name + "(")`, "missing closing )"},
+		{`false || hour("x")`, "cannot parse"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			root, err := parseRule(tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = root.eval(&ruleEnv{channel: "163", event: map[string]interface{}{"name": "News"}})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvalRuleShortCircuit(t *testing.T) {
+	for _, src := range []string{`false && hour("x")`, `true || hour("x")`, `true ? 1 : hour("x")`} {
+		root, err := parseRule(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := root.eval(&ruleEnv{event: map[string]interface{}{}}); err != nil {
+			t.Errorf("%s: got error %v", src, err)
+		}
+	}
+}
+
+func TestApplyRules(t *testing.T) {
+	e := outputEvent{ID: "1", Name: "News", Perex: "Daily", StartTime: "2024-01-01T18:00:00Z"}
+	tests := []struct {
+		name      string
+		rules     []string
+		wantKeep  bool
+		wantName  string
+		wantPerex string
+		wantErr   string
+	}{
+		{"no rules", nil, true, "News", "Daily", ""},
+		{"unchanged", []string{`event`}, true, "News", "Daily", ""},
+		{"set", []string{`set("perex", upper(event.perex))`}, true, "News", "DAILY", ""},
+		{"in order", []string{`set("name", event.name + " 1")`, `set("perex", event.name)`}, true, "News 1", "News 1", ""},
+		{"conditional drop", []string{`channel == "7" ? drop() : event`}, false, "", "", ""},
+		{"conditional keep", []string{`channel == "8" ? drop() : event`}, true, "News", "Daily", ""},
+		{"drop stops", []string{`drop()`, `hour("x")`}, false, "", "", ""},
+		{"not an event", []string{`event.name`}, false, "", "", "returned News instead of the event or drop()"},
+		{"failing", []string{`hour(event.name)`}, false, "", "", "rule 'hour(event.name)' failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := parseRules(tt.rules, "test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, keep, err := applyRules(rules, "7", e)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if keep != tt.wantKeep {
+				t.Fatalf("got keep %v, want %v", keep, tt.wantKeep)
+			}
+			if keep && (got.Name != tt.wantName || got.Perex != tt.wantPerex || got.StartTime != e.StartTime) {
+				t.Errorf("got %q %q %q, want %q %q", got.Name, got.Perex, got.StartTime, tt.wantName, tt.wantPerex)
+			}
+		})
+	}
+}
+
+func TestParseRulesOrigin(t *testing.T) {
+	rules, err := parseRules([]string{`event`, `drop()`}, "batch.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rules[1].origin != "batch.json rule 2" {
+		t.Errorf("got origin %q", rules[1].origin)
+	}
+	if _, err := parseRules([]string{`event`, `event.`}, "batch.json"); err == nil ||
+		!strings.HasPrefix(err.Error(), "invalid rule 2 'event.'") {
+		t.Errorf("got error %v", err)
+	}
+}
+
+func TestRuleRegexpBounded(t *testing.T) {
+	for i := 0; i < 3*maxRuleRegexps; i++ {
+		if _, err := ruleRegexp(fmt.Sprintf("^%d$", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ruleRegexps.Lock()
+	defer ruleRegexps.Unlock()
+	if n := len(ruleRegexps.m); n > maxRuleRegexps {
+		t.Errorf("cache holds %d expressions, want at most %d", n, maxRuleRegexps)
+	}
+}
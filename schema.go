@@ -0,0 +1,30 @@
+package main
+
+import (
+	"embed"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+//go:embed schema
+var pipelineSchemas embed.FS
+
+// schemaCommand prints the JSON Schema of a pipeline file, programmes for the
+// output of parse and channels for the output of transform:
+//
+//	epgtool schema channels > channels.v1.json
+func schemaCommand(args []string) {
+	fs := flag.NewFlagSet("schema", flag.ExitOnError)
+	version := fs.Int("version", pipelineVersion, "schema version")
+	fs.Parse(args)
+	if fs.NArg() != 1 || (fs.Arg(0) != programmesKind && fs.Arg(0) != channelsKind) {
+		log.Fatalf("usage: epgtool schema [-version n] %s|%s", programmesKind, channelsKind)
+	}
+	data, err := pipelineSchemas.ReadFile(fmt.Sprintf("schema/%s.v%d.json", fs.Arg(0), *version))
+	if err != nil {
+		log.Fatalf("unknown schema version %d", *version)
+	}
+	os.Stdout.Write(data)
+}
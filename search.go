@@ -0,0 +1,153 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// searchIndex is an in-memory inverted index over the names and
+// descriptions of the published events.
+type searchIndex struct {
+	events   []searchHit
+	postings map[string][]int
+	// tokens are the sorted keys of postings, used for prefix matching.
+	tokens []string
+}
+
+type searchHit struct {
+	ChannelID string      `json:"channelId"`
+	Channel   string      `json:"channel"`
+	Event     outputEvent `json:"event"`
+}
+
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func newSearchIndex(channels []*outputChannel) *searchIndex {
+	idx := &searchIndex{postings: make(map[string][]int)}
+	for _, c := range channels {
+		for _, e := range c.Events.Values {
+			n := len(idx.events)
+			idx.events = append(idx.events, searchHit{ChannelID: c.ID, Channel: c.Name, Event: e})
+			seen := make(map[string]bool)
+			for _, t := range tokenize(e.Name + " " + e.Description) {
+				if !seen[t] {
+					seen[t] = true
+					idx.postings[t] = append(idx.postings[t], n)
+				}
+			}
+		}
+	}
+	for t := range idx.postings {
+		idx.tokens = append(idx.tokens, t)
+	}
+	sort.Strings(idx.tokens)
+	return idx
+}
+
+// match returns the events containing every term of the query, a term
+// matches any indexed word it is a prefix of.
+func (idx *searchIndex) match(query string) []searchHit {
+	terms := tokenize(query)
+	if len(terms) == 0 {
+		return nil
+	}
+	var result map[int]bool
+	for _, term := range terms {
+		found := make(map[int]bool)
+		for i := sort.SearchStrings(idx.tokens, term); i < len(idx.tokens) && strings.HasPrefix(idx.tokens[i], term); i++ {
+			for _, n := range idx.postings[idx.tokens[i]] {
+				if result == nil || result[n] {
+					found[n] = true
+				}
+			}
+		}
+		result = found
+	}
+
+	hits := make([]searchHit, 0, len(result))
+	for n := range result {
+		hits = append(hits, idx.events[n])
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Event.StartTime != hits[j].Event.StartTime {
+			return hits[i].Event.StartTime < hits[j].Event.StartTime
+		}
+		if hits[i].ChannelID != hits[j].ChannelID {
+			return hits[i].ChannelID < hits[j].ChannelID
+		}
+		return hits[i].Event.ID < hits[j].Event.ID
+	})
+	return hits
+}
+
+// searchCache keeps the index of the output of the last finished run.
+type searchCache struct {
+	mu    sync.Mutex
+	runID string
+	index *searchIndex
+}
+
+func (s *server) searchIndex() (*searchIndex, error) {
+	s.mu.Lock()
+	runID := ""
+	for i := len(s.order) - 1; i >= 0; i-- {
+		if r := s.runs[s.order[i]]; r.Finished != nil {
+			runID = r.ID
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	s.search.mu.Lock()
+	defer s.search.mu.Unlock()
+	if s.search.index == nil || s.search.runID != runID {
+		channels, err := loadPublishedChannels(*outputDir)
+		if err != nil {
+			return nil, err
+		}
+		s.search.index = newSearchIndex(channels)
+		s.search.runID = runID
+	}
+	return s.search.index, nil
+}
+
+// handleSearch serves GET /search?q=&from=&to=&limit= returning the events
+// matching all words of q ordered by start time.
+func (s *server) handleSearch(w http.ResponseWriter, req *http.Request) {
+	from, to, err := parseTimeRange(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit := 50
+	if v := req.URL.Query().Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	idx, err := s.searchIndex()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	hits := make([]searchHit, 0)
+	for _, h := range idx.match(req.URL.Query().Get("q")) {
+		if len(hits) == limit {
+			break
+		}
+		if eventInRange(h.Event, from, to) {
+			hits = append(hits, h)
+		}
+	}
+	writeJSON(w, http.StatusOK, hits)
+}
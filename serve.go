@@ -0,0 +1,430 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	runStatusRunning   = "running"
+	runStatusSucceeded = "succeeded"
+	runStatusFailed    = "failed"
+)
+
+// run is a conversion triggered through the HTTP API.
+type run struct {
+	ID       string      `json:"id"`
+	Status   string      `json:"status"`
+	Job      job         `json:"job"`
+	Started  time.Time   `json:"started"`
+	Finished *time.Time  `json:"finished,omitempty"`
+	Error    string      `json:"error,omitempty"`
+	Summary  *runSummary `json:"summary,omitempty"`
+
+	report *runReport
+}
+
+// server exposes the conversion over HTTP. Runs are executed one at a time
+// since they usually write to the same output directory.
+type server struct {
+	mu     sync.Mutex
+	runs   map[string]*run
+	order  []string
+	nextID int
+
+	running sync.Mutex
+	// mappingMu guards the edits of the channels file from the web UI.
+	mappingMu sync.Mutex
+
+	// maxAge is the maximum age of the last successful run for the
+	// server to be considered ready.
+	maxAge time.Duration
+	// keepRuns and keepRunsFor limit the finished runs kept for the API by
+	// their count and age, 0 disabling the limit.
+	keepRuns    int
+	keepRunsFor time.Duration
+
+	searchEnabled bool
+	search        searchCache
+
+	// csrfToken is posted back by the forms of the web UI.
+	csrfToken string
+}
+
+func newServer(maxAge time.Duration) *server {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		log.Fatalf("unable to generate CSRF token due: %v", err)
+	}
+	return &server{runs: make(map[string]*run), maxAge: maxAge, csrfToken: hex.EncodeToString(token)}
+}
+
+func (s *server) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/runs", s.handleRuns)
+	mux.HandleFunc("/runs/", s.handleRun)
+	mux.HandleFunc("/healthz", s.handleHealth)
+	mux.HandleFunc("/readyz", s.handleReady)
+	mux.HandleFunc("/api/channels", s.handleAPIChannels)
+	mux.HandleFunc("/api/channels/", s.handleAPIEvents)
+	mux.HandleFunc("/graphql", s.handleGraphQL)
+	mux.HandleFunc("/now-next", s.handleNowNext)
+	if s.searchEnabled {
+		mux.HandleFunc("/search", s.handleSearch)
+	}
+	mux.HandleFunc("/ui/", s.handleUI)
+	mux.HandleFunc("/ui/preview", s.handlePreview)
+	mux.HandleFunc("/ui/mapping", s.handleMapping)
+	return mux
+}
+
+// start registers a new run of j and executes it in the background.
+func (s *server) start(j job) *run {
+	s.mu.Lock()
+	s.nextID++
+	r := &run{
+		ID:      strconv.Itoa(s.nextID),
+		Status:  runStatusRunning,
+		Job:     j,
+		Started: time.Now(),
+		report:  newRunReport(os.Stdout),
+	}
+	s.runs[r.ID] = r
+	s.order = append(s.order, r.ID)
+	s.prune(r.Started)
+	s.mu.Unlock()
+
+	go func() {
+		s.running.Lock()
+		defer s.running.Unlock()
+
+		summary, err := j.run(newSourceCache(), r.report)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		finished := time.Now()
+		r.Finished = &finished
+		r.Summary = summary
+		if err != nil {
+			r.Status = runStatusFailed
+			r.Error = err.Error()
+			log.Printf("run %s failed due: %v", r.ID, err)
+		} else {
+			r.Status = runStatusSucceeded
+		}
+		s.prune(finished)
+	}()
+	return r
+}
+
+// prune forgets the oldest finished runs beyond keepRuns and the ones
+// finished longer than keepRunsFor ago. Unfinished runs and the last finished
+// one, which decides the readiness, are always kept. The caller holds s.mu.
+func (s *server) prune(now time.Time) {
+	last := ""
+	finished := 0
+	for _, id := range s.order {
+		if r := s.runs[id]; r.Finished != nil {
+			last = id
+			finished++
+		}
+	}
+	kept := s.order[:0]
+	for _, id := range s.order {
+		r := s.runs[id]
+		if r.Finished != nil && id != last {
+			if (s.keepRuns > 0 && finished > s.keepRuns) || (s.keepRunsFor > 0 && now.Sub(*r.Finished) > s.keepRunsFor) {
+				delete(s.runs, id)
+				finished--
+				continue
+			}
+		}
+		kept = append(kept, id)
+	}
+	s.order = kept
+}
+
+// activeRun returns the ID of a started or queued run which did not finish
+// yet, empty when there is none.
+func (s *server) activeRun() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range s.order {
+		if s.runs[id].Finished == nil {
+			return id
+		}
+	}
+	return ""
+}
+
+// handleRuns serves POST /runs, which triggers a conversion of the job given
+// in the optional JSON body on top of the command line defaults, and GET /runs
+// listing all runs.
+func (s *server) handleRuns(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		j := defaultJob()
+		if req.ContentLength != 0 {
+			if err := json.NewDecoder(req.Body).Decode(&j); err != nil {
+				http.Error(w, fmt.Sprintf("invalid job due: %v", err), http.StatusBadRequest)
+				return
+			}
+		}
+		if err := confineJob(j, defaultJob()); err != nil {
+			http.Error(w, fmt.Sprintf("invalid job due: %v", err), http.StatusForbidden)
+			return
+		}
+		r := s.start(j)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		writeJSON(w, http.StatusAccepted, r)
+	case http.MethodGet:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		runs := make([]*run, 0, len(s.order))
+		for _, id := range s.order {
+			runs = append(runs, s.runs[id])
+		}
+		writeJSON(w, http.StatusOK, runs)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleRun serves GET /runs/{id} and GET /runs/{id}/report.
+func (s *server) handleRun(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(req.URL.Path, "/runs/"), "/")
+	if len(parts) > 2 || (len(parts) == 2 && parts[1] != "report") {
+		http.NotFound(w, req)
+		return
+	}
+
+	s.mu.Lock()
+	r, ok := s.runs[parts[0]]
+	s.mu.Unlock()
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+
+	if len(parts) == 2 {
+		r.report.mu.Lock()
+		defer r.report.mu.Unlock()
+		writeJSON(w, http.StatusOK, r.report)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, http.StatusOK, r)
+}
+
+// handleHealth reports that the process is alive and serving requests.
+func (s *server) handleHealth(w http.ResponseWriter, req *http.Request) {
+	w.Write([]byte("ok\n"))
+}
+
+// handleReady reports ready when the last finished run succeeded and its
+// output is not older than maxAge.
+func (s *server) handleReady(w http.ResponseWriter, req *http.Request) {
+	if err := s.ready(time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok\n"))
+}
+
+func (s *server) ready(now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.order) - 1; i >= 0; i-- {
+		r := s.runs[s.order[i]]
+		if r.Finished == nil {
+			continue
+		}
+		if r.Status != runStatusSucceeded {
+			return fmt.Errorf("last run %s failed: %s", r.ID, r.Error)
+		}
+		if s.maxAge > 0 && now.Sub(*r.Finished) > s.maxAge {
+			return fmt.Errorf("last run %s finished %s ago", r.ID, now.Sub(*r.Finished).Round(time.Second))
+		}
+		return nil
+	}
+	return fmt.Errorf("no finished run")
+}
+
+// confineJob checks that a job given in a POST /runs body only reads and
+// writes below the directories of the command line job and delivers to its
+// destinations.
+func confineJob(j, defaults job) error {
+	if !withinDir(defaults.DataDir, j.DataDir) {
+		return fmt.Errorf("dataDir %s is outside of %s", j.DataDir, defaults.DataDir)
+	}
+	if !withinDir(defaults.OutputDir, j.OutputDir) {
+		return fmt.Errorf("outputDir %s is outside of %s", j.OutputDir, defaults.OutputDir)
+	}
+	channelsDir := filepath.Dir(defaults.ChannelsFile)
+	files := []string{j.ChannelsFile}
+	for _, t := range j.Tenants {
+		if t.ChannelsFile != "" {
+			files = append(files, t.ChannelsFile)
+		}
+	}
+	for _, f := range files {
+		if !withinDir(channelsDir, f) {
+			return fmt.Errorf("channels file %s is outside of %s", f, channelsDir)
+		}
+	}
+	for _, u := range j.SourceURLs {
+		if !containsString(defaults.SourceURLs, u) {
+			return fmt.Errorf("source URL %s is not configured", redactURL(u))
+		}
+	}
+	for _, d := range j.Destinations {
+		configured := false
+		for _, c := range defaults.Destinations {
+			configured = configured || c.URL == d.URL
+		}
+		if !configured {
+			return fmt.Errorf("destination %s is not configured", redactURL(d.URL))
+		}
+	}
+	return nil
+}
+
+// withinDir reports whether path is dir or below it.
+func withinDir(dir, path string) bool {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// requireToken guards the handler with the token given either as a bearer
+// token or as the password of HTTP basic authentication, which browsers ask
+// for on the web UI. /healthz and /readyz stay open for the probes.
+func requireToken(token string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/healthz" || req.URL.Path == "/readyz" {
+			h.ServeHTTP(w, req)
+			return
+		}
+		given := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if _, password, ok := req.BasicAuth(); ok {
+			given = password
+		}
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="epgtool"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
+// loopbackAddr reports whether the listen address only accepts local
+// connections.
+func loopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("unable to write response due: %v", err)
+	}
+}
+
+// serveCommand starts the HTTP API, optionally triggering a run every
+// interval:
+//
+//	epgtool -dataDir data -outputDir out serve -addr 127.0.0.1:8080 -interval 1h
+func serveCommand(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "127.0.0.1:8080", "address the HTTP API listens on, use :8080 to accept remote connections together with EPGTOOL_API_TOKEN")
+	interval := fs.Duration("interval", 0, "run the conversion periodically with the given interval, disabled when 0")
+	maxAge := fs.Duration("readyMaxAge", 0, "maximum age of the last successful run for /readyz, defaults to twice the interval")
+	search := fs.Bool("search", false, "serve /search over an in-memory full-text index of the published events")
+	redisRefresh := fs.Duration("redisRefresh", time.Minute, "interval of refreshing the Redis keys from the published files with -redisAddr")
+	grpcAddr := fs.String("grpcAddr", "", "address the gRPC EPG service listens on, e.g. 127.0.0.1:9090, disabled when empty")
+	keepRuns := fs.Int("keepRuns", 100, "number of finished runs kept for GET /runs, 0 keeps all")
+	keepRunsFor := fs.Duration("keepRunsFor", 7*24*time.Hour, "how long finished runs are kept for GET /runs, 0 keeps them regardless of their age")
+	fs.Parse(args)
+
+	if *maxAge == 0 {
+		*maxAge = 2 * *interval
+	}
+	s := newServer(*maxAge)
+	s.searchEnabled = *search
+	s.keepRuns, s.keepRunsFor = *keepRuns, *keepRunsFor
+	if *interval > 0 {
+		go func() {
+			for {
+				if id := s.activeRun(); id != "" {
+					log.Printf("Skipping the periodic run, run %s is still active\n", id)
+				} else {
+					s.start(defaultJob())
+				}
+				time.Sleep(*interval)
+			}
+		}()
+	}
+	if *redisAddr != "" && *redisRefresh > 0 {
+		go func() {
+			for {
+				time.Sleep(*redisRefresh)
+				channels, err := loadPublishedChannels(*outputDir)
+				if err == nil {
+					err = updateRedis(*redisAddr, *redisPrefix, channels, time.Now())
+				}
+				if err != nil {
+					log.Printf("unable to refresh redis due: %v", err)
+				}
+			}
+		}()
+	}
+	if *grpcAddr != "" {
+		go func() {
+			log.Fatal(serveGRPC(*grpcAddr, os.Getenv("EPGTOOL_API_TOKEN")))
+		}()
+	}
+	var handler http.Handler = s.routes()
+	if token := os.Getenv("EPGTOOL_API_TOKEN"); token != "" {
+		handler = requireToken(token, handler)
+	} else if !loopbackAddr(*addr) {
+		log.Printf("Warning: %s accepts remote connections without EPGTOOL_API_TOKEN\n", *addr)
+	}
+	log.Printf("Listening on %s\n", *addr)
+	log.Fatal(http.ListenAndServe(*addr, handler))
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPruneRuns(t *testing.T) {
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	// runs 1 to 4 finished 4 to 1 days ago, run 5 is still running.
+	newRuns := func() *server {
+		s := newServer(0)
+		for i := 1; i <= 5; i++ {
+			r := &run{ID: strconv.Itoa(i), Started: now.Add(-time.Duration(5-i) * 24 * time.Hour)}
+			if i < 5 {
+				finished := r.Started.Add(time.Hour)
+				r.Finished = &finished
+			}
+			s.runs[r.ID] = r
+			s.order = append(s.order, r.ID)
+		}
+		return s
+	}
+	tests := []struct {
+		name        string
+		keepRuns    int
+		keepRunsFor time.Duration
+		want        []string
+	}{
+		{"unlimited", 0, 0, []string{"1", "2", "3", "4", "5"}},
+		{"by count", 2, 0, []string{"3", "4", "5"}},
+		{"by age", 0, 48 * time.Hour, []string{"3", "4", "5"}},
+		{"by count and age", 3, 36 * time.Hour, []string{"4", "5"}},
+		{"last finished kept", 0, time.Minute, []string{"4", "5"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newRuns()
+			s.keepRuns, s.keepRunsFor = tt.keepRuns, tt.keepRunsFor
+			s.prune(now)
+			if !reflect.DeepEqual(s.order, tt.want) || len(s.runs) != len(tt.want) {
+				t.Errorf("kept runs %v of %d, want %v", s.order, len(s.runs), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+// sourceChannels are the source programmes grouped by source channel name.
+// Once more than limit programmes are buffered, the buffered programmes of
+// every channel are appended to a temporary spill file and read back one
+// channel at a time when it is converted, so the grouping holds at most
+// limit programmes next to the channel being converted.
+type sourceChannels struct {
+	limit    int
+	buffered map[string][]programme
+	size     int
+
+	spill    *os.File
+	end      int64
+	segments map[string][]spillSegment
+	spilled  map[string]int
+}
+
+// spillSegment is a gob encoded batch of programmes of a channel in the spill
+// file.
+type spillSegment struct {
+	offset, size int64
+}
+
+// newSourceChannels returns an empty grouping spilling above limit buffered
+// programmes, never when limit is 0.
+func newSourceChannels(limit int) *sourceChannels {
+	return &sourceChannels{limit: limit, buffered: make(map[string][]programme), segments: make(map[string][]spillSegment),
+		spilled: make(map[string]int)}
+}
+
+// add appends the programme to the events of its channel.
+func (s *sourceChannels) add(e programme) error {
+	s.buffered[e.ChannelName] = append(s.buffered[e.ChannelName], e)
+	s.size++
+	if s.limit > 0 && s.size > s.limit {
+		return s.spillBuffered()
+	}
+	return nil
+}
+
+// spillBuffered appends the buffered programmes to the spill file.
+func (s *sourceChannels) spillBuffered() error {
+	if s.spill == nil {
+		f, err := ioutil.TempFile("", "epgtool-spill-*")
+		if err != nil {
+			return fmt.Errorf("unable to create spill file due: %v", err)
+		}
+		s.spill = f
+	}
+	var buf bytes.Buffer
+	for name, events := range s.buffered {
+		buf.Reset()
+		if err := gob.NewEncoder(&buf).Encode(events); err != nil {
+			return fmt.Errorf("unable to spill the events of channel '%s' due: %v", name, err)
+		}
+		if _, err := s.spill.WriteAt(buf.Bytes(), s.end); err != nil {
+			return fmt.Errorf("unable to write spill file due: %v", err)
+		}
+		s.segments[name] = append(s.segments[name], spillSegment{s.end, int64(buf.Len())})
+		s.spilled[name] += len(events)
+		s.end += int64(buf.Len())
+	}
+	s.buffered = make(map[string][]programme)
+	s.size = 0
+	return nil
+}
+
+// names returns the names of the channels with events in order.
+func (s *sourceChannels) names() []string {
+	if s == nil {
+		return nil
+	}
+	var names []string
+	for name := range s.buffered {
+		names = append(names, name)
+	}
+	for name := range s.spilled {
+		if _, ok := s.buffered[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// has reports whether the channel has events.
+func (s *sourceChannels) has(name string) bool {
+	return s.count(name) > 0
+}
+
+// count returns the number of events of the channel.
+func (s *sourceChannels) count(name string) int {
+	if s == nil {
+		return 0
+	}
+	return s.spilled[name] + len(s.buffered[name])
+}
+
+// len returns the number of channels with events.
+func (s *sourceChannels) len() int {
+	return len(s.names())
+}
+
+// events returns the events of the channel in the order they were added,
+// reading the spilled ones back.
+func (s *sourceChannels) events(name string) ([]programme, error) {
+	if s == nil {
+		return nil, nil
+	}
+	if len(s.segments[name]) == 0 {
+		return s.buffered[name], nil
+	}
+	result := make([]programme, 0, s.count(name))
+	for _, seg := range s.segments[name] {
+		var events []programme
+		if err := gob.NewDecoder(io.NewSectionReader(s.spill, seg.offset, seg.size)).Decode(&events); err != nil {
+			return nil, fmt.Errorf("unable to read spilled events of channel '%s' due: %v", name, err)
+		}
+		result = append(result, events...)
+	}
+	return append(result, s.buffered[name]...), nil
+}
+
+// close removes the spill file.
+func (s *sourceChannels) close() {
+	if s != nil && s.spill != nil {
+		s.spill.Close()
+		os.Remove(s.spill.Name())
+		s.spill = nil
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSourceChannelsSpill(t *testing.T) {
+	for _, limit := range []int{0, 1, 3, 100} {
+		t.Run(fmt.Sprint(limit), func(t *testing.T) {
+			s := newSourceChannels(limit)
+			defer s.close()
+			var want []string
+			for i := 0; i < 20; i++ {
+				name := []string{"a", "b", "c"}[i%3]
+				e := programme{ChannelName: name, Start: fmt.Sprint(i), SourceFile: "f", Provider: "p"}
+				if err := s.add(e); err != nil {
+					t.Fatal(err)
+				}
+				if limit > 0 && s.size > limit {
+					t.Fatalf("holds %d programmes, want at most %d", s.size, limit)
+				}
+				if name == "b" {
+					want = append(want, e.Start)
+				}
+			}
+			if got := strings.Join(s.names(), ","); got != "a,b,c" {
+				t.Errorf("got names %s", got)
+			}
+			if s.count("b") != 7 || !s.has("b") || s.has("d") {
+				t.Errorf("got count %d", s.count("b"))
+			}
+			events, err := s.events("b")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, e := range events {
+				if e.SourceFile != "f" || e.Provider != "p" {
+					t.Errorf("lost the source of %+v", e)
+				}
+				got = append(got, e.Start)
+			}
+			if strings.Join(got, ",") != strings.Join(want, ",") {
+				t.Errorf("got events %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+// largeSource returns an XMLTV source of the channels with events programmes
+// each, the programmes of the channels interleaved as in a real grab.
+func TestConvertLargeSourceSpilled(t *testing.T) {
+	defer func(v int) { *spillEvents = v }(*spillEvents)
+	const channels, events = 40, 1000
+	var lines []string
+	for c := 0; c < channels; c++ {
+		lines = append(lines, fmt.Sprintf("%d,\"Channel %d\"", c+1, c))
+	}
+	source := benchmarkSource(channels, events)
+
+	convert := func(limit int) map[string]string {
+		*spillEvents = limit
+		store := useMemoryStore(t)
+		writeMemoryFile(t, store, filepath.Join("data", "source.xml"), source)
+		writeMemoryFile(t, store, "channels.csv", strings.Join(lines, "\n")+"\n")
+		j := job{DataDir: "data", SourceFileLimit: 1, ChannelsFile: "channels.csv", OutputDir: "out"}
+		summary, err := j.convert(context.Background(), nil, newRunReport(ioutil.Discard), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := summary.Tenants[0].Events; got != channels*events {
+			t.Errorf("converted %d events, want %d", got, channels*events)
+		}
+		files := make(map[string]string)
+		names, _ := store.List("out")
+		for _, name := range names {
+			if filepath.Base(name) != manifestFileName {
+				data, _ := store.ReadFile(name)
+				files[name] = string(data)
+			}
+		}
+		return files
+	}
+	inMemory, spilled := convert(0), convert(channels*events/10)
+	if len(spilled) != channels {
+		t.Fatalf("wrote %d files, want %d", len(spilled), channels)
+	}
+	for name, data := range inMemory {
+		if spilled[name] != data {
+			t.Errorf("%s differs when the events are spilled", name)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// sportsInfo is the structured metadata of a sports event extracted from its
+// title.
+type sportsInfo struct {
+	Sport       string `xml:"sport,omitempty" json:"sport,omitempty"`
+	Home        string `xml:"home,omitempty" json:"home,omitempty"`
+	Away        string `xml:"away,omitempty" json:"away,omitempty"`
+	Competition string `xml:"competition,omitempty" json:"competition,omitempty"`
+	Round       string `xml:"round,omitempty" json:"round,omitempty"`
+}
+
+var (
+	// matchSeparator splits the two sides of a match, e.g "Levski – CSKA".
+	matchSeparator = regexp.MustCompile(`(?i)\s+(?:[-–—]|vs\.?|v|срещу)\s+`)
+	// roundPattern matches a round or stage of a competition.
+	roundPattern = regexp.MustCompile(`(?i)(?:^|\s|-)(?:round|matchday|week|leg|group|stage|(?:semi-|quarter-)?finals?|кръг|група|етап|(?:полу|четвърт)?финал)(?:\s|$)`)
+	// liveMarker matches the live markers providers add to titles.
+	liveMarker = regexp.MustCompile(`(?i)\s*[(\[]\s*(?:live|на живо)\s*[)\]]|^(?:live|на живо)\s*[:-]?\s+|\s+(?:live|на живо)$`)
+)
+
+// parseSportsTitle extracts the sport, the teams, the competition and the
+// round from titles such as "Football: Levski – CSKA, First League, Round 12".
+// It returns nil for titles not describing a match between two sides.
+func parseSportsTitle(name string) *sportsInfo {
+	name = strings.TrimSpace(liveMarker.ReplaceAllString(name, " "))
+	info := &sportsInfo{}
+	if i := strings.Index(name, ":"); i > 0 && len(strings.Fields(name[:i])) <= 3 {
+		info.Sport = strings.TrimSpace(name[:i])
+		name = strings.TrimSpace(name[i+1:])
+	}
+
+	var details string
+	if open := strings.Index(name, "("); open > 0 && strings.HasSuffix(name, ")") {
+		name, details = strings.TrimSpace(name[:open]), name[open+1:len(name)-1]
+	}
+	segments := strings.Split(name, ",")
+	if details != "" {
+		segments = append(segments, strings.Split(details, ",")...)
+	}
+	match := strings.TrimSpace(segments[0])
+	sides := matchSeparator.Split(match, 2)
+	if len(sides) != 2 || !isTeamName(sides[0]) || !isTeamName(sides[1]) {
+		return nil
+	}
+	info.Home, info.Away = strings.TrimSpace(sides[0]), strings.TrimSpace(sides[1])
+
+	for _, s := range segments[1:] {
+		s = strings.TrimSpace(s)
+		switch {
+		case s == "":
+		case info.Round == "" && roundPattern.MatchString(s):
+			info.Round = s
+		case info.Competition == "":
+			info.Competition = s
+		}
+	}
+	return info
+}
+
+// isTeamName reports whether s can be the name of a team or an athlete:
+// not empty and no more than four words.
+func isTeamName(s string) bool {
+	n := len(strings.Fields(s))
+	return n > 0 && n <= 4
+}
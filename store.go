@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mgenov/epgtool/store"
+)
+
+// sourceFiles and outputFiles are the stores the sources are read from and
+// the output is written to, the local file system unless replaced, e.g by
+// a store.Memory.
+var (
+	sourceFiles store.SourceStore = store.Local{}
+	outputFiles store.OutputStore = store.Local{}
+)
+
+// dataFiles returns the source store for writing into the data directory,
+// the downloaded sources, the quarantined ones and the generated channel IDs
+// written back into the channels file.
+func dataFiles() (store.OutputStore, error) {
+	s, ok := sourceFiles.(store.OutputStore)
+	if !ok {
+		return nil, fmt.Errorf("the source store is read only")
+	}
+	return s, nil
+}
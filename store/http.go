@@ -0,0 +1,202 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// HTTP keeps the files below the URL of a web server, reading them with GET
+// and writing them with PUT and DELETE, as WebDAV servers accept them. The
+// user of the URL authenticates with basic authentication. List follows the
+// links of the directory index pages generated by the server, e.g by the
+// autoindex of nginx.
+type HTTP struct {
+	URL *url.URL
+	// Client sends the requests, http.DefaultClient when nil.
+	Client *http.Client
+}
+
+// NewHTTP returns the store of the files below the http(s) URL.
+func NewHTTP(rawURL string) (*HTTP, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported store URL '%s', expected http(s)://", u.Redacted())
+	}
+	return &HTTP{URL: u}, nil
+}
+
+// fileURL returns the URL of the file, ending with a slash for a directory.
+func (s *HTTP) fileURL(name string, dir bool) *url.URL {
+	u := *s.URL
+	u.User = nil
+	u.Path = path.Join("/", s.URL.Path, filepath.ToSlash(name))
+	if dir && !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	return &u
+}
+
+// request returns a request for the file, authenticated with the user of
+// the store URL.
+func (s *HTTP) request(method string, u *url.URL, body []byte) (*http.Request, error) {
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if s.URL.User != nil {
+		password, _ := s.URL.User.Password()
+		req.SetBasicAuth(s.URL.User.Username(), password)
+	}
+	return req, nil
+}
+
+// indexLink matches the links of a directory index page.
+var indexLink = regexp.MustCompile(`(?i)href\s*=\s*["']([^"'#?]+)`)
+
+func (s *HTTP) List(dir string) ([]string, error) {
+	var files []string
+	pending := []string{dir}
+	visited := make(map[string]bool)
+	for len(pending) > 0 {
+		d := pending[0]
+		pending = pending[1:]
+		base := s.fileURL(d, true)
+		if visited[base.Path] {
+			continue
+		}
+		visited[base.Path] = true
+
+		req, err := s.request(http.MethodGet, base, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := send(s.Client, req, "open", d)
+		if err != nil {
+			return nil, err
+		}
+		page, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range indexLink.FindAllSubmatch(page, -1) {
+			ref, err := url.Parse(string(m[1]))
+			if err != nil {
+				continue
+			}
+			link := base.ResolveReference(ref)
+			rel := strings.TrimPrefix(link.Path, base.Path)
+			if link.Host != base.Host || rel == link.Path || rel == "" || strings.HasPrefix(rel, ".") {
+				continue
+			}
+			name := filepath.Join(d, filepath.FromSlash(strings.TrimSuffix(rel, "/")))
+			if strings.HasSuffix(rel, "/") {
+				pending = append(pending, name)
+			} else {
+				files = append(files, name)
+			}
+		}
+	}
+	return files, nil
+}
+
+func (s *HTTP) Open(name string) (io.ReadCloser, error) {
+	req, err := s.request(http.MethodGet, s.fileURL(name, false), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := send(s.Client, req, "open", name)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// MkdirAll does nothing, the server creates the directories of the files
+// put into them.
+func (s *HTTP) MkdirAll(dir string) error {
+	return nil
+}
+
+func (s *HTTP) ReadFile(name string) ([]byte, error) {
+	return readAll(s.Open(name))
+}
+
+func (s *HTTP) Remove(name string) error {
+	req, err := s.request(http.MethodDelete, s.fileURL(name, false), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := send(s.Client, req, "remove", name)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Write uploads the file with a single PUT request, which the server stores
+// at once.
+func (s *HTTP) Write(name string, write func(w io.Writer) error) error {
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return err
+	}
+	req, err := s.request(http.MethodPut, s.fileURL(name, false), buf.Bytes())
+	if err != nil {
+		return err
+	}
+	setContentType(req, name)
+	resp, err := send(s.Client, req, "write", name)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// send sends the request, returning an error for which os.IsNotExist reports
+// true when the server does not have the file and an error for any other
+// unsuccessful status.
+func send(client *http.Client, req *http.Request, op, name string) (*http.Response, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		return nil, notExist(op, name)
+	case resp.StatusCode >= 300:
+		resp.Body.Close()
+		return nil, fmt.Errorf("unable to %s '%s' due: unexpected status %s", op, name, resp.Status)
+	}
+	return resp, nil
+}
+
+func setContentType(req *http.Request, name string) {
+	if ct := mime.TypeByExtension(path.Ext(name)); ct != "" {
+		req.Header.Set("Content-Type", ct)
+	}
+}
+
+func readAll(r io.ReadCloser, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
@@ -0,0 +1,26 @@
+package store
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHTTP(t *testing.T) {
+	s, srv := newObjectServer(t)
+	files, err := NewHTTP(strings.Replace(srv.URL, "://", "://epg:secret@", 1) + "/webdav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, files)
+	if _, ok := s.objects["/webdav/data/epg/sub/a.xml"]; !ok {
+		t.Errorf("got objects %v", s.paths(""))
+	}
+	for _, req := range s.requests {
+		if user, password, _ := req.BasicAuth(); user != "epg" || password != "secret" {
+			t.Errorf("got request %s %s as %s:%s", req.Method, req.URL, user, password)
+		}
+	}
+	if _, err := NewHTTP("ftp://example.com/epg"); err == nil {
+		t.Error("accepted an ftp:// URL")
+	}
+}
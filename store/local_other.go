@@ -0,0 +1,10 @@
+//go:build !windows
+// +build !windows
+
+package store
+
+const renameAttempts = 1
+
+func longPath(p string) string {
+	return p
+}
@@ -0,0 +1,27 @@
+package store
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// renameAttempts covers readers and virus scanners holding the replaced
+// file open for a moment.
+const renameAttempts = 5
+
+// longPath returns the extended-length form of an absolute path, \\?\C:\...
+// or \\?\UNC\server\share\..., so paths longer than MAX_PATH can be written
+// also on systems where long paths are not enabled.
+func longPath(p string) string {
+	if len(p) < 248 || strings.HasPrefix(p, `\\?\`) {
+		return p
+	}
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return p
+	}
+	if strings.HasPrefix(abs, `\\`) {
+		return `\\?\UNC\` + abs[2:]
+	}
+	return `\\?\` + abs
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLongPath(t *testing.T) {
+	long := strings.Repeat(`segment\`, 32) + "n_events_1.xml"
+	relative, err := filepath.Abs(long)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"short drive path", `C:\epg\out\n_events_1.xml`, `C:\epg\out\n_events_1.xml`},
+		{"short share path", `\\server\share\out\n_events_1.xml`, `\\server\share\out\n_events_1.xml`},
+		{"long drive path", `C:\epg\` + long, `\\?\C:\epg\` + long},
+		{"long share path", `\\server\share\` + long, `\\?\UNC\server\share\` + long},
+		{"long relative path", long, `\\?\` + relative},
+		{"long cleaned path", `C:\epg\.\out\..\` + long, `\\?\C:\epg\` + long},
+		{"extended drive path", `\\?\C:\epg\` + long, `\\?\C:\epg\` + long},
+		{"extended share path", `\\?\UNC\server\share\` + long, `\\?\UNC\server\share\` + long},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := longPath(tt.path); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalLongPath(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), strings.Repeat("a", 100), strings.Repeat("b", 100), strings.Repeat("c", 100))
+	fileName := filepath.Join(dir, "n_events_1.xml")
+	if len(fileName) < 260 {
+		t.Fatalf("path %s is not longer than MAX_PATH", fileName)
+	}
+	for i := 0; i < 2; i++ {
+		if err := (Local{}).MkdirAll(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, content := range []string{"first", "second"} {
+		if err := (Local{}).Write(fileName, func(w io.Writer) error { _, err := io.WriteString(w, content); return err }); err != nil {
+			t.Fatal(err)
+		}
+		if data, err := ioutil.ReadFile(longPath(fileName)); err != nil || string(data) != content {
+			t.Fatalf("read %q, %v", data, err)
+		}
+	}
+	if files, err := ioutil.ReadDir(longPath(dir)); err != nil || len(files) != 1 {
+		t.Errorf("got files %v, %v", files, err)
+	}
+}
+
+func TestRenameFileOverOpenFile(t *testing.T) {
+	dir := t.TempDir()
+	from, to := filepath.Join(dir, "new.xml"), filepath.Join(dir, "n_events_1.xml")
+	for _, f := range []string{from, to} {
+		if err := ioutil.WriteFile(f, []byte(filepath.Base(f)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reader, err := os.Open(to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() { done <- renameFile(from, to) }()
+	// Replacing the file fails while it is open, renameFile retries until the
+	// reader closed it.
+	reader.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if data, err := ioutil.ReadFile(to); err != nil || string(data) != "new.xml" {
+		t.Errorf("read %q, %v", data, err)
+	}
+}
@@ -0,0 +1,83 @@
+package store
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Memory keeps the files in memory, for embedding the conversion without a
+// file system and in tests.
+type Memory struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func NewMemory() *Memory {
+	return &Memory{files: make(map[string][]byte)}
+}
+
+func (s *Memory) List(dir string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prefix := filepath.Clean(dir) + string(filepath.Separator)
+	var files []string
+	for name := range s.files {
+		if strings.HasPrefix(name, prefix) {
+			files = append(files, name)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func (s *Memory) Open(name string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.files[filepath.Clean(name)]
+	if !ok {
+		return nil, notExist("open", name)
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+// MkdirAll does nothing, the directories of a Memory store are implied by
+// the names of its files.
+func (s *Memory) MkdirAll(dir string) error {
+	return nil
+}
+
+func (s *Memory) ReadFile(name string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.files[filepath.Clean(name)]
+	if !ok {
+		return nil, notExist("open", name)
+	}
+	return append([]byte(nil), data...), nil
+}
+
+func (s *Memory) Remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.files[filepath.Clean(name)]; !ok {
+		return notExist("remove", name)
+	}
+	delete(s.files, filepath.Clean(name))
+	return nil
+}
+
+func (s *Memory) Write(name string, write func(w io.Writer) error) error {
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[filepath.Clean(name)] = buf.Bytes()
+	return nil
+}
@@ -0,0 +1,80 @@
+package store
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, s OutputStore, name, content string) {
+	if err := s.Write(name, func(w io.Writer) error { _, err := io.WriteString(w, content); return err }); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testStore writes, lists, reads and removes files of the store.
+func testStore(t *testing.T, s Store) {
+	dir := filepath.Join("data", "epg")
+	writeFile(t, s, filepath.Join(dir, "b.xml"), "b")
+	writeFile(t, s, filepath.Join(dir, "sub", "a.xml"), "a")
+	writeFile(t, s, filepath.Join("data", "epg2", "c.xml"), "c")
+
+	files, err := s.List(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "b.xml"), filepath.Join(dir, "sub", "a.xml")}
+	if strings.Join(files, "|") != strings.Join(want, "|") {
+		t.Errorf("got files %v, want %v", files, want)
+	}
+
+	f, err := s.Open(filepath.Join(dir, ".", "b.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadAll(f)
+	f.Close()
+	if string(data) != "b" {
+		t.Errorf("got %q", data)
+	}
+
+	if err := s.Remove(filepath.Join(dir, "b.xml")); err != nil {
+		t.Fatal(err)
+	}
+	for _, err := range []error{
+		func() error { _, err := s.Open(filepath.Join(dir, "b.xml")); return err }(),
+		func() error { _, err := s.ReadFile(filepath.Join(dir, "b.xml")); return err }(),
+	} {
+		if !os.IsNotExist(err) {
+			t.Errorf("got error %v, want a not exist error", err)
+		}
+	}
+}
+
+func TestMemory(t *testing.T) {
+	s := NewMemory()
+	testStore(t, s)
+	if err := s.Remove(filepath.Join("data", "epg", "b.xml")); !os.IsNotExist(err) {
+		t.Errorf("got error %v, want a not exist error", err)
+	}
+}
+
+func TestLocal(t *testing.T) {
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(dir)
+	for _, d := range []string{filepath.Join("data", "epg", "sub"), filepath.Join("data", "epg2")} {
+		if err := (Local{}).MkdirAll(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	testStore(t, Local{})
+}
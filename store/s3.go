@@ -0,0 +1,232 @@
+package store
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// S3 keeps the files in an S3 bucket below Prefix, signing the requests with
+// AWS Signature Version 4.
+type S3 struct {
+	Bucket string
+	Prefix string
+	// Region is the region of the bucket. Endpoint is the URL of an S3
+	// compatible service addressed with path style URLs, empty for AWS.
+	Region   string
+	Endpoint string
+
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	// Client sends the requests, http.DefaultClient when nil.
+	Client *http.Client
+}
+
+// NewS3 returns the store of the bucket using the credentials, region and
+// endpoint of the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY,
+// AWS_SESSION_TOKEN, AWS_REGION and AWS_ENDPOINT_URL environment variables.
+// The region is us-east-1 when it is not set.
+func NewS3(bucket, prefix string) (*S3, error) {
+	s := &S3{
+		Bucket:       bucket,
+		Prefix:       prefix,
+		Region:       os.Getenv("AWS_REGION"),
+		Endpoint:     os.Getenv("AWS_ENDPOINT_URL"),
+		AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if s.Region == "" {
+		s.Region = "us-east-1"
+	}
+	if s.AccessKey == "" || s.SecretKey == "" {
+		return nil, fmt.Errorf("missing AWS_ACCESS_KEY_ID or AWS_SECRET_ACCESS_KEY")
+	}
+	return s, nil
+}
+
+// ObjectURL returns the URL of the object with the key in the bucket.
+func (s *S3) ObjectURL(key string) (*url.URL, error) {
+	endpoint := "https://" + s.Bucket + ".s3." + s.Region + ".amazonaws.com/" + key
+	if s.Endpoint != "" {
+		endpoint = strings.TrimSuffix(s.Endpoint, "/") + "/" + s.Bucket + "/" + key
+	}
+	return url.Parse(endpoint)
+}
+
+// Sign adds the AWS Signature Version 4 of the request with the body.
+func (s *S3) Sign(req *http.Request, body []byte) {
+	sign(req, body, s.AccessKey, s.SecretKey, s.SessionToken, s.Region, time.Now().UTC())
+}
+
+// key returns the key of the file in the bucket.
+func (s *S3) key(name string) string {
+	return strings.TrimPrefix(path.Join(s.Prefix, filepath.ToSlash(name)), "/")
+}
+
+// request returns a signed request for the object with the key.
+func (s *S3) request(method, key, query string, body []byte) (*http.Request, error) {
+	u, err := s.ObjectURL(key)
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = query
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	s.Sign(req, body)
+	return req, nil
+}
+
+// List lists the objects below the directory with ListObjectsV2, a page of
+// up to 1000 objects at a time.
+func (s *S3) List(dir string) ([]string, error) {
+	prefix := s.key(dir) + "/"
+	if prefix == "/" {
+		prefix = ""
+	}
+	var files []string
+	var token string
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		// Signature Version 4 encodes spaces as %20.
+		req, err := s.request(http.MethodGet, "", strings.ReplaceAll(query.Encode(), "+", "%20"), nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := send(s.Client, req, "open", dir)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Contents []struct {
+				Key string
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("unable to list '%s' due: %v", dir, err)
+		}
+		for _, c := range page.Contents {
+			files = append(files, filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(c.Key, prefix))))
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return files, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+func (s *S3) Open(name string) (io.ReadCloser, error) {
+	req, err := s.request(http.MethodGet, s.key(name), "", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := send(s.Client, req, "open", name)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// MkdirAll does nothing, the directories of a bucket are implied by the keys
+// of its objects.
+func (s *S3) MkdirAll(dir string) error {
+	return nil
+}
+
+func (s *S3) ReadFile(name string) ([]byte, error) {
+	return readAll(s.Open(name))
+}
+
+// Remove deletes the object. S3 does not report deleting a missing object.
+func (s *S3) Remove(name string) error {
+	req, err := s.request(http.MethodDelete, s.key(name), "", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := send(s.Client, req, "remove", name)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Write uploads the object with a single PUT request, which S3 stores at
+// once.
+func (s *S3) Write(name string, write func(w io.Writer) error) error {
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return err
+	}
+	req, err := s.request(http.MethodPut, s.key(name), "", buf.Bytes())
+	if err != nil {
+		return err
+	}
+	setContentType(req, name)
+	resp, err := send(s.Client, req, "write", name)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func sign(req *http.Request, body []byte, accessKey, secretKey, token, region string, now time.Time) {
+	payload := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payload[:]))
+	if token != "" {
+		req.Header.Set("X-Amz-Security-Token", token)
+	}
+
+	headers := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	values := map[string]string{"host": req.URL.Host}
+	if token != "" {
+		headers = append(headers, "x-amz-security-token")
+	}
+	var canonicalHeaders strings.Builder
+	for _, h := range headers {
+		v, ok := values[h]
+		if !ok {
+			v = req.Header.Get(h)
+		}
+		canonicalHeaders.WriteString(h + ":" + strings.TrimSpace(v) + "\n")
+	}
+	signedHeaders := strings.Join(headers, ";")
+	canonicalRequest := strings.Join([]string{req.Method, req.URL.EscapedPath(), req.URL.RawQuery,
+		canonicalHeaders.String(), signedHeaders, hex.EncodeToString(payload[:])}, "\n")
+
+	date := now.Format("20060102")
+	scope := date + "/" + region + "/s3/aws4_request"
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + now.Format("20060102T150405Z") + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+
+	mac := func(key []byte, data string) []byte {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(data))
+		return h.Sum(nil)
+	}
+	signingKey := mac(mac(mac(mac([]byte("AWS4"+secretKey), date), region), "s3"), "aws4_request")
+	signature := hex.EncodeToString(mac(signingKey, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
@@ -0,0 +1,129 @@
+// Package store holds the file stores epgtool reads its sources from and
+// writes its output to: the local file system, memory, S3 and HTTP. Embedders
+// supply their own backends by implementing SourceStore and OutputStore.
+//
+// Files are named by slash or OS separated paths like on the local file
+// system, e.g "data/CMS-guide.xml", which the remote stores map to the keys or
+// URLs below their root.
+package store
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SourceStore lists and opens the source files.
+type SourceStore interface {
+	// List returns the paths of all files below the directory.
+	List(dir string) ([]string, error)
+	// Open opens the file for reading, a missing file is an error for which
+	// os.IsNotExist reports true.
+	Open(name string) (io.ReadCloser, error)
+}
+
+// OutputStore keeps the output files. Write replaces a file at once so
+// readers never see it partly written.
+type OutputStore interface {
+	MkdirAll(dir string) error
+	Write(name string, write func(w io.Writer) error) error
+	ReadFile(name string) ([]byte, error)
+	Remove(name string) error
+}
+
+// Store is a store files are both read from and written to, as the data
+// directory is by downloads and quarantined source files.
+type Store interface {
+	SourceStore
+	OutputStore
+}
+
+// notExist returns the error of a missing file, for which os.IsNotExist
+// reports true.
+func notExist(op, name string) error {
+	return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+}
+
+// Local keeps the files in the local file system.
+type Local struct{}
+
+func (Local) List(dir string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+func (Local) Open(name string) (io.ReadCloser, error) {
+	return os.Open(longPath(name))
+}
+
+// MkdirAll creates the directory with its parents. A directory which exists
+// already is not an error, even when creating it fails, as it does for the
+// root of a network share.
+func (Local) MkdirAll(dir string) error {
+	err := os.MkdirAll(longPath(dir), os.ModePerm)
+	if err != nil {
+		if info, statErr := os.Stat(longPath(dir)); statErr == nil && info.IsDir() {
+			return nil
+		}
+	}
+	return err
+}
+
+func (Local) ReadFile(name string) ([]byte, error) {
+	return ioutil.ReadFile(longPath(name))
+}
+
+func (Local) Remove(name string) error {
+	return os.Remove(longPath(name))
+}
+
+// Write writes the file into a temporary file in the same directory which
+// is then renamed over it.
+func (Local) Write(fileName string, write func(w io.Writer) error) error {
+	fileName = longPath(fileName)
+	tmp, err := ioutil.TempFile(filepath.Dir(fileName), "."+filepath.Base(fileName)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("unable to open output file due: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("unable to write output file due: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("unable to write output file due: %v", err)
+	}
+	return renameFile(tmp.Name(), fileName)
+}
+
+// renameFile renames from to to, retrying where renaming over a file which
+// is open, e.g by a reader of a Windows share, fails until it is closed.
+func renameFile(from, to string) error {
+	var err error
+	for attempt := 1; attempt <= renameAttempts; attempt++ {
+		if err = os.Rename(from, to); err == nil {
+			return nil
+		}
+		if attempt < renameAttempts {
+			time.Sleep(time.Duration(attempt) * 100 * time.Millisecond)
+		}
+	}
+	return fmt.Errorf("unable to replace output file due: %v", err)
+}
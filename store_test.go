@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mgenov/epgtool/store"
+)
+
+// useMemoryStore replaces the source and output stores with a store.Memory
+// for the test.
+func useMemoryStore(t *testing.T) *store.Memory {
+	files := store.NewMemory()
+	prevSources, prevOutputs := sourceFiles, outputFiles
+	sourceFiles, outputFiles = files, files
+	t.Cleanup(func() { sourceFiles, outputFiles = prevSources, prevOutputs })
+	return files
+}
+
+func writeMemoryFile(t *testing.T, files *store.Memory, name, content string) {
+	if err := files.Write(name, func(w io.Writer) error { _, err := io.WriteString(w, content); return err }); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConvertInMemory(t *testing.T) {
+	j, store := memoryJob(t)
+	writeMemoryFile(t, store, j.ChannelsFile, "151,\"Nickelodeon\"\n409,\"Animal Planet\"\n")
+
+	summary, err := j.convert(context.Background(), nil, newRunReport(ioutil.Discard), &runDeadline{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := summary.Tenants[0].WrittenFiles; got != 2 {
+		t.Errorf("wrote %d files, want 2", got)
+	}
+	files, _ := store.List("out")
+	if len(files) != 3 {
+		t.Fatalf("got output files %v, want two channels and the manifest", files)
+	}
+	if _, err := os.Stat("out"); !os.IsNotExist(err) {
+		t.Errorf("output was written to the file system")
+	}
+
+	m, err := readManifest("out")
+	if err != nil || m == nil {
+		t.Fatalf("got manifest %v, error %v", m, err)
+	}
+	for _, id := range []string{"151", "409"} {
+		c, err := readPublishedChannel(filepath.Join("out", m.Channels[id].File))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.ID != id || len(c.Events.Values) == 0 {
+			t.Errorf("got channel %s with %d events", c.ID, len(c.Events.Values))
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// sourceBatch is the programmes of one source file passed from the parsing to
+// the grouping stage, or the error which stopped the parsing.
+type sourceBatch struct {
+	programmes []programme
+	err        error
+}
+
+// streamSources parses the source files one at a time, skipping files with
+// the same content as an already read one so their events are not processed
+// twice. The channel holds a single file, so the parser waits for the
+// grouping stage instead of keeping every parsed file in memory. When
+// quarantine is enabled, files failing to be read are moved into the
+// quarantine directory and skipped instead of failing the run. Cancelling ctx
+// stops the parser after the file being parsed.
+func streamSources(ctx context.Context, files []string, cache *sourceCache, quarantineDir string,
+	w sourceWindow) <-chan sourceBatch {
+	out := make(chan sourceBatch, 1)
+	go func() {
+		defer close(out)
+		send := func(b sourceBatch) bool {
+			select {
+			case out <- b:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		hashes := make(map[string]string)
+		bar := newProgress("parsing source files", len(files))
+		defer bar.finish()
+		for _, fname := range files {
+			if ctx.Err() != nil {
+				return
+			}
+			bar.add(1)
+			hash, err := fileHash(fname)
+			if err != nil {
+				send(sourceBatch{err: fmt.Errorf("unable to read source file '%s' due: %v", fname, err)})
+				return
+			}
+			if first, ok := hashes[hash]; ok {
+				log.Printf("skipping source file '%s', its content is identical to '%s'", fname, first)
+				continue
+			}
+			hashes[hash] = fname
+
+			s, err := cache.get(fname, w)
+			if err != nil && *quarantineMode != "off" {
+				log.Print(err)
+				if err := quarantineSource(quarantineDir, fname, err); err != nil {
+					send(sourceBatch{err: err})
+					return
+				}
+				continue
+			}
+			if err != nil {
+				send(sourceBatch{err: err})
+				return
+			}
+			if !send(sourceBatch{programmes: s.ProgramList}) {
+				return
+			}
+		}
+	}()
+	return out
+}
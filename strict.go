@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// unknownElement captures the name of an element which is not mapped by
+// the source structs. The content of the element is ignored.
+type unknownElement struct {
+	XMLName xml.Name
+}
+
+// unknownElements collects the elements not mapped by the source structs.
+// They are only kept with -strictParse, otherwise they are skipped while
+// decoding.
+type unknownElements []unknownElement
+
+func (u *unknownElements) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	if *strictParse != "off" {
+		*u = append(*u, unknownElement{XMLName: start.Name})
+	}
+	return d.Skip()
+}
+
+// unknownAttrs collects the attributes not mapped by the source structs,
+// like unknownElements only with -strictParse.
+type unknownAttrs []xml.Attr
+
+func (u *unknownAttrs) UnmarshalXMLAttr(attr xml.Attr) error {
+	if *strictParse != "off" {
+		*u = append(*u, attr)
+	}
+	return nil
+}
+
+// unknownFields returns a description of every element and attribute of the
+// source that is not part of the XMLTV DTD, e.g "element programme/extra (x12)".
+// Elements and attributes of the DTD which are not mapped, like the icon or the
+// rating of a programme, are not reported.
+func unknownFields(s *source) []string {
+	counts := make(map[string]int)
+	addElements := func(parent string, elements unknownElements) {
+		element := parent[strings.LastIndex(parent, "/")+1:]
+		for _, e := range elements {
+			if !dtdChild(element, e.XMLName.Local) {
+				counts[fmt.Sprintf("element %s/%s", parent, e.XMLName.Local)]++
+			}
+		}
+	}
+	addAttrs := func(parent string, attrs unknownAttrs) {
+		for _, a := range attrs {
+			if _, ok := xmltvDTD[parent].attrs[a.Name.Local]; !ok || a.Name.Space != "" {
+				counts[fmt.Sprintf("attribute %s@%s", parent, a.Name.Local)]++
+			}
+		}
+	}
+
+	addElements("tv", s.Unknown)
+	addAttrs("tv", s.UnknownAttrs)
+	for _, c := range s.ChannelList {
+		addElements("channel", c.Unknown)
+		addAttrs("channel", c.UnknownAttrs)
+	}
+	for _, p := range s.ProgramList {
+		addElements("programme", p.Unknown)
+		addAttrs("programme", p.UnknownAttrs)
+		addElements("programme/credits", p.Credits.Unknown)
+	}
+
+	result := make([]string, 0, len(counts))
+	for k, v := range counts {
+		result = append(result, fmt.Sprintf("%s (x%d)", k, v))
+	}
+	sort.Strings(result)
+	return result
+}
+
+// dtdChild reports whether the XMLTV DTD allows the child element in parent.
+func dtdChild(parent, child string) bool {
+	for _, p := range xmltvDTD[parent].children {
+		if p.name == child {
+			return true
+		}
+	}
+	return false
+}
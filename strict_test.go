@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/xml"
+	"reflect"
+	"testing"
+)
+
+func TestUnknownFields(t *testing.T) {
+	const doc = `<tv generator-info-name="g" generator-info-url="u" extra="x">
+<channel id="1" foo="bar"><display-name>One</display-name><icon src="a.png"/><logo/></channel>
+<programme start="20240101180000 +0000" channel="1" clumpidx="0/1" weight="2">
+<title>News</title><sub-title>Late</sub-title><icon src="b.png"/><rating><value>12</value></rating>
+<star-rating><value>3/5</value></star-rating><premiere/><season>1</season>
+<credits><director>D</director><writer>W</writer><stuntman>S</stuntman></credits>
+</programme>
+<programme start="20240101190000 +0000" channel="1"><title>Film</title><season>2</season></programme>
+<extension/>
+</tv>`
+	tests := []struct {
+		strict string
+		want   []string
+	}{
+		{"off", []string{}},
+		{"warn", []string{
+			"attribute channel@foo (x1)",
+			"attribute programme@weight (x1)",
+			"attribute tv@extra (x1)",
+			"element channel/logo (x1)",
+			"element programme/credits/stuntman (x1)",
+			"element programme/season (x2)",
+			"element tv/extension (x1)",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strict, func(t *testing.T) {
+			prev := *strictParse
+			*strictParse = tt.strict
+			defer func() { *strictParse = prev }()
+
+			var s source
+			if err := xml.Unmarshal([]byte(doc), &s); err != nil {
+				t.Fatal(err)
+			}
+			if tt.strict == "off" && (s.Unknown != nil || s.ProgramList[0].Unknown != nil || s.ProgramList[0].UnknownAttrs != nil) {
+				t.Errorf("kept unknown fields without -strictParse: %v %v", s.Unknown, s.ProgramList[0].Unknown)
+			}
+			if got := unknownFields(&s); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// tenant is an operator for which the same source data is published with
+// its own channel mapping, event ID namespace and output tree.
+type tenant struct {
+	Name         string `json:"name"`
+	ChannelsFile string `json:"channelsFile"`
+}
+
+// outputDir returns the directory for the tenant's output files.
+func (t tenant) outputDir(base string) string {
+	if t.Name == "" {
+		return base
+	}
+	return filepath.Join(base, t.Name)
+}
+
+func (t tenant) fileName(channelID string) string {
+	if t.Name == "" {
+		return fmt.Sprintf("n_events_%s.xml", channelID)
+	}
+	return fmt.Sprintf("%s_n_events_%s.xml", t.Name, channelID)
+}
+
+func (t tenant) deltaFileName(channelID string) string {
+	return strings.TrimSuffix(t.fileName(channelID), ".xml") + ".delta.xml"
+}
+
+func (t tenant) tvaFileName(channelID string) string {
+	return strings.TrimSuffix(t.fileName(channelID), ".xml") + ".tva.xml"
+}
+
+func (t tenant) eitFileName(channelID string) string {
+	return strings.TrimSuffix(t.fileName(channelID), ".xml") + ".eit.json"
+}
+
+// prefixIDs namespaces the IDs of the channel events with the tenant name.
+func (t tenant) prefixIDs(c *outputChannel) {
+	if t.Name == "" {
+		return
+	}
+	for i := range c.Events.Values {
+		c.Events.Values[i].ID = fmt.Sprintf("%s-%s", t.Name, c.Events.Values[i].ID)
+	}
+}
+
+// validatePathName checks that a tenant or package name used in the output
+// paths stays a single path element.
+func validatePathName(kind, name string) error {
+	if name == "." || name == ".." || strings.ContainsAny(name, `/\`) || strings.Contains(name, "..") {
+		return fmt.Errorf("invalid %s name '%s'", kind, name)
+	}
+	return nil
+}
+
+// tenantList is a repeatable flag of "name" or "name=channels.csv" values.
+// Tenants without a channels file use the one given by -channelsFile.
+type tenantList []tenant
+
+func (l *tenantList) String() string {
+	var names []string
+	for _, t := range *l {
+		names = append(names, t.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func (l *tenantList) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	t := tenant{Name: strings.TrimSpace(parts[0])}
+	if t.Name == "" {
+		return fmt.Errorf("tenant name is required")
+	}
+	if err := validatePathName("tenant", t.Name); err != nil {
+		return err
+	}
+	if len(parts) == 2 {
+		t.ChannelsFile = strings.TrimSpace(parts[1])
+	}
+	*l = append(*l, t)
+	return nil
+}
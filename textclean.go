@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// textCleanup counts the changes made by cleanText.
+type textCleanup struct {
+	composed int
+	replaced int
+	stripped int
+}
+
+// cleanText normalizes s to NFC, replaces the no-break spaces with spaces
+// and strips the zero-width spaces, direction marks, soft hyphens and control
+// characters other than tab and newline. The zero-width joiner and non-joiner
+// are kept, as they select the form of emoji sequences and of letters in
+// several scripts.
+func (c *textCleanup) cleanText(s string) string {
+	clean := true
+	for _, r := range s {
+		if r >= 0x80 || (unicode.IsControl(r) && r != '\t' && r != '\n') {
+			clean = false
+			break
+		}
+	}
+	if clean {
+		return s
+	}
+
+	if !norm.NFC.IsNormalString(s) {
+		n := utf8.RuneCountInString(s)
+		s = norm.NFC.String(s)
+		c.composed += n - utf8.RuneCountInString(s)
+	}
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\u00a0' || r == '\u2007' || r == '\u202f':
+			c.replaced++
+			r = ' '
+		case r == '\u200b' || r == '\u200e' || r == '\u200f' || r == '\u2060' || r == '\ufeff' || r == '\u00ad' ||
+			(unicode.IsControl(r) && r != '\t' && r != '\n'):
+			c.stripped++
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// cleanEvent cleans the text elements of the event.
+func (c *textCleanup) cleanEvent(e *outputEvent) {
+	for _, v := range []*string{&e.Name, &e.Perex, &e.Description, &e.Actors, &e.Directors, &e.ProductionCountries, &e.URL} {
+		*v = c.cleanText(*v)
+	}
+	for _, list := range [][]string{e.ActorList, e.DirectorList} {
+		for i := range list {
+			list[i] = c.cleanText(list[i])
+		}
+	}
+}
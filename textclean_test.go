@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestCleanText(t *testing.T) {
+	tests := []struct {
+		name         string
+		in, want     string
+		wantComposed int
+		wantReplaced int
+		wantStripped int
+	}{
+		{"ascii", "News at 6", "News at 6", 0, 0, 0},
+		{"cyrillic breve", "Кра\u0438\u0306", "Край", 1, 0, 0},
+		{"greek tonos", "Ελλα\u0301δα", "Ελλάδα", 1, 0, 0},
+		{"vietnamese stacked marks", "Vie\u0323\u0302t", "Việt", 2, 0, 0},
+		{"hangul jamo", "\u1112\u1161\u11ab", "한", 2, 0, 0},
+		{"no-break spaces", "1\u00a02\u202f3", "1 2 3", 0, 2, 0},
+		{"zero-width and soft hyphen", "Fuß\u00adball\u200b\ufeff", "Fußball", 0, 0, 3},
+		{"control characters", "a\x07b\tc\nd", "ab\tc\nd", 0, 0, 1},
+		{"emoji joiner kept", "👩\u200d💻", "👩\u200d💻", 0, 0, 0},
+		{"non-joiner kept", "می\u200cخواهم", "می\u200cخواهم", 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c textCleanup
+			if got := c.cleanText(tt.in); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if c.composed != tt.wantComposed || c.replaced != tt.wantReplaced || c.stripped != tt.wantStripped {
+				t.Errorf("got composed=%d replaced=%d stripped=%d, want %d %d %d",
+					c.composed, c.replaced, c.stripped, tt.wantComposed, tt.wantReplaced, tt.wantStripped)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// span is the time an event takes, including its start and excluding its
+// end, except for an instant event whose start equals its end.
+type span struct {
+	start time.Time
+	end   time.Time
+}
+
+func (s span) instant() bool {
+	return s.start.Equal(s.end)
+}
+
+func (s span) overlaps(o span) bool {
+	return (s.start.Before(o.end) || (s.start.Equal(o.end) && o.instant())) &&
+		(o.start.Before(s.end) || (o.start.Equal(s.end) && s.instant()))
+}
+
+// timeline keeps the spans of the accepted events ordered by start. The
+// spans never overlap, so their ends are ordered too and the spans
+// overlapping a new event are found by a binary search instead of
+// comparing it with every accepted event.
+type timeline []span
+
+// intersections returns the parts of s overlapped by the spans of the
+// timeline.
+func (t timeline) intersections(s span) []span {
+	i := sort.Search(len(t), func(i int) bool {
+		return t[i].end.After(s.start) || (t[i].end.Equal(s.start) && t[i].instant())
+	})
+	var result []span
+	for ; i < len(t); i++ {
+		if t[i].start.After(s.end) || (t[i].start.Equal(s.end) && !s.instant()) {
+			break
+		}
+		if !t[i].overlaps(s) {
+			continue
+		}
+		intersection := span{start: s.start, end: s.end}
+		if t[i].start.After(intersection.start) {
+			intersection.start = t[i].start
+		}
+		if t[i].end.Before(intersection.end) {
+			intersection.end = t[i].end
+		}
+		result = append(result, intersection)
+	}
+	return result
+}
+
+// insert adds a span not overlapping any span of the timeline.
+func (t *timeline) insert(s span) {
+	spans := *t
+	i := sort.Search(len(spans), func(i int) bool { return spans[i].start.After(s.start) })
+	spans = append(spans, span{})
+	copy(spans[i+1:], spans[i:])
+	spans[i] = s
+	*t = spans
+}
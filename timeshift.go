@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// minShiftMatches is the number of source programmes which have to match an
+// event of the previous output before a shift of the schedule is detected.
+const minShiftMatches = 5
+
+// detectTimeShift compares the source programmes of a channel, moved by the
+// offset, with its previous output. A shift is detected when a non-zero
+// offset is agreed on, see scheduleOffset, which is typical for a provider
+// writing a wrong timezone offset.
+func detectTimeShift(prev *outputChannel, events []programme, offset time.Duration) (time.Duration, bool) {
+	shift, ok := scheduleOffset(prev, events, offset)
+	return shift, ok && shift != 0
+}
+
+// scheduleOffset returns the offset of the source programmes, moved by the
+// offset, to the previous output. Every programme votes for the offsets to
+// the events of the same title starting within 12 hours of it, and the
+// offset is agreed on when it has the votes of at least 80% of at least
+// minShiftMatches matched programmes.
+func scheduleOffset(prev *outputChannel, events []programme, offset time.Duration) (time.Duration, bool) {
+	starts := make(map[string][]time.Time)
+	for _, e := range prev.Events.Values {
+		if start, _, err := eventTimes(e); err == nil {
+			key := strings.ToLower(e.Name)
+			starts[key] = append(starts[key], start)
+		}
+	}
+
+	votes := make(map[time.Duration]int)
+	matched := 0
+	for _, e := range events {
+		start, err := parseSourceTime(e.Start)
+		if err != nil {
+			continue
+		}
+		start = start.Add(offset)
+		offsets := make(map[time.Duration]bool)
+		for _, t := range e.Title {
+			for _, s := range starts[strings.ToLower(t.Name)] {
+				if d := start.Sub(s); absDuration(d) <= 12*time.Hour {
+					offsets[d] = true
+				}
+			}
+		}
+		if len(offsets) > 0 {
+			matched++
+		}
+		for d := range offsets {
+			votes[d]++
+		}
+	}
+
+	var shift time.Duration
+	for d, n := range votes {
+		if n > votes[shift] || (n == votes[shift] && d < shift) {
+			shift = d
+		}
+	}
+	if matched < minShiftMatches || votes[shift]*5 < matched*4 {
+		return 0, false
+	}
+	return shift, true
+}
+
+// checkTimeShift reports a shift of the channel's schedule against its
+// previous output and returns the programmes to convert. A channel opting in
+// with the correctShift option is moved back by the shift, since the previous
+// output alone may be the wrong one. With -timeShift correct only the
+// programmes of the providers whose shift is confirmed by another provider
+// of the channel still matching the previous output are moved back.
+func checkTimeShift(channel *requestedChannel, prev *outputChannel, events []programme, report *runReport) []programme {
+	if *timeShiftMode == "correct" && !channel.Options.CorrectShift {
+		if corrected, shifts := correctShiftedProviders(prev, events, channel.Options.Offset); len(shifts) > 0 {
+			var providers []string
+			for provider := range shifts {
+				providers = append(providers, provider)
+			}
+			sort.Strings(providers)
+			for _, provider := range providers {
+				report.timeShift(*channel, shifts[provider], fmt.Sprintf(", corrected for %s as confirmed by another "+
+					"provider", provider))
+			}
+			return corrected
+		}
+	}
+	shift, ok := detectTimeShift(prev, events, channel.Options.Offset)
+	if !ok {
+		return events
+	}
+	if channel.Options.CorrectShift {
+		report.timeShift(*channel, shift, fmt.Sprintf(", corrected by %s", -shift))
+		channel.Options.Offset -= shift
+		return events
+	}
+	report.timeShift(*channel, shift, "")
+	return events
+}
+
+// correctShiftedProviders moves back the programmes of every provider whose
+// schedule is shifted against the previous output, provided another provider
+// agrees with the previous output without a shift. It returns the shifts of
+// the moved providers, none without such a reference.
+func correctShiftedProviders(prev *outputChannel, events []programme, offset time.Duration) ([]programme, map[string]time.Duration) {
+	groups := make(map[string][]programme)
+	for _, e := range events {
+		key := e.Provider
+		if key == "" {
+			key = e.SourceFile
+		}
+		groups[key] = append(groups[key], e)
+	}
+	shifts := make(map[string]time.Duration)
+	confirmed := false
+	for key, group := range groups {
+		shift, ok := scheduleOffset(prev, group, offset)
+		switch {
+		case ok && shift == 0:
+			confirmed = true
+		case ok:
+			shifts[key] = shift
+		}
+	}
+	if !confirmed || len(shifts) == 0 {
+		return events, nil
+	}
+
+	corrected := make([]programme, 0, len(events))
+	for _, e := range events {
+		key := e.Provider
+		if key == "" {
+			key = e.SourceFile
+		}
+		if shift, ok := shifts[key]; ok {
+			e = shiftProgramme(e, -shift)
+		}
+		corrected = append(corrected, e)
+	}
+	return corrected, shifts
+}
+
+// shiftProgramme moves the start and stop of the programme by d, keeping
+// the times it fails to parse.
+func shiftProgramme(p programme, d time.Duration) programme {
+	if start, err := parseSourceTime(p.Start); err == nil {
+		p.Start = start.Add(d).Format(inDateLayout)
+	}
+	if stop, err := parseSourceTime(p.Stop); err == nil {
+		p.Stop = stop.Add(d).Format(inDateLayout)
+	}
+	return p
+}
+
+// timeShift records a channel whose schedule moved by a constant offset
+// compared to the previous output, the detail telling how it was corrected.
+func (r *runReport) timeShift(channel requestedChannel, shift time.Duration, correction string) {
+	detail := fmt.Sprintf("schedule shifted by %s compared to the previous output", shift) + correction
+	r.add(reportEntry{
+		Kind:      "time shift",
+		ChannelID: channel.ID,
+		Channel:   channel.Name,
+		Detail:    detail,
+	}, fmt.Sprintf("time shift %s channel=\"%s\": %s\n", channel.ID, channel.Name, detail))
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+// shiftFixture returns a previous output of n hourly events and the source
+// programmes of the same titles moved by shift, from the provider.
+func shiftFixture(n int, shift time.Duration, provider string) (*outputChannel, []programme) {
+	base := time.Date(2024, 1, 1, 6, 0, 0, 0, time.UTC)
+	prev := &outputChannel{ID: "1", Name: "One"}
+	var events []programme
+	for i := 0; i < n; i++ {
+		start := base.Add(time.Duration(i) * time.Hour)
+		name := fmt.Sprintf("Show %d", i)
+		prev.Events.Values = append(prev.Events.Values, outputEvent{ID: name, Name: name,
+			StartTime: start.Format(outDateLayout), EndTime: start.Add(time.Hour).Format(outDateLayout)})
+		events = append(events, programme{Title: []title{{Name: name}}, Provider: provider,
+			Start: start.Add(shift).Format(inDateLayout), Stop: start.Add(shift + time.Hour).Format(inDateLayout)})
+	}
+	return prev, events
+}
+
+func TestDetectTimeShift(t *testing.T) {
+	tests := []struct {
+		name      string
+		events    int
+		shift     time.Duration
+		offset    time.Duration
+		wantShift time.Duration
+		wantOK    bool
+	}{
+		{"unchanged", 10, 0, 0, 0, false},
+		{"shifted", 10, time.Hour, 0, time.Hour, true},
+		{"shifted back", 10, -2 * time.Hour, 0, -2 * time.Hour, true},
+		{"offset applied", 10, time.Hour, -time.Hour, 0, false},
+		{"too few matches", minShiftMatches - 1, time.Hour, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prev, events := shiftFixture(tt.events, tt.shift, "")
+			shift, ok := detectTimeShift(prev, events, tt.offset)
+			if shift != tt.wantShift || ok != tt.wantOK {
+				t.Errorf("got %s %v, want %s %v", shift, ok, tt.wantShift, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestCheckTimeShift(t *testing.T) {
+	defer func(mode string) { *timeShiftMode = mode }(*timeShiftMode)
+	*timeShiftMode = "correct"
+
+	tests := []struct {
+		name         string
+		correctShift bool
+		reference    bool
+		wantOffset   time.Duration
+		wantMoved    bool
+	}{
+		{"no reference", false, false, 0, false},
+		{"confirmed by another provider", false, true, 0, true},
+		{"opted in", true, false, -time.Hour, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prev, events := shiftFixture(10, time.Hour, "shifted")
+			if tt.reference {
+				_, reference := shiftFixture(10, 0, "reference")
+				events = append(events, reference...)
+			}
+			channel := requestedChannel{ID: "1", Name: "One"}
+			channel.Options.CorrectShift = tt.correctShift
+			got := checkTimeShift(&channel, prev, events, newRunReport(ioutil.Discard))
+			if channel.Options.Offset != tt.wantOffset {
+				t.Errorf("got offset %s, want %s", channel.Options.Offset, tt.wantOffset)
+			}
+			if moved := got[0].Start != events[0].Start; moved != tt.wantMoved {
+				t.Errorf("got start %s, moved %v, want %v", got[0].Start, moved, tt.wantMoved)
+			}
+			if tt.wantMoved && got[0].Start != "20240101060000 +0000" {
+				t.Errorf("got start %s, want the previous start", got[0].Start)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// normalizeTitle changes the case of a title with the title or sentence
+// mode, e.g "THE BIG BANG THEORY" becomes "The Big Bang Theory" or "The big
+// bang theory". Words listed in acronyms keep their listed form and words
+// containing digits are left alone.
+func normalizeTitle(name string, mode string, acronyms []string) string {
+	if mode == "off" || name == "" {
+		return name
+	}
+	words := strings.Fields(name)
+	sentenceStart := true
+	for i, w := range words {
+		core := strings.TrimFunc(w, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+		switch {
+		case core == "":
+		case strings.IndexFunc(core, unicode.IsDigit) >= 0:
+		case acronym(core, acronyms) != "":
+			w = strings.Replace(w, core, acronym(core, acronyms), 1)
+		default:
+			w = strings.ToLower(w)
+			if mode == "title" || sentenceStart {
+				w = upperFirstLetter(w)
+			}
+		}
+		words[i] = w
+		if core != "" {
+			sentenceStart = strings.ContainsAny(w[len(w)-1:], ".!?:")
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func acronym(word string, acronyms []string) string {
+	for _, a := range acronyms {
+		if strings.EqualFold(a, word) {
+			return a
+		}
+	}
+	return ""
+}
+
+func upperFirstLetter(w string) string {
+	for i, r := range w {
+		if unicode.IsLetter(r) {
+			return w[:i] + string(unicode.ToUpper(r)) + w[i+utf8.RuneLen(r):]
+		}
+	}
+	return w
+}
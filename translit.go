@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// cyrillicToLatin follows the Bulgarian streamlined system, extended with
+// the letters of Russian and Ukrainian.
+var cyrillicToLatin = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ж': "zh", 'з': "z", 'и': "i", 'й': "y",
+	'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "h", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "sht", 'ъ': "a", 'ь': "y", 'ю': "yu", 'я': "ya",
+	'ё': "yo", 'ы': "y", 'э': "e", 'є': "ye", 'і': "i", 'ї': "yi", 'ґ': "g",
+}
+
+// latinToCyrillic lists the digraphs before the single letters, longest
+// first.
+var latinToCyrillic = []struct{ latin, cyrillic string }{
+	{"sht", "щ"}, {"zh", "ж"}, {"ts", "ц"}, {"ch", "ч"}, {"sh", "ш"}, {"yu", "ю"}, {"ya", "я"},
+	{"a", "а"}, {"b", "б"}, {"c", "ц"}, {"d", "д"}, {"e", "е"}, {"f", "ф"}, {"g", "г"}, {"h", "х"},
+	{"i", "и"}, {"j", "дж"}, {"k", "к"}, {"l", "л"}, {"m", "м"}, {"n", "н"}, {"o", "о"}, {"p", "п"},
+	{"q", "к"}, {"r", "р"}, {"s", "с"}, {"t", "т"}, {"u", "у"}, {"v", "в"}, {"w", "в"}, {"x", "кс"},
+	{"y", "й"}, {"z", "з"},
+}
+
+// translitFieldNames are the event elements which can be transliterated.
+var translitFieldNames = []string{"name", "perex", "description", "actors", "directors", "production_countries"}
+
+// transliterate converts s to the latin or the cyrillic script, keeping the
+// case of the letters and any character of another script.
+func transliterate(s string, mode string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		lower := unicode.ToLower(r)
+		upperWord := unicode.IsUpper(r) && i+1 < len(runes) && unicode.IsUpper(runes[i+1])
+
+		var out string
+		n := 1
+		if mode == "latin" {
+			out = cyrillicToLatin[lower]
+			// "-ия" ending a word is written "ia", e.g България as Bulgaria
+			if lower == 'и' && i+1 < len(runes) && unicode.ToLower(runes[i+1]) == 'я' &&
+				(i+2 == len(runes) || !unicode.IsLetter(runes[i+2])) {
+				out, n = "ia", 2
+			}
+		} else if lower == 'i' && i+1 < len(runes) && unicode.ToLower(runes[i+1]) == 'a' &&
+			(i+2 == len(runes) || !unicode.IsLetter(runes[i+2])) {
+			out, n = "ия", 2
+		} else {
+			for _, l := range latinToCyrillic {
+				if end := i + len(l.latin); end <= len(runes) && strings.EqualFold(string(runes[i:end]), l.latin) {
+					out, n = l.cyrillic, len(l.latin)
+					break
+				}
+			}
+		}
+		if out == "" {
+			b.WriteRune(r)
+			i++
+			continue
+		}
+		switch {
+		case upperWord:
+			out = strings.ToUpper(out)
+		case unicode.IsUpper(r):
+			out = upperFirstLetter(out)
+		}
+		b.WriteString(out)
+		i += n
+	}
+	return b.String()
+}
+
+// transliterateEvent transliterates the given fields of the event.
+func transliterateEvent(e *outputEvent, mode string, fields []string) {
+	for _, f := range fields {
+		var v *string
+		var list []string
+		switch f {
+		case "name":
+			v = &e.Name
+		case "perex":
+			v = &e.Perex
+		case "description":
+			v = &e.Description
+		case "actors":
+			v, list = &e.Actors, e.ActorList
+		case "directors":
+			v, list = &e.Directors, e.DirectorList
+		case "production_countries":
+			if !*countryCodes {
+				v = &e.ProductionCountries
+			}
+		}
+		if v != nil {
+			*v = transliterate(*v, mode)
+		}
+		for i := range list {
+			list[i] = transliterate(list[i], mode)
+		}
+	}
+}
+
+func validateTranslitFields(fields []string) error {
+	for _, f := range fields {
+		if !containsString(translitFieldNames, f) {
+			return fmt.Errorf("unsupported transliteration field '%s', expected one of %s", f, strings.Join(translitFieldNames, ", "))
+		}
+	}
+	return nil
+}
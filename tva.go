@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const tvaNamespace = "urn:tva:metadata:2019"
+
+// tvaMain is a TV-Anytime (ETSI TS 102 822-3-1) document describing the
+// schedule of a single channel.
+type tvaMain struct {
+	XMLName            struct{}       `xml:"TVAMain"`
+	Namespace          string         `xml:"xmlns,attr"`
+	Lang               string         `xml:"xml:lang,attr,omitempty"`
+	ProgramInformation []tvaProgram   `xml:"ProgramDescription>ProgramInformationTable>ProgramInformation"`
+	Schedule           tvaSchedule    `xml:"ProgramDescription>ProgramLocationTable>Schedule"`
+	Service            tvaServiceInfo `xml:"ProgramDescription>ServiceInformationTable>ServiceInformation"`
+}
+
+type tvaProgram struct {
+	ProgramID           string          `xml:"programId,attr"`
+	Title               tvaTitle        `xml:"BasicDescription>Title"`
+	Synopsis            []tvaSynopsis   `xml:"BasicDescription>Synopsis"`
+	Keywords            []string        `xml:"BasicDescription>Keyword"`
+	Credits             *tvaCreditsList `xml:"BasicDescription>CreditsList,omitempty"`
+	ProductionDate      *tvaTimePoint   `xml:"BasicDescription>ProductionDate,omitempty"`
+	ProductionLocations []string        `xml:"BasicDescription>ProductionLocation"`
+}
+
+type tvaCreditsList struct {
+	Items []tvaCreditsItem `xml:"CreditsItem"`
+}
+
+type tvaTimePoint struct {
+	TimePoint string `xml:"TimePoint"`
+}
+
+type tvaTitle struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type tvaSynopsis struct {
+	Length string `xml:"length,attr"`
+	Value  string `xml:",chardata"`
+}
+
+type tvaCreditsItem struct {
+	Role       string `xml:"role,attr"`
+	PersonName string `xml:"PersonName"`
+}
+
+type tvaSchedule struct {
+	ServiceIDRef string             `xml:"serviceIDRef,attr"`
+	Start        string             `xml:"start,attr,omitempty"`
+	End          string             `xml:"end,attr,omitempty"`
+	Events       []tvaScheduleEvent `xml:"ScheduleEvent"`
+}
+
+type tvaScheduleEvent struct {
+	Program           tvaCRIDRef `xml:"Program"`
+	PublishedStart    string     `xml:"PublishedStartTime"`
+	PublishedDuration string     `xml:"PublishedDuration"`
+}
+
+type tvaCRIDRef struct {
+	CRID string `xml:"crid,attr"`
+}
+
+type tvaServiceInfo struct {
+	ServiceID string `xml:"serviceId,attr"`
+	Name      string `xml:"Name"`
+}
+
+// tvaDocument converts a channel to a TV-Anytime document in the language
+// lang. Events without a CRID in the source get one derived from the channel
+// and event IDs.
+func tvaDocument(c *outputChannel, lang string) (*tvaMain, error) {
+	doc := &tvaMain{
+		Namespace: tvaNamespace,
+		Lang:      lang,
+		Schedule:  tvaSchedule{ServiceIDRef: c.ID},
+		Service:   tvaServiceInfo{ServiceID: c.ID, Name: c.Name},
+	}
+	for i, e := range c.Events.Values {
+		start, end, err := eventTimes(e)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time of event '%s' due: %v", e.ID, err)
+		}
+		crid := e.CRID
+		if crid == "" {
+			crid = fmt.Sprintf("crid://%s/%s/%s", *tvaAuthority, c.ID, e.ID)
+		}
+
+		p := tvaProgram{
+			ProgramID: crid,
+			Title:     tvaTitle{Type: "main", Value: e.Name},
+		}
+		if e.ProductionYear != "" {
+			p.ProductionDate = &tvaTimePoint{TimePoint: e.ProductionYear}
+		}
+		if e.Perex != "" {
+			p.Synopsis = append(p.Synopsis, tvaSynopsis{Length: "short", Value: e.Perex})
+		}
+		if e.Description != "" {
+			p.Synopsis = append(p.Synopsis, tvaSynopsis{Length: "long", Value: e.Description})
+		}
+		if e.Tags != nil {
+			p.Keywords = e.Tags.Values
+		}
+		var credits []tvaCreditsItem
+		for _, name := range e.actorNames() {
+			credits = append(credits, tvaCreditsItem{Role: "urn:mpeg:mpeg7:cs:RoleCS:2001:ACTOR", PersonName: name})
+		}
+		for _, name := range e.directorNames() {
+			credits = append(credits, tvaCreditsItem{Role: "urn:mpeg:mpeg7:cs:RoleCS:2001:DIRECTOR", PersonName: name})
+		}
+		if len(credits) > 0 {
+			p.Credits = &tvaCreditsList{Items: credits}
+		}
+		p.ProductionLocations = splitList(e.ProductionCountries)
+		doc.ProgramInformation = append(doc.ProgramInformation, p)
+
+		doc.Schedule.Events = append(doc.Schedule.Events, tvaScheduleEvent{
+			Program:           tvaCRIDRef{CRID: crid},
+			PublishedStart:    e.StartTime,
+			PublishedDuration: isoDuration(end.Sub(start)),
+		})
+		if i == 0 {
+			doc.Schedule.Start = e.StartTime
+		}
+		doc.Schedule.End = end.UTC().Format(outDateLayout)
+	}
+	return doc, nil
+}
+
+// isoDuration formats d as an ISO 8601 duration, e.g PT1H30M.
+func isoDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	var b strings.Builder
+	b.WriteString("PT")
+	if h := d / time.Hour; h > 0 {
+		fmt.Fprintf(&b, "%dH", h)
+	}
+	if m := d % time.Hour / time.Minute; m > 0 {
+		fmt.Fprintf(&b, "%dM", m)
+	}
+	if s := d % time.Minute / time.Second; s > 0 || d == 0 {
+		fmt.Fprintf(&b, "%dS", s)
+	}
+	return b.String()
+}
+
+func encodeTVA(w io.Writer, comment string, channel *outputChannel, lang string) error {
+	doc, err := tvaDocument(channel, lang)
+	if err != nil {
+		return err
+	}
+	return encodeXML(w, comment, doc)
+}
+
+// decodeTVA converts a TV-Anytime document written by encodeTVA back to a
+// channel. Event IDs are taken from the CRIDs derived by tvaDocument, other
+// CRIDs are kept as the event CRID and ID.
+func decodeTVA(data []byte) (*outputChannel, error) {
+	var doc tvaMain
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	programs := make(map[string]tvaProgram)
+	for _, p := range doc.ProgramInformation {
+		programs[p.ProgramID] = p
+	}
+	c := &outputChannel{ID: doc.Service.ServiceID, Name: doc.Service.Name}
+	derived := fmt.Sprintf("crid://%s/%s/", *tvaAuthority, c.ID)
+	for _, se := range doc.Schedule.Events {
+		start, err := time.Parse(outDateLayout, se.PublishedStart)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start of event '%s' due: %v", se.Program.CRID, err)
+		}
+		d, err := time.ParseDuration(strings.ToLower(strings.TrimPrefix(se.PublishedDuration, "PT")))
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration of event '%s' due: %v", se.Program.CRID, err)
+		}
+		p := programs[se.Program.CRID]
+		e := outputEvent{
+			ID:        strings.TrimPrefix(se.Program.CRID, derived),
+			Name:      p.Title.Value,
+			StartTime: se.PublishedStart,
+			EndTime:   start.Add(d).UTC().Format(outDateLayout),
+		}
+		if e.ID == se.Program.CRID {
+			e.CRID = se.Program.CRID
+		}
+		if p.ProductionDate != nil {
+			e.ProductionYear = p.ProductionDate.TimePoint
+		}
+		for _, s := range p.Synopsis {
+			if s.Length == "short" {
+				e.Perex = s.Value
+			} else {
+				e.Description = s.Value
+			}
+		}
+		if len(p.Keywords) > 0 {
+			e.Tags = &outputTags{Values: p.Keywords}
+		}
+		e.ProductionCountries = strings.Join(p.ProductionLocations, ", ")
+		c.Events.Values = append(c.Events.Values, e)
+	}
+	return c, nil
+}
@@ -0,0 +1,8 @@
+//go:build tzdata
+// +build tzdata
+
+package main
+
+// Building with -tags tzdata embeds the timezone database, for images
+// without tzdata installed.
+import _ "time/tzdata"
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var uiTemplate = template.Must(template.New("ui").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>epgtool channels</title>
+<style>body{font-family:sans-serif;margin:2em}table{border-collapse:collapse}td,th{border:1px solid #ccc;padding:.2em .5em}</style>
+</head>
+<body>
+{{if .Error}}<p style="color:red">{{.Error}}</p>{{end}}
+{{if .Preview}}
+<h1>{{.Preview.Name}} ({{.Preview.ID}})</h1>
+<p><a href="/ui/">back</a></p>
+<table>
+<tr><th>From</th><th>Till</th><th>Name</th></tr>
+{{range .Preview.Events.Values}}<tr><td>{{.StartTime}}</td><td>{{.EndTime}}</td><td>{{.Name}}</td></tr>
+{{end}}
+</table>
+{{else}}
+<h1>Channel mapping</h1>
+<p>{{.ChannelsFile}}</p>
+<table>
+<tr><th>ID</th><th>Source channel</th><th>Options</th><th>Events</th><th></th></tr>
+{{range $i, $c := .Channels}}<tr><form method="post" action="/ui/mapping">
+<td><input name="id" value="{{$c.ID}}" size="6"></td>
+<td><input name="name" value="{{$c.Name}}"></td>
+<td><input name="options" value="{{$c.OptionSpec}}" size="40"></td>
+<td>{{index $.EventCounts $c.Name}}</td>
+<td><input type="hidden" name="index" value="{{$i}}"><input type="hidden" name="csrf" value="{{$.CSRF}}">
+<button name="action" value="save">Save</button>
+<button name="action" value="delete">Delete</button>
+<a href="/ui/preview?index={{$i}}">preview</a></td>
+</form></tr>
+{{end}}
+<tr><form method="post" action="/ui/mapping">
+<td><input name="id" size="6"></td>
+<td><input name="name" list="unmatched"></td>
+<td><input name="options" size="40"></td>
+<td></td>
+<td><input type="hidden" name="csrf" value="{{$.CSRF}}"><button name="action" value="add">Add</button></td>
+</form></tr>
+</table>
+<datalist id="unmatched">{{range .Unmatched}}<option value="{{.}}">{{end}}</datalist>
+<h2>Unmatched source channels</h2>
+<ul>{{range .Unmatched}}<li><form method="post" action="/ui/mapping">{{.}} ({{index $.EventCounts .}} events)
+<input type="hidden" name="id" value="-"><input type="hidden" name="name" value="{{.}}"><input type="hidden" name="csrf" value="{{$.CSRF}}">
+<button name="action" value="add">Ignore</button></form></li>{{else}}<li>none</li>{{end}}</ul>
+<h2>Ignored source channels</h2>
+<ul>{{range .Ignored}}<li>{{.}}</li>{{else}}<li>none</li>{{end}}</ul>
+{{end}}
+</body>
+</html>
+`))
+
+type uiPage struct {
+	ChannelsFile string
+	Channels     []requestedChannel
+	EventCounts  map[string]int
+	Unmatched    []string
+	Ignored      []string
+	Preview      *outputChannel
+	Error        string
+	// CSRF is the token the mapping forms post back.
+	CSRF string
+}
+
+// loadPage reads the mapping of the default job together with the source
+// channels of its latest source files, which the caller closes.
+func (s *server) loadPage() (*uiPage, *sourceChannels, error) {
+	j := defaultJob()
+	channels, err := loadRequestedChannels(j.ChannelsFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	_, sources, err := j.channelEvents(context.Background(), newSourceCache())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	page := &uiPage{ChannelsFile: j.ChannelsFile, Channels: channels, EventCounts: make(map[string]int)}
+	for _, c := range channels {
+		if c.ignored() {
+			page.Ignored = append(page.Ignored, c.Name)
+		}
+	}
+	for _, name := range sources.names() {
+		page.EventCounts[name] = sources.count(name)
+	}
+	page.Unmatched = unmappedChannels(channels, sources)
+	return page, sources, nil
+}
+
+// handleUI serves the mapping overview. Like the preview it only reads the
+// channels file, which is written by the POST requests of handleMapping.
+func (s *server) handleUI(w http.ResponseWriter, req *http.Request) {
+	s.mappingMu.Lock()
+	defer s.mappingMu.Unlock()
+	page, sources, err := s.loadPage()
+	if err != nil {
+		page = &uiPage{Error: err.Error()}
+	}
+	sources.close()
+	s.renderUI(w, page)
+}
+
+// handlePreview serves the converted timeline of a mapped channel.
+func (s *server) handlePreview(w http.ResponseWriter, req *http.Request) {
+	s.mappingMu.Lock()
+	defer s.mappingMu.Unlock()
+	page, sources, err := s.loadPage()
+	if err != nil {
+		s.renderUI(w, &uiPage{Error: err.Error()})
+		return
+	}
+	defer sources.close()
+	i, err := strconv.Atoi(req.URL.Query().Get("index"))
+	if err != nil || i < 0 || i >= len(page.Channels) {
+		http.NotFound(w, req)
+		return
+	}
+
+	channel := page.Channels[i]
+	events, err := sources.events(channel.Name)
+	if err != nil {
+		page.Error = err.Error()
+		s.renderUI(w, page)
+		return
+	}
+	preview, err := convertChannel(channel, events, make(map[string]programme), transformRules, newRunReport(ioutil.Discard))
+	if err != nil {
+		page.Error = err.Error()
+		s.renderUI(w, page)
+		return
+	}
+	sortEvents(preview.Events.Values)
+	page.Preview = preview
+	s.renderUI(w, page)
+}
+
+// handleMapping adds, updates or deletes a row of the channels file.
+func (s *server) handleMapping(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(req.FormValue("csrf")), []byte(s.csrfToken)) != 1 {
+		http.Error(w, "invalid CSRF token", http.StatusForbidden)
+		return
+	}
+	s.mappingMu.Lock()
+	defer s.mappingMu.Unlock()
+
+	if err := s.updateMapping(req); err != nil {
+		page, sources, loadErr := s.loadPage()
+		defer sources.close()
+		if loadErr != nil {
+			page = &uiPage{}
+		}
+		page.Error = err.Error()
+		s.renderUI(w, page)
+		return
+	}
+	http.Redirect(w, req, "/ui/", http.StatusSeeOther)
+}
+
+func (s *server) updateMapping(req *http.Request) error {
+	fileName := defaultJob().ChannelsFile
+	channels, err := readRequestedChannels(fileName)
+	if err != nil {
+		return err
+	}
+
+	c := requestedChannel{
+		ID:         strings.TrimSpace(req.FormValue("id")),
+		Name:       strings.TrimSpace(req.FormValue("name")),
+		OptionSpec: strings.TrimSpace(req.FormValue("options")),
+	}
+	action := req.FormValue("action")
+	if action != "delete" {
+		if c.ID == "" || c.Name == "" {
+			return fmt.Errorf("channel ID and name are required")
+		}
+		if c.Options, err = parseChannelOptions(globalChannelOptions(), c.OptionSpec); err != nil {
+			return err
+		}
+	}
+
+	switch action {
+	case "add":
+		channels = append(channels, c)
+	case "save", "delete":
+		i, err := strconv.Atoi(req.FormValue("index"))
+		if err != nil || i < 0 || i >= len(channels) {
+			return fmt.Errorf("unknown channel row")
+		}
+		if action == "save" {
+			c.Line = channels[i].Line
+			channels[i] = c
+		} else {
+			channels = append(channels[:i], channels[i+1:]...)
+		}
+	default:
+		return fmt.Errorf("unknown action '%s'", action)
+	}
+
+	if err := writeRequestedChannels(fileName, channels); err != nil {
+		return err
+	}
+	log.Printf("channel mapping %s: %s %s", action, c.ID, c.Name)
+	return nil
+}
+
+func (s *server) renderUI(w http.ResponseWriter, page *uiPage) {
+	page.CSRF = s.csrfToken
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := uiTemplate.Execute(w, page); err != nil {
+		log.Printf("unable to render page due: %v", err)
+	}
+}
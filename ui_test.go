@@ -0,0 +1,116 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteRequestedChannels(t *testing.T) {
+	const original = "# operator lineup\nid,name\n1,One\n,\n2,\"Two, HD\",lang=en\n# sports\n3,Three\n"
+	tests := []struct {
+		name string
+		edit func([]requestedChannel) []requestedChannel
+		want string
+	}{
+		{
+			name: "unchanged",
+			edit: func(c []requestedChannel) []requestedChannel { return c },
+			want: original,
+		},
+		{
+			name: "changed row",
+			edit: func(c []requestedChannel) []requestedChannel {
+				c[1].OptionSpec = "lang=bg"
+				return c
+			},
+			want: "# operator lineup\nid,name\n1,One\n,\n2,\"Two, HD\",lang=bg\n# sports\n3,Three\n",
+		},
+		{
+			name: "deleted row",
+			edit: func(c []requestedChannel) []requestedChannel { return append(c[:1], c[2:]...) },
+			want: "# operator lineup\nid,name\n1,One\n,\n# sports\n3,Three\n",
+		},
+		{
+			name: "added row",
+			edit: func(c []requestedChannel) []requestedChannel {
+				return append(c, requestedChannel{ID: "4", Name: "Four"})
+			},
+			want: original + "4,Four\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), "channels.csv")
+			if err := ioutil.WriteFile(fileName, []byte(original), 0644); err != nil {
+				t.Fatal(err)
+			}
+			channels, _, err := parseChannelsFile(fileName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := writeRequestedChannels(fileName, tt.edit(channels)); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ioutil.ReadFile(fileName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleMappingRequiresCSRFToken(t *testing.T) {
+	s := newServer(0)
+	tests := []struct {
+		token string
+		want  int
+	}{
+		{"", http.StatusForbidden},
+		{"wrong", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		form := url.Values{"csrf": {tt.token}, "action": {"add"}, "id": {"1"}, "name": {"One"}}
+		req := httptest.NewRequest(http.MethodPost, "/ui/mapping", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		s.handleMapping(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("token %q: got status %d, want %d", tt.token, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestUIReadsChannelsFileWithoutWriting(t *testing.T) {
+	j, store := memoryJob(t)
+	const mapping = "151,\"Nickelodeon\"\n,\"New\"\n"
+	writeMemoryFile(t, store, j.ChannelsFile, mapping)
+	defer func(v bool, dir, file string) { *generateIDs, *dataDir, *channelsFile = v, dir, file }(*generateIDs, *dataDir, *channelsFile)
+	*generateIDs, *dataDir, *channelsFile = true, j.DataDir, j.ChannelsFile
+
+	s := newServer(0)
+	for _, target := range []string{"/ui/", "/ui/preview?index=0", "/ui/preview?index=1"} {
+		rec := httptest.NewRecorder()
+		s.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if data, _ := store.ReadFile(j.ChannelsFile); string(data) != mapping {
+			t.Errorf("GET %s changed the channels file to\n%s", target, data)
+		}
+	}
+
+	form := url.Values{"csrf": {s.csrfToken}, "action": {"add"}, "id": {"2"}, "name": {"Two"}}
+	req := httptest.NewRequest(http.MethodPost, "/ui/mapping", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	s.handleMapping(rec, req)
+	data, _ := store.ReadFile(j.ChannelsFile)
+	if rec.Code != http.StatusSeeOther || strings.Contains(string(data), ",\"New\"") || !strings.Contains(string(data), "2,Two") {
+		t.Errorf("POST got status %d and the channels file\n%s", rec.Code, data)
+	}
+}
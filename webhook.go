@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// webhookText describes the run in a few lines: its outcome, the written
+// files and the collision and gap counts exceeding their thresholds.
+func webhookText(n runNotice) (string, bool) {
+	var lines []string
+	alert := n.Failed || n.AnomalyLevel >= notifyAnomaly
+	if n.Failed {
+		lines = append(lines, fmt.Sprintf("EPG run %s failed: %s", n.Job, n.Error))
+	} else {
+		lines = append(lines, fmt.Sprintf("EPG run %s finished, anomaly level %s", n.Job, n.AnomalyLevel))
+	}
+	if n.Summary != nil {
+		for _, t := range n.Summary.Tenants {
+			name := t.Name
+			if name == "" {
+				name = "output"
+			}
+			lines = append(lines, fmt.Sprintf("%s: %d channels, %d files, %d events", name, t.Channels, t.WrittenFiles, t.Events))
+		}
+	}
+	if c := n.Counts["collision"]; *notifyCollisions > 0 && c > *notifyCollisions {
+		lines = append(lines, fmt.Sprintf("%d collisions exceed the threshold of %d", c, *notifyCollisions))
+		alert = true
+	}
+	if c := n.Counts["repaired gap"]; *notifyGaps > 0 && c > *notifyGaps {
+		lines = append(lines, fmt.Sprintf("%d repaired gaps exceed the threshold of %d", c, *notifyGaps))
+		alert = true
+	}
+	return strings.Join(lines, "\n"), alert
+}
+
+func slackMessage(n runNotice) interface{} {
+	text, alert := webhookText(n)
+	if alert {
+		text = ":warning: " + text
+	}
+	return map[string]string{"text": text}
+}
+
+// teamsMessage builds a legacy MessageCard, which Teams incoming webhooks
+// accept.
+func teamsMessage(n runNotice) interface{} {
+	text, alert := webhookText(n)
+	color := "2EB886"
+	if alert {
+		color = "D00000"
+	}
+	return map[string]string{
+		"@type":      "MessageCard",
+		"@context":   "http://schema.org/extensions",
+		"summary":    strings.SplitN(text, "\n", 2)[0],
+		"themeColor": color,
+		"text":       strings.Replace(text, "\n", "<br>", -1),
+	}
+}
+
+func postWebhook(url string, message interface{}) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}